@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
+type AdminRspT struct {
+	Err string `json:"err,omitempty"`
+}
+
+// AdminPoolReload 重新加载地址池配置，已分配的子网号不受影响
+func AdminPoolReload(c *gin.Context) {
+	err := SubnetPoolReload(cfgPath)
+	if err != nil {
+		log.Printf("ERROR 0x2f7d8c14 reload subnet pool fail:%s", err)
+		c.JSON(http.StatusBadRequest, &AdminRspT{Err: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, SubnetPoolStat())
+}
+
+type AdminReservationReqT struct {
+	Uuid  string `json:"uuid"`
+	SubId int    `json:"subId"`
+	Role  string `json:"role"` // Pac or Repeater
+	Note  string `json:"note"`
+}
+
+func AdminReservationGet(c *gin.Context) {
+	c.JSON(http.StatusOK, ReservationGetAll())
+}
+
+// AdminReservationSet 新增或修改静态预留
+func AdminReservationSet(c *gin.Context) {
+	var req AdminReservationReqT
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &AdminRspT{Err: err.Error()})
+		return
+	}
+	role, err := parseRole(req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &AdminRspT{Err: err.Error()})
+		return
+	}
+
+	res := &ReservationT{Uuid: req.Uuid, SubId: req.SubId, RoleType: role, Note: req.Note}
+	err = nodeMgr.reservationSet(res)
+	if err != nil {
+		log.Printf("ERROR 0x5b9e2a07 set reservation fail:%s", err)
+		c.JSON(http.StatusConflict, &AdminRspT{Err: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func AdminReservationDel(c *gin.Context) {
+	err := nodeMgr.reservationDel(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, &AdminRspT{Err: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, &AdminRspT{})
+}
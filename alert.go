@@ -0,0 +1,539 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// 告警的发送状态
+const (
+	ALERT_STATUS_SENDING = "sending"
+	ALERT_STATUS_SENT    = "sent"
+	ALERT_STATUS_FAILED  = "failed"
+)
+
+// AlertCfgT 告警，规则在单独的文件中，可通过 admin 接口重新加载
+type AlertCfgT struct {
+	File      string    `json:"file"`      // 规则文件，为空则不告警
+	Timeout   DurationT `json:"timeout"`   // 单次 webhook 请求的超时
+	Retry     int       `json:"retry"`     // webhook 失败后的重试次数
+	RetryWait DurationT `json:"retryWait"` // 首次重试前的等待时长，之后每次翻倍
+	History   int       `json:"history"`   // 内存中保留的触发记录条数
+}
+
+func (cfg *AlertCfgT) check() error {
+	if cfg.Timeout <= 0 || cfg.Retry < 0 || cfg.RetryWait <= 0 || cfg.History <= 0 {
+		return errors.New(fmt.Sprintf("0x6ad1f0c3 invalid alert timeout(%s), retry(%d), retryWait(%s), history(%d)",
+			time.Duration(cfg.Timeout), cfg.Retry, time.Duration(cfg.RetryWait), cfg.History))
+	}
+	return nil
+}
+
+// AlertRuleT 同一 node 在 window 内匹配的事件达到 count 条时触发，之后 silence 时长内不再重复触发
+// 异常事件(PINGLOSTPERCENT20、PINGACKNULL 等)按涉及的 repeater 统计，见 alertSubjectT
+type AlertRuleT struct {
+	Name    string            `json:"name"`
+	Types   []string          `json:"types"`   // 事件类型(名称或数值)，为空则匹配所有类型
+	Uuids   []string          `json:"uuids"`   // 统计对象的 uuid，为空则匹配所有 node
+	Roles   []string          `json:"roles"`   // 统计对象的角色(名称或数值)，为空则匹配所有角色
+	Count   int               `json:"count"`   // 触发的条数
+	Window  DurationT         `json:"window"`  // 统计条数的时间窗口
+	Silence DurationT         `json:"silence"` // 触发后同一 node 的静默时长
+	URL     string            `json:"url"`     // webhook
+	Method  string            `json:"method"`  // 默认 POST
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"` // text/template，字段见 AlertDataT，为空则发送 AlertDataT 的 json
+
+	typeMap map[int]bool
+	uuidMap map[string]bool
+	roleMap map[int]bool
+	tpl     *template.Template
+}
+
+type alertFileT struct {
+	Rules []*AlertRuleT `json:"rules"`
+}
+
+// init 校验并解析规则
+func (rule *AlertRuleT) init() error {
+	if len(rule.Name) == 0 || len(rule.URL) == 0 || rule.Count < 1 || rule.Window <= 0 || rule.Silence < 0 {
+		return errors.New(fmt.Sprintf("0x2f07c6e9 invalid alert rule(%s) url(%s), count(%d), window(%s), silence(%s)",
+			rule.Name, rule.URL, rule.Count, time.Duration(rule.Window), time.Duration(rule.Silence)))
+	}
+	if len(rule.Method) == 0 {
+		rule.Method = http.MethodPost
+	}
+
+	rule.typeMap = make(map[int]bool)
+	for _, s := range rule.Types {
+		eType, err := parseEventType(s)
+		if err != nil {
+			return err
+		}
+		rule.typeMap[eType] = true
+	}
+	rule.uuidMap = make(map[string]bool)
+	for _, uuid := range rule.Uuids {
+		rule.uuidMap[uuid] = true
+	}
+	rule.roleMap = make(map[int]bool)
+	for _, s := range rule.Roles {
+		role, err := strconv.Atoi(s)
+		if err != nil {
+			role, err = parseRole(s)
+			if err != nil {
+				return err
+			}
+		}
+		rule.roleMap[role] = true
+	}
+	if len(rule.Body) > 0 {
+		tpl, err := template.New(rule.Name).Parse(rule.Body)
+		if err != nil {
+			return errors.New(fmt.Sprintf("0x5c81e3a0 alert rule(%s) invalid body:%s", rule.Name, err))
+		}
+		rule.tpl = tpl
+	}
+	return nil
+}
+
+func (rule *AlertRuleT) match(e *EventItemDBT, subject *alertSubjectT) bool {
+	if len(rule.typeMap) > 0 && !rule.typeMap[e.EType] {
+		return false
+	}
+	if len(rule.uuidMap) > 0 && !rule.uuidMap[subject.uuid] {
+		return false
+	}
+	if len(rule.roleMap) > 0 && !rule.roleMap[subject.roleType] {
+		return false
+	}
+	return true
+}
+
+// alertSubjectT 告警的统计对象: 异常事件为涉及的 repeater(同 recordAbnormal)，其他事件为上报的 node
+// 多个客户端报告同一 repeater 时计入同一个对象
+type alertSubjectT struct {
+	uuid     string
+	roleType int
+}
+
+// AlertDataT webhook body 模板的数据
+type AlertDataT struct {
+	Rule     string    `json:"rule"`
+	Uuid     string    `json:"uuid"`     // 统计对象
+	Role     string    `json:"role"`     // 统计对象的角色
+	Reporter string    `json:"reporter"` // 最后一条事件的上报者
+	IP       string    `json:"ip"`       // 上报者的地址
+	Ver      string    `json:"ver"`      // 上报者的版本
+	Event    string    `json:"event"`    // 最后一条事件的类型名
+	Msg      string    `json:"msg"`      // 最后一条事件的 eventMsg
+	Count    int       `json:"count"`    // 窗口内的条数
+	Window   string    `json:"window"`
+	TS       time.Time `json:"ts"`
+}
+
+// AlertFireT 一次触发的记录
+type AlertFireT struct {
+	Id       int64      `json:"id"`
+	Data     AlertDataT `json:"data"`
+	Status   string     `json:"status"`   // sending, sent, failed
+	Attempts int        `json:"attempts"` // 已发送的次数
+	Code     int        `json:"code,omitempty"`
+	Err      string     `json:"err,omitempty"`
+	Done     time.Time  `json:"done,omitempty"`
+}
+
+// alertKeyT 按规则和统计对象计数、静默
+type alertKeyT struct {
+	rule string
+	uuid string
+}
+
+type alertEngineT struct {
+	cfg      AlertCfgT
+	ruleLst  []*AlertRuleT
+	tsMap    map[alertKeyT][]time.Time // 窗口内匹配的事件时间(升序)
+	fireMap  map[alertKeyT]time.Time   // 最后一次触发的时间，用于静默
+	history  []*AlertFireT             // 环形，最多 cfg.History 条
+	seq      int64
+	client   *http.Client
+	mtx      sync.Mutex
+	sending  sync.WaitGroup         // 进行中的发送
+	stopped  bool                   // 退出时停止，不再触发
+	stopCh   chan struct{}          // 关闭后重试不再等待
+	sendDone func(fire *AlertFireT) // 测试用，发送结束后调用
+}
+
+var (
+	alertEngine *alertEngineT
+)
+
+func newAlertEngine(cfg *AlertCfgT) (*alertEngineT, error) {
+	ae := &alertEngineT{
+		cfg:     *cfg,
+		tsMap:   make(map[alertKeyT][]time.Time),
+		fireMap: make(map[alertKeyT]time.Time),
+		client:  &http.Client{Timeout: time.Duration(cfg.Timeout)},
+		stopCh:  make(chan struct{}),
+	}
+	ruleLst, err := loadAlertRule(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	ae.ruleLst = ruleLst
+	return ae, nil
+}
+
+// loadAlertRule 规则名不能重复
+func loadAlertRule(path string) ([]*AlertRuleT, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x0c94a7e2 read alert rule(%s) fail:%s", path, err))
+	}
+	var file alertFileT
+	err = json.Unmarshal(buf, &file)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x7b2e05d8 parse alert rule(%s) fail:%s", path, err))
+	}
+
+	nameMap := make(map[string]bool)
+	for _, rule := range file.Rules {
+		err = rule.init()
+		if err != nil {
+			return nil, err
+		}
+		if nameMap[rule.Name] {
+			return nil, errors.New(fmt.Sprintf("0x41f6d9b3 duplicate alert rule(%s)", rule.Name))
+		}
+		nameMap[rule.Name] = true
+	}
+	return file.Rules, nil
+}
+
+// reload 重新读取规则文件，失败时保留原规则；已删除规则的统计一并清除
+func (ae *alertEngineT) reload() ([]*AlertRuleT, error) {
+	ruleLst, err := loadAlertRule(ae.cfg.File)
+	if err != nil {
+		return nil, err
+	}
+
+	ae.mtx.Lock()
+	defer ae.mtx.Unlock()
+
+	ae.ruleLst = ruleLst
+	ruleMap := make(map[string]bool)
+	for _, rule := range ruleLst {
+		ruleMap[rule.Name] = true
+	}
+	for key := range ae.tsMap {
+		if !ruleMap[key.rule] {
+			delete(ae.tsMap, key)
+		}
+	}
+	for key := range ae.fireMap {
+		if !ruleMap[key.rule] {
+			delete(ae.fireMap, key)
+		}
+	}
+	return ruleLst, nil
+}
+
+// observe 每条记录的事件都经过规则匹配，webhook 异步发送，不阻塞调用方
+// subjectLst 为空时按上报的 node 统计
+func (ae *alertEngineT) observe(e *EventItemDBT, subjectLst ...alertSubjectT) {
+	if ae == nil {
+		return
+	}
+	if len(subjectLst) == 0 {
+		subjectLst = []alertSubjectT{{uuid: e.Uuid, roleType: e.RoleType}}
+	}
+
+	ae.mtx.Lock()
+	defer ae.mtx.Unlock()
+
+	if ae.stopped {
+		return
+	}
+	for i := range subjectLst {
+		for _, rule := range ae.ruleLst {
+			ae.observeRule(rule, e, &subjectLst[i])
+		}
+	}
+}
+
+// observeRule 调用方需持有 mtx
+func (ae *alertEngineT) observeRule(rule *AlertRuleT, e *EventItemDBT, subject *alertSubjectT) {
+	if !rule.match(e, subject) {
+		return
+	}
+	key := alertKeyT{rule: rule.Name, uuid: subject.uuid}
+	since := e.TS.Add(-time.Duration(rule.Window))
+	tsLst := ae.tsMap[key]
+	i := 0
+	for i < len(tsLst) && !tsLst[i].After(since) {
+		i++
+	}
+	tsLst = append(tsLst[i:], e.TS)
+	if len(tsLst) < rule.Count {
+		ae.tsMap[key] = tsLst
+		return
+	}
+
+	// 达到阈值，重新计数；静默期内的不重复触发
+	delete(ae.tsMap, key)
+	if last, ok := ae.fireMap[key]; ok && e.TS.Before(last.Add(time.Duration(rule.Silence))) {
+		return
+	}
+	ae.fireMap[key] = e.TS
+
+	ae.seq++
+	fire := &AlertFireT{
+		Id: ae.seq,
+		Data: AlertDataT{
+			Rule:     rule.Name,
+			Uuid:     subject.uuid,
+			Role:     proto.Role(subject.roleType).String(),
+			Reporter: e.Uuid,
+			IP:       e.IP,
+			Ver:      e.Ver,
+			Event:    eventTypeName(e.EType),
+			Msg:      e.EMsg,
+			Count:    len(tsLst),
+			Window:   time.Duration(rule.Window).String(),
+			TS:       e.TS,
+		},
+		Status: ALERT_STATUS_SENDING,
+	}
+	ae.history = append(ae.history, fire)
+	if len(ae.history) > ae.cfg.History {
+		ae.history = ae.history[len(ae.history)-ae.cfg.History:]
+	}
+	log.Printf("WARN 0x3e7b1c08 alert rule(%s) fire, uuid:%s, reporter:%s, count:%d in %s", rule.Name, subject.uuid, e.Uuid, len(tsLst), fire.Data.Window)
+	ae.sending.Add(1)
+	go func(rule *AlertRuleT, fire *AlertFireT) {
+		defer ae.sending.Done()
+		ae.send(rule, fire)
+	}(rule, fire)
+}
+
+// stop 退出时调用: 不再触发新的告警，进行中的发送不再重试
+func (ae *alertEngineT) stop() {
+	if ae == nil {
+		return
+	}
+	ae.mtx.Lock()
+	defer ae.mtx.Unlock()
+
+	if !ae.stopped {
+		ae.stopped = true
+		close(ae.stopCh)
+	}
+}
+
+// wait 等进行中的发送完成，超过 ctx 时不再等待，返回是否都已完成
+func (ae *alertEngineT) wait(ctx context.Context) bool {
+	if ae == nil {
+		return true
+	}
+	done := make(chan struct{})
+	go func() {
+		ae.sending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// send 发送 webhook，非 2xx 或请求失败时按 retryWait 翻倍重试
+func (ae *alertEngineT) send(rule *AlertRuleT, fire *AlertFireT) {
+	wait := time.Duration(ae.cfg.RetryWait)
+	code := 0
+	attempts := 0
+	body, err := ae.render(rule, &fire.Data)
+	if err == nil {
+		for attempts <= ae.cfg.Retry {
+			if attempts > 0 {
+				// 退出中不再重试，保留上一次的失败
+				if !ae.sleep(wait) {
+					break
+				}
+				wait *= 2
+			}
+			attempts++
+			code, err = ae.post(rule, body)
+			if err == nil {
+				break
+			}
+			log.Printf("ERROR 0x1d6f38a5 alert rule(%s) webhook attempt %d fail:%s", rule.Name, attempts, err)
+		}
+	}
+
+	ae.mtx.Lock()
+	fire.Attempts = attempts
+	fire.Code = code
+	fire.Done = time.Now()
+	if err != nil {
+		fire.Status = ALERT_STATUS_FAILED
+		fire.Err = err.Error()
+	} else {
+		fire.Status = ALERT_STATUS_SENT
+	}
+	done := ae.sendDone
+	ae.mtx.Unlock()
+
+	if done != nil {
+		done(fire)
+	}
+}
+
+// sleep 等待 d，期间停止时返回 false
+func (ae *alertEngineT) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ae.stopCh:
+		return false
+	}
+}
+
+func (ae *alertEngineT) render(rule *AlertRuleT, data *AlertDataT) ([]byte, error) {
+	if rule.tpl == nil {
+		return json.Marshal(data)
+	}
+	var buf bytes.Buffer
+	err := rule.tpl.Execute(&buf, data)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x6f42b9d1 alert rule(%s) render body fail:%s", rule.Name, err))
+	}
+	return buf.Bytes(), nil
+}
+
+func (ae *alertEngineT) post(rule *AlertRuleT, body []byte) (int, error) {
+	req, err := http.NewRequest(rule.Method, rule.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range rule.Headers {
+		req.Header.Set(k, v)
+	}
+	rsp, err := ae.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return rsp.StatusCode, errors.New(fmt.Sprintf("0x28c5e7f4 webhook status %d", rsp.StatusCode))
+	}
+	return rsp.StatusCode, nil
+}
+
+// AlertHistoryQueryT 零值的字段不参与过滤
+type AlertHistoryQueryT struct {
+	Rule   string
+	Uuid   string
+	Status string
+	Since  time.Time
+	Limit  int
+}
+
+// historyGet 新的在前
+func (ae *alertEngineT) historyGet(q *AlertHistoryQueryT) []AlertFireT {
+	lst := make([]AlertFireT, 0)
+	if ae == nil {
+		return lst
+	}
+
+	ae.mtx.Lock()
+	defer ae.mtx.Unlock()
+
+	for i := len(ae.history) - 1; i >= 0 && len(lst) < q.Limit; i-- {
+		fire := ae.history[i]
+		if len(q.Rule) > 0 && fire.Data.Rule != q.Rule {
+			continue
+		}
+		if len(q.Uuid) > 0 && fire.Data.Uuid != q.Uuid {
+			continue
+		}
+		if len(q.Status) > 0 && fire.Status != q.Status {
+			continue
+		}
+		if !q.Since.IsZero() && fire.Data.TS.Before(q.Since) {
+			continue
+		}
+		lst = append(lst, *fire)
+	}
+	return lst
+}
+
+// AlertInit 配置了规则文件时启用告警
+func AlertInit(cfg *AlertCfgT) {
+	ae, err := newAlertEngine(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	alertEngine = ae
+}
+
+// AlertHistoryGet 查询触发记录，参数: rule、uuid、status、since、limit
+func AlertHistoryGet(c *gin.Context) {
+	q := &AlertHistoryQueryT{Rule: c.Query("rule"), Uuid: c.Query("uuid"), Status: c.Query("status"), Limit: EVENT_QUERY_LIMIT_DEFAULT}
+	var err error
+	if arg := c.Query("since"); len(arg) > 0 {
+		q.Since, err = parseEventTime(arg)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, &AdminRspT{Err: fmt.Sprintf("0x4c0a8e17 invalid since(%s)", arg)})
+			return
+		}
+	}
+	if arg := c.Query("limit"); len(arg) > 0 {
+		q.Limit, err = strconv.Atoi(arg)
+		if err != nil || q.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, &AdminRspT{Err: fmt.Sprintf("0x7e61b5a9 invalid limit(%s)", arg)})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, alertEngine.historyGet(q))
+}
+
+// AdminAlertReload 重新加载告警规则，返回加载后的规则名
+func AdminAlertReload(c *gin.Context) {
+	if alertEngine == nil || len(alertEngine.cfg.File) == 0 {
+		c.JSON(http.StatusBadRequest, &AdminRspT{Err: "0x5a93c2e0 alert not enabled"})
+		return
+	}
+	ruleLst, err := alertEngine.reload()
+	if err != nil {
+		log.Printf("ERROR 0x13e8f4d6 reload alert rule fail:%s", err)
+		c.JSON(http.StatusBadRequest, &AdminRspT{Err: err.Error()})
+		return
+	}
+
+	nameLst := make([]string, 0, len(ruleLst))
+	for _, rule := range ruleLst {
+		nameLst = append(nameLst, rule.Name)
+	}
+	c.JSON(http.StatusOK, nameLst)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAlertEngine(t *testing.T) {
+	var hit int32
+	bodyCh := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 第一次返回 500，验证重试
+		if atomic.AddInt32(&hit, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodyCh <- r.Header.Get("X-Token") + " " + string(body)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "alert.json")
+	os.WriteFile(path, []byte(`{"rules": [{
+		"name": "ackNull", "types": ["PINGACKNULL"], "roles": ["Repeater"],
+		"count": 3, "window": "10m", "silence": "30m",
+		"url": "`+srv.URL+`", "headers": {"X-Token": "t"},
+		"body": "{{.Rule}} {{.Uuid}} {{.Count}} {{.Event}}"
+	}]}`), 0644)
+
+	ae, err := newAlertEngine(&AlertCfgT{File: path, Timeout: DurationT(time.Second), Retry: 2, RetryWait: DurationT(time.Millisecond), History: 10})
+	if err != nil {
+		t.Fatalf("0x0b7e4d21 new alert engine:%s", err)
+	}
+	doneCh := make(chan *AlertFireT, 10)
+	ae.sendDone = func(fire *AlertFireT) { doneCh <- fire }
+
+	now := time.Now()
+	event := func(uuid string, eType int, role int, after time.Duration) {
+		ae.observe(&EventItemDBT{Uuid: uuid, RoleType: role, EType: eType, TS: now.Add(after)})
+	}
+	event("uuid-a", 1001, 1000, 0)
+	event("uuid-a", 1000, 1000, time.Minute)    // 类型不匹配
+	event("uuid-b", 1001, 1000, time.Minute)    // 其他 node
+	event("uuid-a", 1001, 1, time.Minute)       // 角色不匹配
+	event("uuid-a", 1001, 1000, time.Minute*11) // 第一条已在窗口外
+	event("uuid-a", 1001, 1000, time.Minute*12)
+	if len(ae.historyGet(&AlertHistoryQueryT{Limit: 10})) != 0 {
+		t.Fatalf("0x5d3a91f6 fired before threshold")
+	}
+	event("uuid-a", 1001, 1000, time.Minute*13)
+
+	fire := <-doneCh
+	if fire.Status != ALERT_STATUS_SENT || fire.Attempts != 2 || fire.Code != http.StatusOK {
+		t.Fatalf("0x2e8c07a4 fire:%+v", fire)
+	}
+	if body := <-bodyCh; body != "t ackNull uuid-a 3 PINGACKNULL" {
+		t.Fatalf("0x6a41f2c9 webhook body:%s", body)
+	}
+
+	// 静默期内不重复触发，静默期后再次触发
+	for i := 0; i < 3; i++ {
+		event("uuid-a", 1001, 1000, time.Minute*(20+time.Duration(i)))
+	}
+	for i := 0; i < 3; i++ {
+		event("uuid-a", 1001, 1000, time.Minute*(50+time.Duration(i)))
+	}
+	<-doneCh
+	lst := ae.historyGet(&AlertHistoryQueryT{Uuid: "uuid-a", Limit: 10})
+	if len(lst) != 2 || lst[0].Id != 2 || lst[0].Data.TS != now.Add(time.Minute*52) {
+		t.Fatalf("0x1f5b6e80 history:%+v", lst)
+	}
+	if lst = ae.historyGet(&AlertHistoryQueryT{Rule: "other", Limit: 10}); len(lst) != 0 {
+		t.Fatalf("0x73c0a9d2 history rule filter:%d", len(lst))
+	}
+
+	os.WriteFile(path, []byte(`{"rules": [{"name": "x", "url": "", "count": 1, "window": "1m"}]}`), 0644)
+	if _, err = ae.reload(); err == nil || len(ae.ruleLst) != 1 {
+		t.Fatalf("0x48d2b6e5 invalid rule accepted")
+	}
+}
+
+func TestAlertRepeaterSubject(t *testing.T) {
+	NodeMgrInit(NewMemStore(), configDefault())
+	t.Cleanup(nodeMgr.stopReaper)
+	mgr := nodeMgr
+	if err := mgr.reservationSet(&ReservationT{Uuid: "uuid-r", SubId: 150, RoleType: 1000}); err != nil {
+		t.Fatalf("0x3a9c51e7 reserve fail:%v", err)
+	}
+	if _, _, err := mgr.bootNode("uuid-r", "1.1.1.2", "v1", 0); err != nil {
+		t.Fatalf("0x7d04b2c8 boot fail:%v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "alert.json")
+	os.WriteFile(path, []byte(`{"rules": [{
+		"name": "ackNull", "types": ["PINGACKNULL"], "roles": ["Repeater"],
+		"count": 3, "window": "10m", "silence": "30m", "url": "http://127.0.0.1:1"
+	}]}`), 0644)
+	ae, err := newAlertEngine(&AlertCfgT{File: path, Timeout: DurationT(time.Second), Retry: 0, RetryWait: DurationT(time.Millisecond), History: 10})
+	if err != nil {
+		t.Fatalf("0x1e6f93a4 new alert engine:%s", err)
+	}
+	doneCh := make(chan *AlertFireT, 10)
+	ae.sendDone = func(fire *AlertFireT) { doneCh <- fire }
+	old := alertEngine
+	alertEngine = ae
+	t.Cleanup(func() { alertEngine = old })
+
+	// 三个客户端各报告一次 uuid-r 不回应，按 uuid-r 计数后触发
+	now := time.Now()
+	report := func(client string, after time.Duration) {
+		e := &EventItemDBT{Uuid: client, IP: "2.2.2.2", RoleType: 1, EType: 1001, EMsg: "ping 1.1.1.2 ack null", TS: now.Add(after)}
+		mgr.dataMtx.Lock()
+		subjectLst := mgr.recordAbnormal(client, e.EMsg, e.TS)
+		mgr.dataMtx.Unlock()
+		mgr.recordEvent(e, subjectLst...)
+	}
+	report("client-a", 0)
+	report("client-b", time.Minute)
+	if len(ae.historyGet(&AlertHistoryQueryT{Limit: 10})) != 0 {
+		t.Fatalf("0x52b8e0d6 fired before threshold")
+	}
+	report("client-c", time.Minute*2)
+	fire := <-doneCh
+	if fire.Data.Uuid != "uuid-r" || fire.Data.Role != "Repeater" || fire.Data.Reporter != "client-c" || fire.Data.Count != 3 {
+		t.Fatalf("0x0f47a3bd fire:%+v", fire.Data)
+	}
+
+	// 静默按 repeater 计，换其他客户端报告也不重复触发
+	for _, client := range []string{"client-d", "client-e", "client-f"} {
+		report(client, time.Minute*5)
+	}
+	if lst := ae.historyGet(&AlertHistoryQueryT{Uuid: "uuid-r", Limit: 10}); len(lst) != 1 {
+		t.Fatalf("0x6c2d18f5 silence per repeater:%d", len(lst))
+	}
+
+	// 退出时停止后不再触发
+	ae.stop()
+	for _, client := range []string{"client-a", "client-b", "client-c"} {
+		report(client, time.Hour)
+	}
+	if lst := ae.historyGet(&AlertHistoryQueryT{Limit: 10}); len(lst) != 1 {
+		t.Fatalf("0x39e0c5d7 fired after stop:%d", len(lst))
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"sort"
+	"time"
+)
+
+// RepeaterNodeCfgT 单个 repeater 的权重和容量，优先于 keepalive 上报的值
+type RepeaterNodeCfgT struct {
+	Weight   int `json:"weight"`
+	Capacity int `json:"capacity"`
+}
+
+// clientAssignT 分配给某个客户端的 repeater
+type clientAssignT struct {
+	repeaterLst []string // repeater.uuid，按返回顺序
+	ts          time.Time
+}
+
+// repeaterWeight 生效的权重和容量：配置 > keepalive 上报 > 默认值，capacity 0 表示不限
+func (mgr *nodeMgrT) repeaterWeight(node *NodeT) (weight int, capacity int) {
+	weight, capacity = mgr.repeater.Weight, mgr.repeater.Capacity
+	if node.Weight > 0 {
+		weight = node.Weight
+	}
+	if node.Capacity > 0 {
+		capacity = node.Capacity
+	}
+	if cfg, ok := mgr.repeater.Nodes[node.Uuid]; ok {
+		if cfg.Weight > 0 {
+			weight = cfg.Weight
+		}
+		if cfg.Capacity > 0 {
+			capacity = cfg.Capacity
+		}
+	}
+	return weight, capacity
+}
+
+// reportWeight 记录 repeater 在 boot/keepalive 中上报的权重和容量
+func (mgr *nodeMgrT) reportWeight(uuid string, rpt *proto.Node) {
+	if rpt == nil {
+		return
+	}
+	okW, okC := rpt.Weight != nil, rpt.Capacity != nil
+	if !okW && !okC {
+		return
+	}
+
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	node, ok := mgr.nodeUuidMap[uuid]
+	if !ok {
+		return
+	}
+	if okW {
+		node.Weight = int(rpt.GetWeight())
+	}
+	if okC {
+		node.Capacity = int(rpt.GetCapacity())
+	}
+	mgr.refreshRepeaterVer(time.Now())
+}
+
+// unassign 释放客户端之前的分配，调用方需持有 dataMtx
+func (mgr *nodeMgrT) unassign(client string) {
+	old, ok := mgr.assignMap[client]
+	if !ok {
+		return
+	}
+	for _, uuid := range old.repeaterLst {
+		mgr.assignCntMap[uuid]--
+		if mgr.assignCntMap[uuid] <= 0 {
+			delete(mgr.assignCntMap, uuid)
+		}
+	}
+	delete(mgr.assignMap, client)
+}
+
+// unassignRepeater 释放分配到该 repeater 的客户端，客户端再次请求时重新挑选，调用方需持有 dataMtx
+func (mgr *nodeMgrT) unassignRepeater(uuid string) {
+	for client, assign := range mgr.assignMap {
+		for _, id := range assign.repeaterLst {
+			if id == uuid {
+				mgr.unassign(client)
+				break
+			}
+		}
+	}
+}
+
+// assignRepeater 为客户端挑选 count 个 repeater，挑选方式见 repeater.select
+// 客户端地址所在 region 优先的 repeater 在前，容量已满的只在不足 count 时补充，保证列表不为空；
+// 不能使用 ipv6 的客户端(见 clientIPv6)不挑选仅有 ipv6 的 repeater
+func (mgr *nodeMgrT) assignRepeater(client, clientIP string, now time.Time) []NodeT {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	candLst := mgr.repeaterCand(now)
+	mgr.checkRepeaterVer(candLst)
+	if !mgr.clientIPv6(client, clientIP) {
+		candLst = ipv4Cand(candLst)
+	}
+	mgr.preferRegion(clientIP, candLst)
+	count := mgr.repeater.Count
+	if count <= 0 || count > len(candLst) {
+		count = len(candLst)
+	}
+
+	var lst []NodeT
+	if mgr.repeater.Select == REPEATER_SELECT_HASH {
+		lst = mgr.pickByHash(client, candLst, count)
+	} else {
+		if lst = mgr.keepAssigned(client, candLst, count); lst != nil {
+			mgr.assignMap[client].ts = now
+			return mgr.fillAssigned(lst)
+		}
+		lst = mgr.pickByLoad(candLst, count)
+	}
+
+	mgr.unassign(client)
+	assign := &clientAssignT{ts: now}
+	for _, node := range lst {
+		assign.repeaterLst = append(assign.repeaterLst, node.Uuid)
+		mgr.assignCntMap[node.Uuid]++
+	}
+	if len(lst) > 0 {
+		mgr.assignMap[client] = assign
+	}
+
+	return mgr.fillAssigned(lst)
+}
+
+// keepAssigned 之前分配的仍在候选中、未超出容量且 region 优先级与可选的最优 count 个相同时沿用，
+// 否则返回 nil，调用方需持有 dataMtx
+func (mgr *nodeMgrT) keepAssigned(client string, candLst []*repeaterCandT, count int) []NodeT {
+	old, ok := mgr.assignMap[client]
+	if !ok || len(old.repeaterLst) != count || count == 0 {
+		return nil
+	}
+
+	candMap := make(map[string]*repeaterCandT)
+	preferLst := make([]int, 0, len(candLst))
+	for _, cand := range candLst {
+		candMap[cand.node.Uuid] = cand
+		preferLst = append(preferLst, cand.prefer)
+	}
+	sort.Ints(preferLst)
+	best := 0
+	for _, prefer := range preferLst[:count] {
+		best += prefer
+	}
+	lst := make([]NodeT, 0, count)
+	for _, uuid := range old.repeaterLst {
+		cand, exist := candMap[uuid]
+		if !exist {
+			return nil
+		}
+		best -= cand.prefer
+		// 自己占用的一个名额不计入
+		_, capacity := mgr.repeaterWeight(&cand.node)
+		if capacity > 0 && mgr.assignCntMap[uuid] > capacity {
+			return nil
+		}
+		lst = append(lst, cand.node)
+	}
+	if best < 0 {
+		return nil
+	}
+	return lst
+}
+
+// pickByLoad 按 region 优先级、(已分配数+1)/权重 从小到大挑选，返回时按 region 优先级、健康程度排序，调用方需持有 dataMtx
+func (mgr *nodeMgrT) pickByLoad(candLst []*repeaterCandT, count int) []NodeT {
+	type loadT struct {
+		node   *NodeT
+		prefer int
+		rank   int // 健康排序中的位置
+		full   bool
+		load   float64
+	}
+	loadLst := make([]*loadT, 0, len(candLst))
+	for i, cand := range candLst {
+		weight, capacity := mgr.repeaterWeight(&cand.node)
+		if weight <= 0 {
+			weight = 1
+		}
+		assigned := mgr.assignCntMap[cand.node.Uuid]
+		loadLst = append(loadLst, &loadT{
+			node:   &cand.node,
+			prefer: cand.prefer,
+			rank:   i,
+			full:   capacity > 0 && assigned >= capacity,
+			load:   float64(assigned+1) / float64(weight),
+		})
+	}
+	sort.SliceStable(loadLst, func(i, j int) bool {
+		a, b := loadLst[i], loadLst[j]
+		if a.full != b.full {
+			return !a.full
+		}
+		if a.prefer != b.prefer {
+			return a.prefer < b.prefer
+		}
+		if a.load != b.load {
+			return a.load < b.load
+		}
+		return a.rank < b.rank
+	})
+
+	pickLst := loadLst[:count]
+	sort.SliceStable(pickLst, func(i, j int) bool {
+		if pickLst[i].prefer != pickLst[j].prefer {
+			return pickLst[i].prefer < pickLst[j].prefer
+		}
+		return pickLst[i].rank < pickLst[j].rank
+	})
+
+	lst := make([]NodeT, 0, count)
+	for _, l := range pickLst {
+		lst = append(lst, *l.node)
+	}
+	return lst
+}
+
+// fillAssigned 填充返回值中的生效权重、容量和已分配数，调用方需持有 dataMtx
+func (mgr *nodeMgrT) fillAssigned(lst []NodeT) []NodeT {
+	for i := range lst {
+		lst[i].Weight, lst[i].Capacity = mgr.repeaterWeight(&lst[i])
+		lst[i].Assigned = mgr.assignCntMap[lst[i].Uuid]
+	}
+	return lst
+}
+
+// expireAssign 删除超过 assignTtl 未再请求的客户端分配，调用方需持有 dataMtx
+func (mgr *nodeMgrT) expireAssign(now time.Time) {
+	for client, assign := range mgr.assignMap {
+		if now.Sub(assign.ts) > time.Duration(mgr.repeater.AssignTTL) {
+			mgr.unassign(client)
+		}
+	}
+}
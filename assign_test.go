@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	pb "google.golang.org/protobuf/proto"
+	"testing"
+	"time"
+)
+
+func TestAssignRepeater(t *testing.T) {
+	cfg := configDefault()
+	cfg.Repeater.Select = REPEATER_SELECT_LOAD
+	cfg.Repeater.Count = 1
+	cfg.Repeater.Nodes = map[string]RepeaterNodeCfgT{"uuid-a": {Weight: 2}, "uuid-c": {Capacity: 1}}
+	NodeMgrInit(NewMemStore(), cfg)
+	t.Cleanup(nodeMgr.stopReaper)
+	mgr := nodeMgr
+	for i, uuid := range []string{"uuid-a", "uuid-b", "uuid-c"} {
+		if err := mgr.reservationSet(&ReservationT{Uuid: uuid, SubId: 150 + i, RoleType: 1000}); err != nil {
+			t.Fatalf("0xca585ed1 reserve fail:%v", err)
+		}
+		if _, _, err := mgr.bootNode(uuid, fmt.Sprintf("1.1.1.%d", i+1), "v1", 0); err != nil {
+			t.Fatalf("0x96c59692 boot fail:%v", err)
+		}
+	}
+
+	// keepalive 上报的权重，配置优先
+	rpt := &proto.Node{Ver: "v1", Role: proto.Role_Repeater}
+	rpt.Weight = pb.Uint64(5)
+	mgr.reportWeight("uuid-a", rpt)
+	mgr.reportWeight("uuid-b", rpt)
+	if w, _ := mgr.repeaterWeight(mgr.nodeUuidMap["uuid-a"]); w != 2 {
+		t.Fatalf("0x2d6f8e13 config weight not preferred:%d", w)
+	}
+	rpt.Weight = pb.Uint64(1)
+	mgr.reportWeight("uuid-b", rpt)
+	if w, _ := mgr.repeaterWeight(mgr.nodeUuidMap["uuid-b"]); w != 1 {
+		t.Fatalf("0x6a1b0c74 reported weight error:%d", w)
+	}
+
+	now := time.Now()
+	first := ""
+	for i := 0; i < 8; i++ {
+		lst := mgr.assignRepeater(fmt.Sprintf("client-%d", i), "", now)
+		if len(lst) != 1 {
+			t.Fatalf("0x4c97e2d0 assign len:%d", len(lst))
+		}
+		if i == 0 {
+			first = lst[0].Uuid
+		}
+	}
+	cntA, cntB, cntC := mgr.assignCntMap["uuid-a"], mgr.assignCntMap["uuid-b"], mgr.assignCntMap["uuid-c"]
+	if cntA+cntB+cntC != 8 || cntC != 1 || cntA <= cntB {
+		t.Fatalf("0x19f3c8a6 unbalanced a:%d, b:%d, c:%d", cntA, cntB, cntC)
+	}
+
+	// 再次请求沿用之前的分配
+	before := mgr.assignCntMap[first]
+	lst := mgr.assignRepeater("client-0", "", now)
+	if lst[0].Uuid != first || mgr.assignCntMap[first] != before || lst[0].Assigned != before {
+		t.Fatalf("0x5e02a7b9 assignment not sticky")
+	}
+	for _, node := range NodeGetAll() {
+		if node.Assigned != mgr.assignCntMap[node.Uuid] {
+			t.Fatalf("0x7b34d1e5 monitor assigned error")
+		}
+	}
+
+	mgr.expireAssign(now.Add(time.Duration(mgr.repeater.AssignTTL) + time.Second))
+	if len(mgr.assignMap) != 0 || len(mgr.assignCntMap) != 0 {
+		t.Fatalf("0x03c8f6a2 assignment not expired")
+	}
+}
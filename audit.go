@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ADMIN_ACTOR_KEY = "adminActor" // gin.Context 中认证通过的操作者
+
+	adminTokenMinLen    = 16
+	auditLimitDefault   = 100
+	auditLimitMax       = 1000
+	adminAuthHeaderType = "Bearer "
+)
+
+// AdminCfgT 管理接口(/v1/admin)的认证
+type AdminCfgT struct {
+	Tokens   map[string]string `json:"tokens"`   // token->操作者，为空时拒绝所有管理请求
+	Insecure bool              `json:"insecure"` // tokens 为空时不认证，仅用于调试
+}
+
+func (cfg *AdminCfgT) check() error {
+	for token, actor := range cfg.Tokens {
+		if len(token) < adminTokenMinLen || len(actor) == 0 {
+			return errors.New(fmt.Sprintf("0x3c0e9a57 invalid admin token of actor(%s), token at least %d chars", actor, adminTokenMinLen))
+		}
+	}
+	return nil
+}
+
+// AdminAuth 校验 Authorization: Bearer <token>，通过后记录操作者
+func AdminAuth(cfg *AdminCfgT) gin.HandlerFunc {
+	if len(cfg.Tokens) == 0 && cfg.Insecure {
+		log.Printf("WARN 0x5e2b7d90 admin api without authentication(admin.insecure), set admin.tokens")
+		return func(c *gin.Context) {}
+	}
+	if len(cfg.Tokens) == 0 {
+		log.Printf("WARN 0x2d6f0b3e admin api disabled, set admin.tokens")
+	}
+
+	tokenMap := cfg.Tokens
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), adminAuthHeaderType)
+		for t, actor := range tokenMap {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				c.Set(ADMIN_ACTOR_KEY, actor)
+				return
+			}
+		}
+		log.Printf("WARN 0x1f7a4c36 admin %s %s unauthorized, ip:%s", c.Request.Method, c.Request.URL.Path, c.RemoteIP())
+		c.AbortWithStatusJSON(http.StatusUnauthorized, &AdminRspT{Err: "0x6a93e1d5 unauthorized"})
+	}
+}
+
+// AuditDetailT 审计记录的详情: 请求参数及变更前后的 node
+type AuditDetailT struct {
+	Req    interface{} `json:"req,omitempty"`
+	Before *NodeT      `json:"before,omitempty"`
+	After  *NodeT      `json:"after,omitempty"`
+}
+
+// audit 记录一次管理操作，失败的操作也记录
+func (mgr *nodeMgrT) audit(c *gin.Context, action, uuid string, detail *AuditDetailT, err error) {
+	a := &AuditT{
+		TS:     time.Now(),
+		Actor:  c.GetString(ADMIN_ACTOR_KEY),
+		IP:     c.RemoteIP(),
+		Action: action,
+		Uuid:   uuid,
+	}
+	buf, _ := json.Marshal(detail)
+	a.Detail = string(buf)
+	if err != nil {
+		a.Err = err.Error()
+	}
+	errAudit := mgr.store.InsertAudit(a)
+	if errAudit != nil {
+		log.Printf("ERROR 0x0d58f3b2 save audit fail:%s", errAudit)
+	}
+	log.Printf("LOG 0x47b1c6e0 admin(%s) %s uuid(%s) err:%v", a.Actor, action, uuid, err)
+}
+
+// AdminAuditGet ?uuid=&action=&limit=，新的在前
+func AdminAuditGet(c *gin.Context) {
+	q := &AuditQueryT{Uuid: c.Query("uuid"), Action: c.Query("action"), Limit: auditLimitDefault}
+	if s := c.Query("limit"); len(s) > 0 {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, &AdminRspT{Err: fmt.Sprintf("0x2e8b4f71 invalid limit(%s)", s)})
+			return
+		}
+		q.Limit = limit
+	}
+	if q.Limit > auditLimitMax {
+		q.Limit = auditLimitMax
+	}
+
+	lst, err := nodeMgr.store.SelectAudit(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, &AdminRspT{Err: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, lst)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 节点消息的校验方式
+const (
+	AUTH_MODE_OFF     = "off"     // 不校验，不签发密钥
+	AUTH_MODE_LOG     = "log"     // 签发密钥并校验，失败只记录安全事件
+	AUTH_MODE_ENFORCE = "enforce" // 校验失败的消息返回 401
+
+	AUTH_SIGN_HEADER = "X-Node-Sign" // hex(HMAC-SHA256(secret, Ts + "." + body))
+
+	authSecretLen = 32
+)
+
+// authSeenT 窗口内已接受的消息，同一 uuid 的 Ts 不得重复
+type authSeenT struct {
+	uuid string
+	ts   int64
+}
+
+// AuthCfgT 节点消息(EventPost)的签名校验
+type AuthCfgT struct {
+	Mode         string    `json:"mode"`         // off(默认), log, enforce
+	ReplayWindow DurationT `json:"replayWindow"` // 消息 Ts 与服务器时间相差超过该值的拒绝
+	EnrollCIDR   []string  `json:"enrollCidr"`   // 管理员认可的地址段，从这些地址 boot 的 node 才签发密钥
+	GraceUntil   time.Time `json:"graceUntil"`   // 迁移截止时间，之前开启校验前已有的 node 不带签名的消息也接受
+}
+
+func (cfg *AuthCfgT) check() error {
+	if cfg.Mode != AUTH_MODE_OFF && cfg.Mode != AUTH_MODE_LOG && cfg.Mode != AUTH_MODE_ENFORCE {
+		return errors.New(fmt.Sprintf("0x3ba8e1d6 invalid auth mode(%s)", cfg.Mode))
+	}
+	if cfg.ReplayWindow <= 0 {
+		return errors.New(fmt.Sprintf("0x70c5f29a invalid auth replayWindow(%s)", time.Duration(cfg.ReplayWindow)))
+	}
+	_, err := cfg.enrollNetLst()
+	return err
+}
+
+func (cfg *AuthCfgT) enrollNetLst() ([]*net.IPNet, error) {
+	var netLst []*net.IPNet
+	for _, s := range cfg.EnrollCIDR {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("0x0c9e7a52 invalid auth enrollCidr(%s)", s))
+		}
+		netLst = append(netLst, ipNet)
+	}
+	return netLst, nil
+}
+
+// authSign 签名的内容为十进制的 Ts、"."、protobuf 原始 body
+func authSign(secret string, ts int64, body []byte) string {
+	key, _ := hex.DecodeString(secret)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyNode 校验节点消息，调用方需持有 dataMtx
+// 不带签名的消息只接受：没有密钥或密钥未确认(还没有收到过带签名的消息)的 uuid 可签发密钥(见 authIssuable)、
+// 带有效 token 或进入待审批队列(不分配子网号)的 STARTED，及迁移期内开启校验前已有的 node 的消息
+func (mgr *nodeMgrT) verifyNode(ip string, msg *proto.MsgEventPost, body []byte, sign string, now time.Time) error {
+	uuid := msg.GetMachine().GetUUID()
+	cred, ok := mgr.credMap[uuid]
+	if len(sign) == 0 {
+		if ok && !cred.Confirmed.IsZero() {
+			return errors.New(fmt.Sprintf("0x6e1b09c4 uuid(%s) missing signature", uuid))
+		}
+		if msg.GetEvent() == proto.Event_STARTED && mgr.authStartable(uuid, ip, msg.GetToken(), now) {
+			return nil
+		}
+		if _, legacy := mgr.authLegacyMap[uuid]; legacy && now.Before(mgr.auth.GraceUntil) {
+			return nil
+		}
+		return errors.New(fmt.Sprintf("0x2d7a5f10 uuid(%s) not enrolled", uuid))
+	}
+	if !ok {
+		return errors.New(fmt.Sprintf("0x3f58c0a7 uuid(%s) signed but not enrolled", uuid))
+	}
+	ts := time.UnixMilli(msg.GetTs())
+	skew := now.Sub(ts)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > time.Duration(mgr.auth.ReplayWindow) {
+		return errors.New(fmt.Sprintf("0x5c39e7a2 uuid(%s) ts(%s) outside replay window(%s)",
+			uuid, ts.Format(time.RFC3339), time.Duration(mgr.auth.ReplayWindow)))
+	}
+	expect, err := hex.DecodeString(authSign(cred.Secret, msg.GetTs(), body))
+	got, errSign := hex.DecodeString(sign)
+	if err != nil || errSign != nil || !hmac.Equal(expect, got) {
+		return errors.New(fmt.Sprintf("0x18f4d6b3 uuid(%s) bad signature", uuid))
+	}
+
+	// 签名正确后才记录，避免伪造的消息占用
+	mgr.pruneSeen(now)
+	seen := authSeenT{uuid: uuid, ts: msg.GetTs()}
+	if _, dup := mgr.seenMap[seen]; dup {
+		return errors.New(fmt.Sprintf("0x7b2e05c8 uuid(%s) ts(%d) replayed", uuid, msg.GetTs()))
+	}
+	mgr.seenMap[seen] = ts
+
+	// 第一条带签名的消息确认 node 已收到密钥，之后不再下发
+	if cred.Confirmed.IsZero() {
+		c := *cred
+		c.Confirmed = now
+		err = mgr.store.UpsertCredential(&c)
+		if err != nil {
+			log.Printf("ERROR 0x2a6d91f4 confirm credential fail:%s", err)
+		} else {
+			mgr.credMap[uuid] = &c
+			delete(mgr.authLegacyMap, uuid)
+		}
+	}
+	return nil
+}
+
+// authStartable 未确认密钥的 uuid 可不带签名 STARTED，调用方需持有 dataMtx
+func (mgr *nodeMgrT) authStartable(uuid, ip, token string, now time.Time) bool {
+	if mgr.authIssuable(uuid, ip, now) {
+		return true
+	}
+	if len(token) > 0 {
+		if _, err := mgr.checkEnrollToken(uuid, token, now); err == nil {
+			return true
+		}
+	}
+	return mgr.enroll.Approval && !mgr.enrollKnown(uuid)
+}
+
+// authIssuable 可向 ip 签发 uuid 的密钥: ip 属于 enrollCidr、审批通过(或使用过 token)时的地址为 ip，
+// 或迁移期内开启校验前已有的 node 从其登记的地址 boot，调用方需持有 dataMtx
+func (mgr *nodeMgrT) authIssuable(uuid, ip string, now time.Time) bool {
+	if mgr.enrollApprovedAt(uuid, ip) {
+		return true
+	}
+	if addr := net.ParseIP(ip); addr != nil {
+		for _, ipNet := range mgr.authNetLst {
+			if ipNet.Contains(addr) {
+				return true
+			}
+		}
+	}
+	legacy, ok := mgr.authLegacyMap[uuid]
+	return ok && now.Before(mgr.auth.GraceUntil) && (legacy.IPv4 == ip || legacy.IPv6 == ip)
+}
+
+// pruneSeen 丢弃 replayWindow 之外的记录(这些消息已因 Ts 被拒绝)，调用方需持有 dataMtx
+func (mgr *nodeMgrT) pruneSeen(now time.Time) {
+	window := time.Duration(mgr.auth.ReplayWindow)
+	if now.Sub(mgr.seenPrune) < window {
+		return
+	}
+	for seen, ts := range mgr.seenMap {
+		if now.Sub(ts) > window {
+			delete(mgr.seenMap, seen)
+		}
+	}
+	mgr.seenPrune = now
+}
+
+// authNode 校验失败时记录安全事件，返回是否接受该消息
+func (mgr *nodeMgrT) authNode(ip string, msg *proto.MsgEventPost, body []byte, sign string) bool {
+	if mgr.auth.Mode == AUTH_MODE_OFF {
+		return true
+	}
+
+	now := time.Now()
+	mgr.dataMtx.Lock()
+	err := mgr.verifyNode(ip, msg, body, sign, now)
+	mgr.dataMtx.Unlock()
+	if err == nil {
+		return true
+	}
+
+	log.Printf("WARN 0x0a6c3e95 reject %s from ip:%s, %s", msg.GetEvent(), ip, err)
+	mgr.recordEvent(&EventItemDBT{
+		Uuid:     msg.GetMachine().GetUUID(),
+		IP:       ip,
+		RoleType: int(msg.GetNode().GetRole()),
+		TS:       now,
+		Ver:      msg.GetNode().GetVer(),
+		EType:    EVENT_AUTH_REJECT,
+		EMsg:     fmt.Sprintf("%s %s", msg.GetEvent(), err),
+	})
+	return mgr.auth.Mode != AUTH_MODE_ENFORCE
+}
+
+// enrollNode boot 时签发密钥，不可签发(见 authIssuable)或密钥已确认的返回 ""
+// 确认前再次 boot 时重新下发同一密钥，避免响应丢失后 node 无法登记
+func (mgr *nodeMgrT) enrollNode(uuid, ip string) string {
+	if mgr.auth.Mode == AUTH_MODE_OFF {
+		return ""
+	}
+
+	now := time.Now()
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	cred, ok := mgr.credMap[uuid]
+	if ok && !cred.Confirmed.IsZero() {
+		return ""
+	}
+	if !mgr.authIssuable(uuid, ip, now) {
+		log.Printf("WARN 0x5a1d7e06 node.uuid(%s) ip:%s not allowed to enroll, secret not issued", uuid, ip)
+		return ""
+	}
+	if ok {
+		return cred.Secret
+	}
+	buf := make([]byte, authSecretLen)
+	_, err := rand.Read(buf)
+	if err != nil {
+		log.Printf("ERROR 0x4f2b8a17 gen secret fail:%s", err)
+		return ""
+	}
+	cred = &CredentialT{Uuid: uuid, Secret: hex.EncodeToString(buf), Created: now}
+	err = mgr.store.UpsertCredential(cred)
+	if err != nil {
+		log.Printf("ERROR 0x33e9c0d5 save credential fail:%s", err)
+		return ""
+	}
+	mgr.credMap[uuid] = cred
+	log.Printf("LOG 0x6b05f8a2 node.uuid(%s) enrolled", uuid)
+	return cred.Secret
+}
+
+// credentialDel 吊销密钥及登记审批，该 node 需从 enrollCidr 内、重新审批或使用 token 后才再次签发密钥
+func (mgr *nodeMgrT) credentialDel(uuid string) error {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	if _, ok := mgr.credMap[uuid]; !ok {
+		return errors.New(fmt.Sprintf("0x1c7e4b90 credential not found, uuid:%s", uuid))
+	}
+	return mgr.revokeNode(uuid)
+}
+
+// revokeNode 删除密钥及登记审批，调用方需持有 dataMtx
+func (mgr *nodeMgrT) revokeNode(uuid string) error {
+	err := mgr.store.DeleteCredentialByUuid(uuid)
+	if err != nil {
+		return err
+	}
+	delete(mgr.credMap, uuid)
+	delete(mgr.authLegacyMap, uuid)
+	if _, ok := mgr.enrollMap[uuid]; ok {
+		err = mgr.store.DeleteEnrollByUuid(uuid)
+		if err != nil {
+			return err
+		}
+		delete(mgr.enrollMap, uuid)
+	}
+	return nil
+}
+
+// CredentialInfoT 不含密钥
+type CredentialInfoT struct {
+	Uuid      string    `json:"uuid"`
+	Created   time.Time `json:"created"`
+	Confirmed time.Time `json:"confirmed,omitempty"`
+}
+
+func (mgr *nodeMgrT) credentialGetAll() []CredentialInfoT {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	lst := make([]CredentialInfoT, 0, len(mgr.credMap))
+	for _, cred := range mgr.credMap {
+		lst = append(lst, CredentialInfoT{Uuid: cred.Uuid, Created: cred.Created, Confirmed: cred.Confirmed})
+	}
+	sort.Slice(lst, func(i, j int) bool { return lst[i].Uuid < lst[j].Uuid })
+	return lst
+}
+
+func AdminCredentialGet(c *gin.Context) {
+	c.JSON(http.StatusOK, nodeMgr.credentialGetAll())
+}
+
+// AdminCredentialDel 吊销 node 的密钥
+func AdminCredentialDel(c *gin.Context) {
+	err := nodeMgr.credentialDel(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, &AdminRspT{Err: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, &AdminRspT{})
+}
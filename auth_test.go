@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	pb "google.golang.org/protobuf/proto"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthNode(t *testing.T) {
+	store := NewMemStore()
+	cfg := configDefault()
+	cfg.Auth.Mode = AUTH_MODE_ENFORCE
+	NodeMgrInit(store, cfg)
+	t.Cleanup(nodeMgr.stopReaper)
+	if err := nodeMgr.reservationSet(&ReservationT{Uuid: "uuid-a", SubId: 150, RoleType: 1000}); err != nil {
+		t.Fatalf("0x4afc7b48 reserve fail:%v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/event", EventPost)
+	post := func(event proto.Event, ts time.Time, secret string) *httptest.ResponseRecorder {
+		msg := &proto.MsgEventPost{
+			Event:   event,
+			Ts:      ts.UnixMilli(),
+			Machine: &proto.Machine{UUID: "uuid-a"},
+			Node:    &proto.Node{Ver: "v1", Role: proto.Role_Repeater},
+		}
+		body, _ := pb.Marshal(msg)
+		req := httptest.NewRequest(http.MethodPost, "/event", bytes.NewReader(body))
+		if len(secret) > 0 {
+			req.Header.Set(AUTH_SIGN_HEADER, authSign(secret, msg.Ts, body))
+		}
+		rsp := httptest.NewRecorder()
+		r.ServeHTTP(rsp, req)
+		return rsp
+	}
+	bootSecret := func(rsp *httptest.ResponseRecorder) string {
+		var bootRsp proto.MsgEventRsp
+		pb.Unmarshal(rsp.Body.Bytes(), &bootRsp)
+		return bootRsp.GetSecret()
+	}
+
+	// 未登记的不接受 KEEPALIVE，不在 enrollCidr 内的 STARTED 也拒绝
+	if rsp := post(proto.Event_KEEPALIVE, time.Now(), ""); rsp.Code != http.StatusUnauthorized {
+		t.Fatalf("0x4e7b2a19 unenrolled keepalive code:%d", rsp.Code)
+	}
+	if rsp := post(proto.Event_STARTED, time.Now(), ""); rsp.Code != http.StatusUnauthorized {
+		t.Fatalf("0x2c60f8b1 started outside enrollCidr code:%d", rsp.Code)
+	}
+
+	// httptest 的源地址为 192.0.2.1
+	nodeMgr.authNetLst, _ = (&AuthCfgT{EnrollCIDR: []string{"192.0.2.0/24"}}).enrollNetLst()
+	rsp := post(proto.Event_STARTED, time.Now(), "")
+	secret := bootSecret(rsp)
+	if rsp.Code != http.StatusOK || len(secret) != authSecretLen*2 {
+		t.Fatalf("0x1a9c5f63 enroll code:%d, secret:%s", rsp.Code, secret)
+	}
+	// 确认前再次 boot 重新下发同一密钥
+	if rsp = post(proto.Event_STARTED, time.Now(), ""); rsp.Code != http.StatusOK || bootSecret(rsp) != secret {
+		t.Fatalf("0x6d3b0e97 re-fetch code:%d", rsp.Code)
+	}
+
+	for _, c := range []struct {
+		event  proto.Event
+		ts     time.Time
+		secret string
+		code   int
+	}{
+		{proto.Event_KEEPALIVE, time.Now(), secret, http.StatusOK},
+		{proto.Event_STARTED, time.Now(), "", http.StatusUnauthorized}, // 确认后的 STARTED 也需签名
+		{proto.Event_KEEPALIVE, time.Now(), "00" + secret[2:], http.StatusUnauthorized},
+		{proto.Event_KEEPALIVE, time.Now().Add(-time.Minute * 6), secret, http.StatusUnauthorized},
+		{proto.Event_STARTED, time.Now().Add(time.Minute * 4), secret, http.StatusOK},
+	} {
+		if rsp = post(c.event, c.ts, c.secret); rsp.Code != c.code {
+			t.Fatalf("0x62d8e0b4 %s ts:%s code:%d, expect:%d", c.event, c.ts, rsp.Code, c.code)
+		}
+	}
+	// 确认后再次 boot 不重新下发密钥
+	if bootSecret(rsp) != "" {
+		t.Fatalf("0x0f35a7d2 secret issued again")
+	}
+	if cred, _ := store.LoadCredentialAll(); len(cred) != 1 || cred[0].Confirmed.IsZero() {
+		t.Fatalf("0x5e21c7a4 credential not confirmed")
+	}
+	// 窗口内重复的消息拒绝
+	ts := time.Now().Add(time.Second)
+	if rsp = post(proto.Event_KEEPALIVE, ts, secret); rsp.Code != http.StatusOK {
+		t.Fatalf("0x1e5a7c40 keepalive code:%d", rsp.Code)
+	}
+	if rsp = post(proto.Event_KEEPALIVE, ts, secret); rsp.Code != http.StatusUnauthorized {
+		t.Fatalf("0x48f0b2d6 replayed keepalive code:%d", rsp.Code)
+	}
+
+	page, _ := store.SelectEvent(&EventQueryT{Role: -1, Types: []int{EVENT_AUTH_REJECT}, Limit: 10})
+	if page.Total != 6 {
+		t.Fatalf("0x37c1e6a8 security event:%d", page.Total)
+	}
+
+	// 吊销后重新登记
+	if nodeMgr.credentialDel("uuid-a") != nil {
+		t.Fatalf("0x5b2f94c7 revoke fail")
+	}
+	rsp = post(proto.Event_STARTED, time.Now(), "")
+	if newSecret := bootSecret(rsp); len(newSecret) == 0 || newSecret == secret {
+		t.Fatalf("0x7d60a3e1 re-enroll secret:%s", newSecret)
+	}
+}
+
+func TestAuthLegacyGrace(t *testing.T) {
+	store := NewMemStore()
+	NodeMgrInit(store, configDefault())
+	t.Cleanup(nodeMgr.stopReaper)
+	if err := nodeMgr.reservationSet(&ReservationT{Uuid: "uuid-a", SubId: 150, RoleType: 1000}); err != nil {
+		t.Fatalf("0x78672162 reserve fail:%v", err)
+	}
+	if _, _, err := nodeMgr.bootNode("uuid-a", "192.0.2.1", "v1", 0); err != nil {
+		t.Fatalf("0x3c7e91a2 boot:%v", err)
+	}
+
+	// 开启校验前已有的 node 在迁移期内可不带签名，并从登记的地址领取密钥
+	cfg := configDefault()
+	cfg.Auth.Mode = AUTH_MODE_ENFORCE
+	cfg.Auth.GraceUntil = time.Now().Add(time.Hour)
+	NodeMgrInit(store, cfg)
+	t.Cleanup(nodeMgr.stopReaper)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/event", EventPost)
+	post := func(event proto.Event, remoteAddr string) *httptest.ResponseRecorder {
+		msg := &proto.MsgEventPost{
+			Event:   event,
+			Ts:      time.Now().UnixMilli(),
+			Machine: &proto.Machine{UUID: "uuid-a"},
+			Node:    &proto.Node{Ver: "v1", Role: proto.Role_Repeater},
+		}
+		body, _ := pb.Marshal(msg)
+		req := httptest.NewRequest(http.MethodPost, "/event", bytes.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		rsp := httptest.NewRecorder()
+		r.ServeHTTP(rsp, req)
+		return rsp
+	}
+
+	if rsp := post(proto.Event_KEEPALIVE, "198.51.100.1:1234"); rsp.Code != http.StatusOK {
+		t.Fatalf("0x0b84d6f3 legacy keepalive code:%d", rsp.Code)
+	}
+	rsp := post(proto.Event_STARTED, "198.51.100.1:1234")
+	var bootRsp proto.MsgEventRsp
+	pb.Unmarshal(rsp.Body.Bytes(), &bootRsp)
+	if bootRsp.GetSecret() != "" {
+		t.Fatalf("0x71f2a8c5 secret issued to unrecorded ip")
+	}
+	rsp = post(proto.Event_STARTED, "192.0.2.1:1234")
+	bootRsp.Reset()
+	pb.Unmarshal(rsp.Body.Bytes(), &bootRsp)
+	if len(bootRsp.GetSecret()) != authSecretLen*2 {
+		t.Fatalf("0x26e9b1d7 legacy enroll code:%d", rsp.Code)
+	}
+
+	// 迁移期结束后不带签名的拒绝
+	nodeMgr.auth.GraceUntil = time.Now().Add(-time.Second)
+	if rsp = post(proto.Event_KEEPALIVE, "192.0.2.1:1234"); rsp.Code != http.StatusUnauthorized {
+		t.Fatalf("0x58a3d0e6 keepalive after grace code:%d", rsp.Code)
+	}
+}
+
+func TestAuthEnrollBinding(t *testing.T) {
+	store := NewMemStore()
+	cfg := configDefault()
+	cfg.Auth.Mode = AUTH_MODE_ENFORCE
+	NodeMgrInit(store, cfg)
+	t.Cleanup(nodeMgr.stopReaper)
+	if err := nodeMgr.reservationSet(&ReservationT{Uuid: "uuid-a", SubId: 150, RoleType: 1000}); err != nil {
+		t.Fatalf("0x9b285549 reserve fail:%v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/event", EventPost)
+	boot := func(token, remoteAddr string) (int, string) {
+		msg := &proto.MsgEventPost{
+			Event:   proto.Event_STARTED,
+			Ts:      time.Now().UnixMilli(),
+			Machine: &proto.Machine{UUID: "uuid-a"},
+			Node:    &proto.Node{Ver: "v1", Role: proto.Role_Repeater},
+		}
+		msg.Token = token
+		body, _ := pb.Marshal(msg)
+		req := httptest.NewRequest(http.MethodPost, "/event", bytes.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		rsp := httptest.NewRecorder()
+		r.ServeHTTP(rsp, req)
+		var bootRsp proto.MsgEventRsp
+		pb.Unmarshal(rsp.Body.Bytes(), &bootRsp)
+		return rsp.Code, bootRsp.GetSecret()
+	}
+
+	// 未开启审批时用一次性 token 登记，密钥绑定到使用 token 的地址
+	if code, _ := boot("", "192.0.2.1:1234"); code != http.StatusUnauthorized {
+		t.Fatalf("0x6a1f3c08 started without token code:%d", code)
+	}
+	token, _, _ := nodeMgr.enrollTokenNew("uuid-a", 0, "", time.Now())
+	code, secret := boot(token, "192.0.2.1:1234")
+	if code != http.StatusOK || len(secret) != authSecretLen*2 {
+		t.Fatalf("0x2e7d90b5 token enroll code:%d, secret:%s", code, secret)
+	}
+	// 确认前从同一地址再次 boot 不需 token，其他地址拒绝
+	if code, again := boot("", "192.0.2.1:1234"); code != http.StatusOK || again != secret {
+		t.Fatalf("0x4b96e1d2 re-fetch code:%d", code)
+	}
+	if code, _ := boot("", "198.51.100.1:1234"); code != http.StatusUnauthorized {
+		t.Fatalf("0x13c8a7f4 re-fetch from other ip code:%d", code)
+	}
+	// token 只能用一次
+	if code, _ := boot(token, "198.51.100.1:1234"); code != http.StatusUnauthorized {
+		t.Fatalf("0x7f0b5d26 reused token code:%d", code)
+	}
+
+	// 开启审批时只向申请时的地址签发
+	nodeMgr.enroll.Approval = true
+	nodeMgr.enrollCheck("uuid-b", "192.0.2.2", "v1", "", time.Now())
+	now := time.Now()
+	nodeMgr.dataMtx.Lock()
+	queued := nodeMgr.authStartable("uuid-b", "198.51.100.1", "", now) && !nodeMgr.authIssuable("uuid-b", "192.0.2.2", now)
+	nodeMgr.dataMtx.Unlock()
+	if !queued {
+		t.Fatalf("0x58d2e4a9 pending uuid issuable")
+	}
+	if _, err := nodeMgr.enrollSet("uuid-b", ENROLL_STATUS_APPROVED, "", now); err != nil {
+		t.Fatalf("0x0e3a6b71 approve:%v", err)
+	}
+	nodeMgr.dataMtx.Lock()
+	defer nodeMgr.dataMtx.Unlock()
+	if !nodeMgr.authIssuable("uuid-b", "192.0.2.2", now) || nodeMgr.authStartable("uuid-b", "198.51.100.1", "", now) {
+		t.Fatalf("0x31f7c8d0 approved uuid not bound to ip")
+	}
+}
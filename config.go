@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CONFIG_PATH_DEFAULT = "./etc/nodeMgr.json"
+)
+
+// ConfigT 运行时配置，文件不存在时使用默认值
+type ConfigT struct {
+	SubnetPools []SubnetPoolCfgT `json:"subnetPools"` // 子网号地址池
+	Lease       LeaseCfgT        `json:"lease"`
+	IPv6        IPv6CfgT         `json:"ipv6"`
+	Store       StoreCfgT        `json:"store"`
+	Retention   RetentionCfgT    `json:"retention"`
+	Liveness    LivenessCfgT     `json:"liveness"`
+	Repeater    RepeaterCfgT     `json:"repeater"`
+	Region      RegionCfgT       `json:"region"`
+	Stream      StreamCfgT       `json:"stream"`
+	Alert       AlertCfgT        `json:"alert"`
+	Auth        AuthCfgT         `json:"auth"`
+	Enroll      EnrollCfgT       `json:"enroll"`
+	Admin       AdminCfgT        `json:"admin"`
+	Role        RoleCfgT         `json:"role"`
+	Pac         PacCfgT          `json:"pac"`
+	Drain       DrainCfgT        `json:"drain"`
+	Close       CloseCfgT        `json:"close"`
+	Shutdown    ShutdownCfgT     `json:"shutdown"`
+	Reconcile   bool             `json:"reconcile"` // 启动时修复冲突的数据(重复的 uuid/子网号等)，否则直接退出
+}
+
+type IPv6CfgT struct {
+	ULAPrefix string `json:"ulaPrefix"` // fdxx:xxxx:xxxx::/48，为空则不分配 ULA
+}
+
+// LeaseCfgT 子网号租约
+type LeaseCfgT struct {
+	Duration DurationT `json:"duration"` // 租约时长，boot/keepalive 时续约
+	Grace    DurationT `json:"grace"`    // 租约过期后子网号仍为该 uuid 保留的时长，之后才回收
+}
+
+// DurationT 配置文件中以 "30m"、"168h" 的形式填写
+type DurationT time.Duration
+
+func (d *DurationT) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = DurationT(v)
+	return nil
+}
+
+func (d DurationT) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func configDefault() *ConfigT {
+	cfg := &ConfigT{}
+	cfg.SubnetPools = []SubnetPoolCfgT{
+		{Name: "pac-default", Role: "Pac", Min: 20, Max: 49},
+		{Name: "repeater-default", Role: "Repeater", Min: 120, Max: 199},
+	}
+	cfg.Lease.Duration = DurationT(time.Minute * 30)
+	cfg.Lease.Grace = DurationT(time.Hour * 24 * 7)
+	cfg.Store.Driver = STORE_DRIVER_SQLITE
+	cfg.Store.Path = "./etc/nodeInfo.db"
+	cfg.Retention.Interval = DurationT(time.Hour)
+	cfg.Liveness.Keepalive = DurationT(time.Minute)
+	cfg.Liveness.SuspectMiss = 3
+	cfg.Liveness.Offline = DurationT(time.Minute * 10)
+	cfg.Liveness.Sweep = DurationT(time.Minute)
+	cfg.Repeater.AbnormalWindow = DurationT(time.Minute * 10)
+	cfg.Repeater.AbnormalMax = 3
+	cfg.Repeater.MinCount = 1
+	cfg.Repeater.Select = REPEATER_SELECT_HASH
+	cfg.Repeater.Count = 3
+	cfg.Repeater.Weight = 1
+	cfg.Repeater.AssignTTL = DurationT(time.Minute * 30)
+	cfg.Repeater.LongPollMax = DurationT(time.Minute)
+	cfg.Stream.Buffer = 256
+	cfg.Stream.Heartbeat = DurationT(time.Second * 30)
+	cfg.Alert.Timeout = DurationT(time.Second * 5)
+	cfg.Alert.Retry = 3
+	cfg.Alert.RetryWait = DurationT(time.Second)
+	cfg.Alert.History = 1000
+	cfg.Auth.Mode = AUTH_MODE_OFF
+	cfg.Auth.ReplayWindow = DurationT(time.Minute * 5)
+	cfg.Enroll.MaxPending = 1000
+	cfg.Enroll.TokenTTL = DurationT(time.Hour * 24)
+	// 默认不采纳 node 请求的角色，与之前的行为一致
+	cfg.Role.Order = []string{ROLE_SOURCE_OVERRIDE, ROLE_SOURCE_CIDR, ROLE_SOURCE_IP}
+	cfg.Pac.Local = "./etc/cnIP.cfg"
+	cfg.Pac.Out = "./etc/outIP.cfg"
+	cfg.Drain.Deadline = DurationT(time.Hour)
+	cfg.Drain.Max = DurationT(time.Hour * 24)
+	cfg.Close.Release = CLOSE_RELEASE_KEEP
+	cfg.Shutdown.Timeout = DurationT(time.Second * 30)
+	return cfg
+}
+
+// ConfigLoad 读取配置文件，未配置的字段沿用默认值
+func ConfigLoad(path string) (*ConfigT, error) {
+	cfg := configDefault()
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, errors.New(fmt.Sprintf("0x3f0b6c1e read config(%s) fail:%s", path, err))
+	}
+
+	err = json.Unmarshal(buf, cfg)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x6e2d91a4 parse config(%s) fail:%s", path, err))
+	}
+	if cfg.Lease.Duration <= 0 || cfg.Lease.Grace < 0 {
+		return nil, errors.New(fmt.Sprintf("0x52e8b0c9 invalid lease duration(%s) or grace(%s)",
+			time.Duration(cfg.Lease.Duration), time.Duration(cfg.Lease.Grace)))
+	}
+	err = cfg.Liveness.check()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Repeater.check()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Stream.check()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Alert.check()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Auth.check()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Enroll.check()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Admin.check()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Role.check()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Pac.check()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Drain.check()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Close.check()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Shutdown.check()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// parseRole "Pac"/"Repeater"(不区分大小写) -> proto.Role
+func parseRole(s string) (int, error) {
+	for name, val := range proto.Role_value {
+		if strings.EqualFold(name, s) {
+			return int(val), nil
+		}
+	}
+	return 0, errors.New(fmt.Sprintf("0x1d5a7e30 invalid role(%s)", s))
+}
+
+// parseEventType 事件类型名称(不区分大小写)或数值
+func parseEventType(s string) (int, error) {
+	if v, err := strconv.Atoi(s); err == nil {
+		return v, nil
+	}
+	for name, val := range proto.Event_value {
+		if strings.EqualFold(name, s) {
+			return int(val), nil
+		}
+	}
+	for val, name := range eventLocalName {
+		if strings.EqualFold(name, s) {
+			return val, nil
+		}
+	}
+	return 0, errors.New(fmt.Sprintf("0x5c3f08e2 invalid event type(%s)", s))
+}
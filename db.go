@@ -7,42 +7,147 @@ import (
 	"errors"
 	"fmt"
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/shankusu2017/proto_pb/go/proto"
 	"log"
+	"strings"
 	"time"
 )
 
-var (
-	dbHandle *sql.DB
-)
+// sqliteStoreT Store 的 sqlite 实现
+type sqliteStoreT struct {
+	db *sql.DB
+}
 
 // NetConfigT 网络参数
 type NetConfigT struct {
-	SubId    int       `json:SubId`
-	Uuid     string    `json:Uuid`
-	IP       string    `json:IP`
-	RoleType int       `json:RoleType`
-	TS       time.Time `json:TS`
-	Ver      string    `json:Ver`
+	SubId    int       `json:"SubId"`
+	Uuid     string    `json:"Uuid"`
+	IP       string    `json:"IP"`
+	IPv6     string    `json:"IPv6"`
+	RoleType int       `json:"RoleType"`
+	TS       time.Time `json:"TS"`
+	Ver      string    `json:"Ver"`
+}
+
+// LeaseT 子网号租约，回收后仍保留记录(Released)，便于该 uuid 再次上线时沿用原子网号
+type LeaseT struct {
+	Uuid     string    `json:"Uuid"`
+	SubId    int       `json:"SubId"`
+	RoleType int       `json:"RoleType"`
+	Expire   time.Time `json:"Expire"`
+	Released bool      `json:"Released"`
+	Closed   bool      `json:"Closed"` // node 上报了 CLOSED，再次 boot/ping 前为 offline
+}
+
+// ReservationT 静态预留：uuid 固定使用 SubId，不参与动态分配和回收
+type ReservationT struct {
+	Uuid     string `json:"uuid"`
+	SubId    int    `json:"subId"`
+	RoleType int    `json:"roleType"`
+	Note     string `json:"note,omitempty"`
+}
+
+// CredentialT node 签名消息的密钥，登记(boot)时签发，收到第一条带签名的消息后确认
+type CredentialT struct {
+	Uuid      string    `json:"uuid"`
+	Secret    string    `json:"secret"` // hex
+	Created   time.Time `json:"created"`
+	Confirmed time.Time `json:"confirmed,omitempty"` // 零值表示未确认，可重新下发
+}
+
+// EnrollT 未知 uuid 的登记申请，pending 的等待审批
+type EnrollT struct {
+	Uuid   string    `json:"uuid"`
+	Status string    `json:"status"` // pending, approved, rejected
+	IP     string    `json:"ip"`     // 最近一次申请的 ip
+	Ver    string    `json:"ver"`
+	First  time.Time `json:"first"` // 首次申请的时间
+	Last   time.Time `json:"last"`  // 最近一次申请或审批的时间
+	Count  int       `json:"count"` // 申请次数
+	Note   string    `json:"note,omitempty"`
+}
+
+// EnrollTokenT 一次性登记 token，只保存 sha256，使用后删除
+type EnrollTokenT struct {
+	Hash    string    `json:"hash"`
+	Uuid    string    `json:"uuid,omitempty"` // 非空时只能用于该 uuid
+	Expire  time.Time `json:"expire"`
+	Created time.Time `json:"created"`
+	Note    string    `json:"note,omitempty"`
+}
+
+// NodeAttrT 管理员设置的 node 属性，node 被驱逐时删除
+type NodeAttrT struct {
+	Uuid        string    `json:"uuid"`
+	Role        int       `json:"role,omitempty"` // 强制的角色，0 为按地址推断
+	Note        string    `json:"note,omitempty"`
+	Maintenance bool      `json:"maintenance,omitempty"`
+	Updated     time.Time `json:"updated"`
+
+	// 排空: DrainUntil 为零时未排空，到期自动取消
+	DrainSince    time.Time `json:"drainSince,omitempty"`
+	DrainUntil    time.Time `json:"drainUntil,omitempty"`
+	DrainAssigned int       `json:"drainAssigned,omitempty"` // 开始排空时分配到的客户端数
+
+	// pac 重新加载后保持的角色，地址变化或 migrate 后清除，PacPinRole 为 0 时未保持
+	PacPinRole int    `json:"pacPinRole,omitempty"`
+	PacPinIP   string `json:"pacPinIP,omitempty"`
+
+	// 最近一次 boot 请求的角色及决定角色的原因，重启后恢复到 node
+	ReqRole    int    `json:"reqRole,omitempty"`
+	RoleReason string `json:"roleReason,omitempty"`
+}
+
+// AuditT 一次管理操作
+type AuditT struct {
+	Id     int64     `json:"id"`
+	TS     time.Time `json:"ts"`
+	Actor  string    `json:"actor"` // 管理 token 对应的操作者，未开启认证时为空
+	IP     string    `json:"ip"`
+	Action string    `json:"action"`
+	Uuid   string    `json:"uuid,omitempty"`
+	Detail string    `json:"detail,omitempty"` // 请求参数及变更前后的值
+	Err    string    `json:"err,omitempty"`
+}
+
+// AuditQueryT 为空的条件不过滤
+type AuditQueryT struct {
+	Uuid   string
+	Action string
+	Limit  int
+}
+
+func (q *AuditQueryT) match(a *AuditT) bool {
+	return (len(q.Uuid) == 0 || a.Uuid == q.Uuid) && (len(q.Action) == 0 || a.Action == q.Action)
+}
+
+// pick lst 按 id 从小到大，返回过滤后新的在前的 limit 条
+func (q *AuditQueryT) pick(lst []*AuditT) []*AuditT {
+	retLst := make([]*AuditT, 0)
+	for i := len(lst) - 1; i >= 0 && len(retLst) < q.Limit; i-- {
+		if q.match(lst[i]) {
+			retLst = append(retLst, lst[i])
+		}
+	}
+	return retLst
 }
 
 type EventItemDBT struct {
-	Uuid     string    `json:Uuid`
-	IP       string    `json:IP`
-	RoleType int       `json:RoleType`
-	TS       time.Time `json:TS`
-	Ver      string    `json:Ver`
-	EType    int       `json:EType`
-	EMsg     string    `json:EMsg`
+	Id       int64     `json:"Id"` // 自增 id，分页游标
+	Uuid     string    `json:"Uuid"`
+	IP       string    `json:"IP"`
+	RoleType int       `json:"RoleType"`
+	TS       time.Time `json:"TS"`
+	Ver      string    `json:"Ver"`
+	EType    int       `json:"EType"`
+	EMsg     string    `json:"EMsg"`
 }
 
-func openDB(dbPath string) *sql.DB {
+func openDB(dbPath string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
-		log.Fatal(err)
+		return nil, errors.New(fmt.Sprintf("0x0e5d7f2a open db(%s) fail:%s", dbPath, err))
 	}
-	//db.Close()
-	return db
+	return db, nil
 }
 
 func createTable(db *sql.DB) error {
@@ -53,6 +158,7 @@ func createTable(db *sql.DB) error {
 	// roleType 角色类型
 	// ver 版本号
 	// ts 分配时间
+	// 之后新增的列见 migrate.go
 	sqlStmt := `
 	create table IF NOT EXISTS netConfigTbl (
 		sub_id INT NOT NULL PRIMARY KEY,
@@ -94,21 +200,74 @@ func createTable(db *sql.DB) error {
 		return err
 	}
 
+	// 子网号租约表
+	// uuid
+	// sub_id 最近一次分配的子网号
+	// roleType
+	// expire 租约到期时间
+	// released 1:子网号已回收
+	sqlStmt = `
+	create table IF NOT EXISTS leaseTbl (
+		uuid text NOT NULL PRIMARY KEY,
+		sub_id INT,
+		roleType INT,
+		expire timestamp,
+		released INT DEFAULT 0);
+	`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%s: %s\n", err.Error(), sqlStmt)
+		return err
+	}
+
+	// 子网号静态预留表
+	// uuid
+	// sub_id 固定的子网号
+	// roleType
+	// note 备注
+	sqlStmt = `
+	create table IF NOT EXISTS reservationTbl (
+		uuid text NOT NULL PRIMARY KEY,
+		sub_id INT NOT NULL unique,
+		roleType INT,
+		note text);
+	`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%s: %s\n", err.Error(), sqlStmt)
+		return err
+	}
+
 	return nil
 }
 
-func InitDB(dbPath string) {
-	dbHandle = openDB(dbPath)
-	err := createTable(dbHandle)
+func NewSqliteStore(dbPath string) (*sqliteStoreT, error) {
+	db, err := openDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	err = createTable(db)
 	if err != nil {
-		log.Fatal(err)
+		db.Close()
+		return nil, err
 	}
+	_, err = migrateUp(db, false)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqliteStoreT{db: db}, nil
 }
 
-func LoadNetConfigItemAll() ([]*NetConfigT, error) {
+func (s *sqliteStoreT) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqliteStoreT) LoadNetConfigItemAll() ([]*NetConfigT, error) {
 	var retLst []*NetConfigT
 
-	rows, err := dbHandle.Query("SELECT sub_id, uuid, ip, roleType, ver, ts FROM netConfigTbl")
+	rows, err := s.db.Query("SELECT sub_id, uuid, ip, ipv6, roleType, ver, ts FROM netConfigTbl")
 	if err != nil {
 		log.Printf("0x6625c105 db.Query err:%s", err)
 		return retLst, err
@@ -117,7 +276,7 @@ func LoadNetConfigItemAll() ([]*NetConfigT, error) {
 
 	for rows.Next() {
 		var node NetConfigT
-		err = rows.Scan(&node.SubId, &node.Uuid, &node.IP, &node.RoleType, &node.Ver, &node.TS)
+		err = rows.Scan(&node.SubId, &node.Uuid, &node.IP, &node.IPv6, &node.RoleType, &node.Ver, &node.TS)
 		if err != nil {
 			log.Printf("0x50f73e51 rows.Scan err:%s", err)
 			return nil, err
@@ -136,10 +295,10 @@ func LoadNetConfigItemAll() ([]*NetConfigT, error) {
 }
 
 // FindNetConfigItemByUuid 不保证结果唯一
-func FindNetConfigItemByUuid(uuid string) ([]*NetConfigT, error) {
+func (s *sqliteStoreT) FindNetConfigItemByUuid(uuid string) ([]*NetConfigT, error) {
 	var retLst []*NetConfigT
 
-	rows, err := dbHandle.Query("SELECT sub_id, ip, roleType, ver, ts FROM netConfigTbl WHERE uuid == ?", uuid)
+	rows, err := s.db.Query("SELECT sub_id, ip, ipv6, roleType, ver, ts FROM netConfigTbl WHERE uuid == ?", uuid)
 	if err != nil {
 		log.Printf("0x255d7d91 db.Query err:%s", err)
 		return retLst, err
@@ -148,7 +307,7 @@ func FindNetConfigItemByUuid(uuid string) ([]*NetConfigT, error) {
 
 	for rows.Next() {
 		var node NetConfigT
-		err = rows.Scan(&node.SubId, &node.IP, &node.RoleType, &node.Ver, &node.TS)
+		err = rows.Scan(&node.SubId, &node.IP, &node.IPv6, &node.RoleType, &node.Ver, &node.TS)
 		if err != nil {
 			log.Printf("0x3c8d5220 rows.Scan err:%s", err)
 			return nil, err
@@ -168,9 +327,9 @@ func FindNetConfigItemByUuid(uuid string) ([]*NetConfigT, error) {
 }
 
 // DeleteNetConfigItemByUuid 删除指定行
-func DeleteNetConfigItemByUuid(uuid string) error {
+func (s *sqliteStoreT) DeleteNetConfigItemByUuid(uuid string) error {
 	// 删除数据
-	deleteStmt, err := dbHandle.Prepare("DELETE FROM netConfigTbl WHERE uuid = ?")
+	deleteStmt, err := s.db.Prepare("DELETE FROM netConfigTbl WHERE uuid = ?")
 	if err != nil {
 		return errors.New(fmt.Sprintf("0x3ca6aadb db.Prepare error uuid:%s", uuid))
 	}
@@ -184,8 +343,8 @@ func DeleteNetConfigItemByUuid(uuid string) error {
 	return nil
 }
 
-func UpdateNetConfigPingByUuid(uuid string) error {
-	updateStmt, err := dbHandle.Prepare("UPDATE netConfigTbl SET ts = ? WHERE uuid = ?")
+func (s *sqliteStoreT) UpdateNetConfigPingByUuid(uuid string) error {
+	updateStmt, err := s.db.Prepare("UPDATE netConfigTbl SET ts = ? WHERE uuid = ?")
 	if err != nil {
 		return errors.New(fmt.Sprintf("0x0454bd4e db.Prepare error uuid:%s", uuid))
 	}
@@ -200,11 +359,11 @@ func UpdateNetConfigPingByUuid(uuid string) error {
 }
 
 // UpdateNetConfigRowByUuid 更新原有数据
-func UpdateNetConfigRowByUuid(node *NodeT) error {
+func (s *sqliteStoreT) UpdateNetConfigRowByUuid(node *NodeT) error {
 	ctx := context.Background()
-	rowInfo := fmt.Sprintf("subId:%d, uuid:%s, ip:%s, roleType:%d, ver:%s, ts:%s", node.SubId, node.Uuid, node.IP, node.RoleType, node.Ver, node.Ping)
+	rowInfo := fmt.Sprintf("subId:%d, uuid:%s, ip:%s, ipv6:%s, roleType:%d, ver:%s, ts:%s", node.SubId, node.Uuid, node.IP, node.IPv6, node.RoleType, node.Ver, node.Ping)
 
-	result, err := dbHandle.ExecContext(ctx, "UPDATE netConfigTbl SET sub_id=?, ip=?, roleType=?, ver=?, ts=? WHERE uuid=?", node.SubId, node.IP, node.RoleType, node.Ver, node.Ping, node.Uuid)
+	result, err := s.db.ExecContext(ctx, "UPDATE netConfigTbl SET sub_id=?, ip=?, ipv6=?, roleType=?, ver=?, ts=? WHERE uuid=?", node.SubId, node.IP, node.IPv6, node.RoleType, node.Ver, node.Ping, node.Uuid)
 	if err != nil {
 		return errors.New(fmt.Sprintf("0x64d8c6a9 update fail(%s), row:%s", err, rowInfo))
 	}
@@ -220,11 +379,11 @@ func UpdateNetConfigRowByUuid(node *NodeT) error {
 }
 
 // InsertNetConfig 新增一条数据
-func InsertNetConfig(node *NodeT) error {
-	rowInfo := fmt.Sprintf("row info subId:%d, uuid:%s, ip:%s, role:%d, ver:%s ts:%v",
-		node.SubId, node.Uuid, node.IP, node.RoleType, node.Ver, node.Ping)
+func (s *sqliteStoreT) InsertNetConfig(node *NodeT) error {
+	rowInfo := fmt.Sprintf("row info subId:%d, uuid:%s, ip:%s, ipv6:%s, role:%d, ver:%s ts:%v",
+		node.SubId, node.Uuid, node.IP, node.IPv6, node.RoleType, node.Ver, node.Ping)
 
-	stmt, err := dbHandle.Prepare("INSERT INTO netConfigTbl(sub_id, uuid, ip, roleType, ver, ts) VALUES ( ?, ?, ?, ?, ?, ? )")
+	stmt, err := s.db.Prepare("INSERT INTO netConfigTbl(sub_id, uuid, ip, ipv6, roleType, ver, ts) VALUES ( ?, ?, ?, ?, ?, ?, ? )")
 	if err != nil {
 		err = errors.New(fmt.Sprintf("0x159f4891 db.Prepare fail:%s, rowInfo:%s", err, rowInfo))
 		log.Printf(err.Error())
@@ -232,7 +391,7 @@ func InsertNetConfig(node *NodeT) error {
 	}
 	defer stmt.Close() // Prepared statements take up server resources and should be closed after use.
 
-	_, err = stmt.Exec(node.SubId, node.Uuid, node.IP, node.RoleType, node.Ver, node.Ping)
+	_, err = stmt.Exec(node.SubId, node.Uuid, node.IP, node.IPv6, node.RoleType, node.Ver, node.Ping)
 	if err != nil {
 		err = errors.New(fmt.Sprintf("0x777e5d3e insert fail:%s, rowInfo:%v", err, rowInfo))
 		log.Printf(err.Error())
@@ -243,10 +402,10 @@ func InsertNetConfig(node *NodeT) error {
 }
 
 // InsertNodeEvent 新增一条数据
-func InsertNodeEvent(ip string, role proto.Role, addMsg string, event *proto.MsgEventPost) error {
-	rowInfo, _ := json.Marshal(*event)
+func (s *sqliteStoreT) InsertNodeEvent(event *EventItemDBT) error {
+	rowInfo, _ := json.Marshal(event)
 	//	create table IF NOT EXISTS nodeEventTbl (id INT NOT NULL AUTO_INCREMENT PRIMARY KEY, uuid text, ip text, ver text, eventType INT, eventMsg text, roleType INT, ts timestamp);
-	stmt, err := dbHandle.Prepare("INSERT INTO nodeEventTbl(uuid, ip, roleType, ver, eventType, eventMsg, ts) VALUES ( ?, ?, ?, ?, ?, ?, ? )")
+	stmt, err := s.db.Prepare("INSERT INTO nodeEventTbl(uuid, ip, roleType, ver, eventType, eventMsg, ts) VALUES ( ?, ?, ?, ?, ?, ?, ? )")
 	if err != nil {
 		err = errors.New(fmt.Sprintf("0x159f4891 db.Prepare fail:%s, rowInfo:%v", err, string(rowInfo)))
 		log.Printf(err.Error())
@@ -254,7 +413,7 @@ func InsertNodeEvent(ip string, role proto.Role, addMsg string, event *proto.Msg
 	}
 	defer stmt.Close() // Prepared statements take up server resources and should be closed after use.
 
-	_, err = stmt.Exec(event.Machine.GetUUID(), ip, role, event.Node.GetVer(), event.Event, addMsg, time.Now())
+	_, err = stmt.Exec(event.Uuid, event.IP, event.RoleType, event.Ver, event.EType, event.EMsg, eventTS(event.TS))
 	if err != nil {
 		err = errors.New(fmt.Sprintf("0x2bda2151 insert fail:%s, %v", err, rowInfo))
 		log.Printf(err.Error())
@@ -264,10 +423,10 @@ func InsertNodeEvent(ip string, role proto.Role, addMsg string, event *proto.Msg
 	return nil
 }
 
-func SelectEventAll() ([]*EventItemDBT, error) {
+func (s *sqliteStoreT) SelectEventAll() ([]*EventItemDBT, error) {
 	var retLst []*EventItemDBT
 
-	rows, err := dbHandle.Query("SELECT uuid, ip, roleType, ver, eventType, eventMsg, ts FROM nodeEventTbl")
+	rows, err := s.db.Query("SELECT id, uuid, ip, roleType, ver, eventType, eventMsg, ts FROM nodeEventTbl")
 	if err != nil {
 		log.Printf("0x645df775 db.Query err:%s", err)
 		return retLst, err
@@ -276,7 +435,7 @@ func SelectEventAll() ([]*EventItemDBT, error) {
 
 	for rows.Next() {
 		event := &EventItemDBT{}
-		err = rows.Scan(&event.Uuid, &event.IP, &event.RoleType, &event.Ver, &event.EType, &event.EMsg, &event.TS)
+		err = rows.Scan(&event.Id, &event.Uuid, &event.IP, &event.RoleType, &event.Ver, &event.EType, &event.EMsg, &event.TS)
 		if err != nil {
 			log.Printf("0x28f973da rows.Scan err:%s", err)
 			return nil, err
@@ -293,3 +452,703 @@ func SelectEventAll() ([]*EventItemDBT, error) {
 
 	return retLst, nil
 }
+
+// eventTSLayout nodeEventTbl.ts 的存储格式，统一为 UTC、毫秒精度的定长字符串，字符串顺序即时间顺序
+const eventTSLayout = "2006-01-02 15:04:05.000-07:00"
+
+func eventTS(ts time.Time) string {
+	return ts.UTC().Format(eventTSLayout)
+}
+
+// eventWhere 由查询条件生成 where 子句(不含游标)，ts 按 eventTS 的格式直接比较原始列，可使用索引
+func eventWhere(q *EventQueryT) (string, []interface{}) {
+	var condLst []string
+	var args []interface{}
+	if len(q.Uuid) > 0 {
+		condLst = append(condLst, "uuid = ?")
+		args = append(args, q.Uuid)
+	}
+	if len(q.IP) > 0 {
+		condLst = append(condLst, "ip = ?")
+		args = append(args, q.IP)
+	}
+	if q.Role >= 0 {
+		condLst = append(condLst, "roleType = ?")
+		args = append(args, q.Role)
+	}
+	if len(q.Ver) > 0 {
+		condLst = append(condLst, "ver = ?")
+		args = append(args, q.Ver)
+	}
+	if len(q.Types) > 0 {
+		condLst = append(condLst, fmt.Sprintf("eventType IN (%s)", strings.TrimSuffix(strings.Repeat("?,", len(q.Types)), ",")))
+		for _, eType := range q.Types {
+			args = append(args, eType)
+		}
+	}
+	if !q.Since.IsZero() {
+		condLst = append(condLst, "ts >= ?")
+		args = append(args, eventTS(q.Since))
+	}
+	if !q.Until.IsZero() {
+		condLst = append(condLst, "ts < ?")
+		args = append(args, eventTS(q.Until))
+	}
+	if len(q.Text) > 0 {
+		condLst = append(condLst, "instr(eventMsg, ?) > 0")
+		args = append(args, q.Text)
+	}
+
+	if len(condLst) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(condLst, " AND "), args
+}
+
+func (s *sqliteStoreT) SelectEvent(q *EventQueryT) (*EventPageT, error) {
+	ret := &EventPageT{Events: make([]*EventItemDBT, 0)}
+	where, args := eventWhere(q)
+
+	err := s.db.QueryRow("SELECT count(*) FROM nodeEventTbl"+where, args...).Scan(&ret.Total)
+	if err != nil {
+		log.Printf("0x0d5c3e8a db.QueryRow err:%s", err)
+		return nil, err
+	}
+
+	order := "ASC"
+	if q.Cursor > 0 {
+		cond := "id > ?"
+		if q.Desc {
+			cond = "id < ?"
+		}
+		if len(where) > 0 {
+			where = where + " AND " + cond
+		} else {
+			where = " WHERE " + cond
+		}
+		args = append(args, q.Cursor)
+	}
+	if q.Desc {
+		order = "DESC"
+	}
+	// 多取一条用于判断是否还有下一页
+	args = append(args, q.Limit+1)
+	rows, err := s.db.Query("SELECT id, uuid, ip, roleType, ver, eventType, eventMsg, ts FROM nodeEventTbl"+where+
+		" ORDER BY id "+order+" LIMIT ?", args...)
+	if err != nil {
+		log.Printf("0x3a96f1c4 db.Query err:%s", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event := &EventItemDBT{}
+		err = rows.Scan(&event.Id, &event.Uuid, &event.IP, &event.RoleType, &event.Ver, &event.EType, &event.EMsg, &event.TS)
+		if err != nil {
+			log.Printf("0x6fb0d297 rows.Scan err:%s", err)
+			return nil, err
+		}
+		if len(ret.Events) == q.Limit {
+			ret.NextCursor = ret.Events[len(ret.Events)-1].Id
+			break
+		}
+		ret.Events = append(ret.Events, event)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Printf("0x27e4c0b8 rows err:%s", err)
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (s *sqliteStoreT) CompactEvent(rollupLst []*EventRollupT, idLst []int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x5d0e7a13 begin tx fail(%s)", err))
+	}
+	defer tx.Rollback()
+
+	for _, r := range rollupLst {
+		_, err = tx.Exec("INSERT INTO nodeEventRollupTbl(period, start, uuid, eventType, cnt) VALUES ( ?, ?, ?, ?, ? ) "+
+			"ON CONFLICT(period, start, uuid, eventType) DO UPDATE SET cnt = cnt + excluded.cnt",
+			r.Period, r.Start.Unix(), r.Uuid, r.EType, r.Count)
+		if err != nil {
+			return errors.New(fmt.Sprintf("0x2b86f4c0 upsert rollup fail(%s), uuid:%s", err, r.Uuid))
+		}
+	}
+	for _, id := range idLst {
+		_, err = tx.Exec("DELETE FROM nodeEventTbl WHERE id = ?", id)
+		if err != nil {
+			return errors.New(fmt.Sprintf("0x48a1c9e7 delete event fail(%s), id:%d", err, id))
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x0f3b6d25 commit fail(%s)", err))
+	}
+	return nil
+}
+
+func (s *sqliteStoreT) SelectEventRollup(q *EventRollupQueryT) ([]*EventRollupT, error) {
+	var condLst []string
+	var args []interface{}
+	if len(q.Period) > 0 {
+		condLst = append(condLst, "period = ?")
+		args = append(args, q.Period)
+	}
+	if len(q.Uuid) > 0 {
+		condLst = append(condLst, "uuid = ?")
+		args = append(args, q.Uuid)
+	}
+	if len(q.Types) > 0 {
+		condLst = append(condLst, fmt.Sprintf("eventType IN (%s)", strings.TrimSuffix(strings.Repeat("?,", len(q.Types)), ",")))
+		for _, eType := range q.Types {
+			args = append(args, eType)
+		}
+	}
+	if !q.Since.IsZero() {
+		condLst = append(condLst, "start >= ?")
+		args = append(args, q.Since.Unix())
+	}
+	if !q.Until.IsZero() {
+		condLst = append(condLst, "start < ?")
+		args = append(args, q.Until.Unix())
+	}
+	where := ""
+	if len(condLst) > 0 {
+		where = " WHERE " + strings.Join(condLst, " AND ")
+	}
+
+	rows, err := s.db.Query("SELECT period, start, uuid, eventType, cnt FROM nodeEventRollupTbl"+where+
+		" ORDER BY start, period, uuid, eventType", args...)
+	if err != nil {
+		log.Printf("0x6c29e0f5 db.Query err:%s", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	retLst := make([]*EventRollupT, 0)
+	for rows.Next() {
+		r := &EventRollupT{}
+		var start int64
+		err = rows.Scan(&r.Period, &start, &r.Uuid, &r.EType, &r.Count)
+		if err != nil {
+			log.Printf("0x1e57b3a8 rows.Scan err:%s", err)
+			return nil, err
+		}
+		r.Start = time.Unix(start, 0).UTC()
+		retLst = append(retLst, r)
+	}
+	return retLst, rows.Err()
+}
+
+func (s *sqliteStoreT) LoadLeaseAll() ([]*LeaseT, error) {
+	var retLst []*LeaseT
+
+	rows, err := s.db.Query("SELECT uuid, sub_id, roleType, expire, released, closed FROM leaseTbl")
+	if err != nil {
+		log.Printf("0x1b4e7a52 db.Query err:%s", err)
+		return retLst, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		lease := &LeaseT{}
+		err = rows.Scan(&lease.Uuid, &lease.SubId, &lease.RoleType, &lease.Expire, &lease.Released, &lease.Closed)
+		if err != nil {
+			log.Printf("0x5c09d3e6 rows.Scan err:%s", err)
+			return nil, err
+		}
+		retLst = append(retLst, lease)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Printf("0x28e6f4b1 rows err:%s", err)
+		return []*LeaseT{}, err
+	}
+
+	return retLst, nil
+}
+
+// UpsertLease 新增或续约
+func (s *sqliteStoreT) UpsertLease(lease *LeaseT) error {
+	rowInfo := fmt.Sprintf("uuid:%s, subId:%d, roleType:%d, expire:%v, released:%v, closed:%v",
+		lease.Uuid, lease.SubId, lease.RoleType, lease.Expire, lease.Released, lease.Closed)
+
+	_, err := s.db.Exec("INSERT INTO leaseTbl(uuid, sub_id, roleType, expire, released, closed) VALUES ( ?, ?, ?, ?, ?, ? ) "+
+		"ON CONFLICT(uuid) DO UPDATE SET sub_id=excluded.sub_id, roleType=excluded.roleType, expire=excluded.expire, released=excluded.released, closed=excluded.closed",
+		lease.Uuid, lease.SubId, lease.RoleType, lease.Expire, lease.Released, lease.Closed)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x7a3d5e90 upsert lease fail:%s, row:%s", err, rowInfo))
+	}
+
+	return nil
+}
+
+// ReleaseLeaseByUuid 子网号已回收，保留记录用于再次上线时沿用
+func (s *sqliteStoreT) ReleaseLeaseByUuid(uuid string) error {
+	_, err := s.db.Exec("UPDATE leaseTbl SET released = 1 WHERE uuid = ?", uuid)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x4f81c2a7 release lease fail:%s, uuid:%s", err, uuid))
+	}
+
+	return nil
+}
+
+func (s *sqliteStoreT) LoadReservationAll() ([]*ReservationT, error) {
+	var retLst []*ReservationT
+
+	rows, err := s.db.Query("SELECT uuid, sub_id, roleType, note FROM reservationTbl")
+	if err != nil {
+		log.Printf("0x3b7f02d9 db.Query err:%s", err)
+		return retLst, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		res := &ReservationT{}
+		err = rows.Scan(&res.Uuid, &res.SubId, &res.RoleType, &res.Note)
+		if err != nil {
+			log.Printf("0x6d4c81ae rows.Scan err:%s", err)
+			return nil, err
+		}
+		retLst = append(retLst, res)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Printf("0x0a9e35f2 rows err:%s", err)
+		return []*ReservationT{}, err
+	}
+
+	return retLst, nil
+}
+
+// UpsertReservation 新增或修改预留
+func (s *sqliteStoreT) UpsertReservation(res *ReservationT) error {
+	_, err := s.db.Exec("INSERT INTO reservationTbl(uuid, sub_id, roleType, note) VALUES ( ?, ?, ?, ? ) "+
+		"ON CONFLICT(uuid) DO UPDATE SET sub_id=excluded.sub_id, roleType=excluded.roleType, note=excluded.note",
+		res.Uuid, res.SubId, res.RoleType, res.Note)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x58c1a7e4 upsert reservation fail:%s, uuid:%s, subId:%d", err, res.Uuid, res.SubId))
+	}
+
+	return nil
+}
+
+func (s *sqliteStoreT) DeleteReservationByUuid(uuid string) error {
+	_, err := s.db.Exec("DELETE FROM reservationTbl WHERE uuid = ?", uuid)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x2e90d6b3 delete reservation fail:%s, uuid:%s", err, uuid))
+	}
+
+	return nil
+}
+
+func (s *sqliteStoreT) LoadCredentialAll() ([]*CredentialT, error) {
+	var retLst []*CredentialT
+
+	rows, err := s.db.Query("SELECT uuid, secret, created, confirmed FROM nodeCredentialTbl")
+	if err != nil {
+		log.Printf("0x0d6e3b91 db.Query err:%s", err)
+		return retLst, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cred := &CredentialT{}
+		var created, confirmed int64
+		err = rows.Scan(&cred.Uuid, &cred.Secret, &created, &confirmed)
+		if err != nil {
+			log.Printf("0x4a7c2fe0 rows.Scan err:%s", err)
+			return nil, err
+		}
+		cred.Created = time.UnixMilli(created)
+		if confirmed > 0 {
+			cred.Confirmed = time.UnixMilli(confirmed)
+		}
+		retLst = append(retLst, cred)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Printf("0x79b3e5c8 rows err:%s", err)
+		return []*CredentialT{}, err
+	}
+
+	return retLst, nil
+}
+
+// UpsertCredential 新增或替换 node 的密钥
+func (s *sqliteStoreT) UpsertCredential(cred *CredentialT) error {
+	var confirmed int64
+	if !cred.Confirmed.IsZero() {
+		confirmed = cred.Confirmed.UnixMilli()
+	}
+	_, err := s.db.Exec("INSERT INTO nodeCredentialTbl(uuid, secret, created, confirmed) VALUES ( ?, ?, ?, ? ) "+
+		"ON CONFLICT(uuid) DO UPDATE SET secret=excluded.secret, created=excluded.created, confirmed=excluded.confirmed",
+		cred.Uuid, cred.Secret, cred.Created.UnixMilli(), confirmed)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x1f9a6d37 upsert credential fail:%s, uuid:%s", err, cred.Uuid))
+	}
+
+	return nil
+}
+
+func (s *sqliteStoreT) DeleteCredentialByUuid(uuid string) error {
+	_, err := s.db.Exec("DELETE FROM nodeCredentialTbl WHERE uuid = ?", uuid)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x6c05b8e2 delete credential fail:%s, uuid:%s", err, uuid))
+	}
+
+	return nil
+}
+
+func (s *sqliteStoreT) LoadEnrollAll() ([]*EnrollT, error) {
+	var retLst []*EnrollT
+
+	rows, err := s.db.Query("SELECT uuid, status, ip, ver, first, last, cnt, note FROM nodeEnrollTbl")
+	if err != nil {
+		log.Printf("0x2c9f07e4 db.Query err:%s", err)
+		return retLst, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		enroll := &EnrollT{}
+		var first, last int64
+		err = rows.Scan(&enroll.Uuid, &enroll.Status, &enroll.IP, &enroll.Ver, &first, &last, &enroll.Count, &enroll.Note)
+		if err != nil {
+			log.Printf("0x5e70b1d3 rows.Scan err:%s", err)
+			return nil, err
+		}
+		enroll.First = time.UnixMilli(first)
+		enroll.Last = time.UnixMilli(last)
+		retLst = append(retLst, enroll)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Printf("0x13a6d8f0 rows err:%s", err)
+		return []*EnrollT{}, err
+	}
+
+	return retLst, nil
+}
+
+func (s *sqliteStoreT) UpsertEnroll(enroll *EnrollT) error {
+	_, err := s.db.Exec("INSERT INTO nodeEnrollTbl(uuid, status, ip, ver, first, last, cnt, note) VALUES ( ?, ?, ?, ?, ?, ?, ?, ? ) "+
+		"ON CONFLICT(uuid) DO UPDATE SET status=excluded.status, ip=excluded.ip, ver=excluded.ver, "+
+		"first=excluded.first, last=excluded.last, cnt=excluded.cnt, note=excluded.note",
+		enroll.Uuid, enroll.Status, enroll.IP, enroll.Ver, enroll.First.UnixMilli(), enroll.Last.UnixMilli(), enroll.Count, enroll.Note)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x6f2e8c15 upsert enroll fail:%s, uuid:%s", err, enroll.Uuid))
+	}
+
+	return nil
+}
+
+func (s *sqliteStoreT) DeleteEnrollByUuid(uuid string) error {
+	_, err := s.db.Exec("DELETE FROM nodeEnrollTbl WHERE uuid = ?", uuid)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x0b84f3a6 delete enroll fail:%s, uuid:%s", err, uuid))
+	}
+
+	return nil
+}
+
+func (s *sqliteStoreT) LoadEnrollTokenAll() ([]*EnrollTokenT, error) {
+	var retLst []*EnrollTokenT
+
+	rows, err := s.db.Query("SELECT hash, uuid, expire, created, note FROM enrollTokenTbl")
+	if err != nil {
+		log.Printf("0x47d1a9c2 db.Query err:%s", err)
+		return retLst, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		token := &EnrollTokenT{}
+		var expire, created int64
+		err = rows.Scan(&token.Hash, &token.Uuid, &expire, &created, &token.Note)
+		if err != nil {
+			log.Printf("0x7a35e0b8 rows.Scan err:%s", err)
+			return nil, err
+		}
+		token.Expire = time.UnixMilli(expire)
+		token.Created = time.UnixMilli(created)
+		retLst = append(retLst, token)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Printf("0x3e9c62d4 rows err:%s", err)
+		return []*EnrollTokenT{}, err
+	}
+
+	return retLst, nil
+}
+
+func (s *sqliteStoreT) UpsertEnrollToken(token *EnrollTokenT) error {
+	_, err := s.db.Exec("INSERT INTO enrollTokenTbl(hash, uuid, expire, created, note) VALUES ( ?, ?, ?, ?, ? ) "+
+		"ON CONFLICT(hash) DO UPDATE SET uuid=excluded.uuid, expire=excluded.expire, created=excluded.created, note=excluded.note",
+		token.Hash, token.Uuid, token.Expire.UnixMilli(), token.Created.UnixMilli(), token.Note)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x58b0f7e1 upsert enroll token fail:%s", err))
+	}
+
+	return nil
+}
+
+func (s *sqliteStoreT) DeleteEnrollToken(hash string) error {
+	_, err := s.db.Exec("DELETE FROM enrollTokenTbl WHERE hash = ?", hash)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x21f6c9a7 delete enroll token fail:%s", err))
+	}
+
+	return nil
+}
+
+func (s *sqliteStoreT) LoadNodeAttrAll() ([]*NodeAttrT, error) {
+	var retLst []*NodeAttrT
+
+	rows, err := s.db.Query("SELECT uuid, role, note, maintenance, updated, drainSince, drainUntil, drainAssigned, pacPinRole, pacPinIP, reqRole, roleReason FROM nodeAttrTbl")
+	if err != nil {
+		log.Printf("0x0a7c3e94 db.Query err:%s", err)
+		return retLst, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		attr := &NodeAttrT{}
+		var updated, drainSince, drainUntil int64
+		err = rows.Scan(&attr.Uuid, &attr.Role, &attr.Note, &attr.Maintenance, &updated, &drainSince, &drainUntil, &attr.DrainAssigned,
+			&attr.PacPinRole, &attr.PacPinIP, &attr.ReqRole, &attr.RoleReason)
+		if err != nil {
+			log.Printf("0x6b21f0d8 rows.Scan err:%s", err)
+			return nil, err
+		}
+		attr.Updated = time.UnixMilli(updated)
+		// 0 为未排空
+		if drainUntil > 0 {
+			attr.DrainSince = time.UnixMilli(drainSince)
+			attr.DrainUntil = time.UnixMilli(drainUntil)
+		}
+		retLst = append(retLst, attr)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Printf("0x3f8d5a12 rows err:%s", err)
+		return []*NodeAttrT{}, err
+	}
+
+	return retLst, nil
+}
+
+func (s *sqliteStoreT) UpsertNodeAttr(attr *NodeAttrT) error {
+	var drainSince, drainUntil int64
+	if !attr.DrainUntil.IsZero() {
+		drainSince, drainUntil = attr.DrainSince.UnixMilli(), attr.DrainUntil.UnixMilli()
+	}
+	_, err := s.db.Exec("INSERT INTO nodeAttrTbl(uuid, role, note, maintenance, updated, drainSince, drainUntil, drainAssigned, pacPinRole, pacPinIP, reqRole, roleReason) "+
+		"VALUES ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? ) "+
+		"ON CONFLICT(uuid) DO UPDATE SET role=excluded.role, note=excluded.note, maintenance=excluded.maintenance, updated=excluded.updated, "+
+		"drainSince=excluded.drainSince, drainUntil=excluded.drainUntil, drainAssigned=excluded.drainAssigned, "+
+		"pacPinRole=excluded.pacPinRole, pacPinIP=excluded.pacPinIP, reqRole=excluded.reqRole, roleReason=excluded.roleReason",
+		attr.Uuid, attr.Role, attr.Note, attr.Maintenance, attr.Updated.UnixMilli(), drainSince, drainUntil, attr.DrainAssigned,
+		attr.PacPinRole, attr.PacPinIP, attr.ReqRole, attr.RoleReason)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x54e9b3c0 upsert node attr fail:%s, uuid:%s", err, attr.Uuid))
+	}
+
+	return nil
+}
+
+func (s *sqliteStoreT) DeleteNodeAttrByUuid(uuid string) error {
+	_, err := s.db.Exec("DELETE FROM nodeAttrTbl WHERE uuid = ?", uuid)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x1d60a7f5 delete node attr fail:%s, uuid:%s", err, uuid))
+	}
+
+	return nil
+}
+
+func (s *sqliteStoreT) InsertAudit(audit *AuditT) error {
+	_, err := s.db.Exec("INSERT INTO auditTbl(ts, actor, ip, action, uuid, detail, err) VALUES ( ?, ?, ?, ?, ?, ?, ? )",
+		audit.TS.UnixMilli(), audit.Actor, audit.IP, audit.Action, audit.Uuid, audit.Detail, audit.Err)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x7c2a91e6 insert audit fail:%s, action:%s", err, audit.Action))
+	}
+
+	return nil
+}
+
+func (s *sqliteStoreT) SelectAudit(q *AuditQueryT) ([]*AuditT, error) {
+	retLst := make([]*AuditT, 0)
+
+	where, args := "1=1", []interface{}{}
+	if len(q.Uuid) > 0 {
+		where += " AND uuid = ?"
+		args = append(args, q.Uuid)
+	}
+	if len(q.Action) > 0 {
+		where += " AND action = ?"
+		args = append(args, q.Action)
+	}
+	args = append(args, q.Limit)
+	rows, err := s.db.Query("SELECT id, ts, actor, ip, action, uuid, detail, err FROM auditTbl WHERE "+where+" ORDER BY id DESC LIMIT ?", args...)
+	if err != nil {
+		log.Printf("0x28f4d6b1 db.Query err:%s", err)
+		return retLst, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		audit := &AuditT{}
+		var ts int64
+		err = rows.Scan(&audit.Id, &ts, &audit.Actor, &audit.IP, &audit.Action, &audit.Uuid, &audit.Detail, &audit.Err)
+		if err != nil {
+			log.Printf("0x4e1c8a07 rows.Scan err:%s", err)
+			return nil, err
+		}
+		audit.TS = time.UnixMilli(ts)
+		retLst = append(retLst, audit)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Printf("0x65b7e0d3 rows err:%s", err)
+		return []*AuditT{}, err
+	}
+
+	return retLst, nil
+}
+
+// CommitNetConfig 网络参数与租约在同一事务中写入，insert 为 false 时更新原有行
+func (s *sqliteStoreT) CommitNetConfig(node *NodeT, insert bool) error {
+	rowInfo := fmt.Sprintf("subId:%d, uuid:%s, ip:%s, ipv6:%s, roleType:%d, ver:%s, ts:%s, expire:%s",
+		node.SubId, node.Uuid, node.IP, node.IPv6, node.RoleType, node.Ver, node.Ping, node.LeaseExpire)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x4c2b8e07 begin tx fail(%s), row:%s", err, rowInfo))
+	}
+	defer tx.Rollback()
+
+	if insert {
+		_, err = tx.Exec("INSERT INTO netConfigTbl(sub_id, uuid, ip, ipv6, roleType, ver, ts) VALUES ( ?, ?, ?, ?, ?, ?, ? )",
+			node.SubId, node.Uuid, node.IP, node.IPv6, node.RoleType, node.Ver, node.Ping)
+		if err != nil {
+			return errors.New(fmt.Sprintf("0x1f9d3a64 insert fail(%s), row:%s", err, rowInfo))
+		}
+	} else {
+		result, err := tx.Exec("UPDATE netConfigTbl SET sub_id=?, ip=?, ipv6=?, roleType=?, ver=?, ts=? WHERE uuid=?",
+			node.SubId, node.IP, node.IPv6, node.RoleType, node.Ver, node.Ping, node.Uuid)
+		if err != nil {
+			return errors.New(fmt.Sprintf("0x6e05c7b2 update fail(%s), row:%s", err, rowInfo))
+		}
+		rows, err := result.RowsAffected()
+		if err != nil || rows != 1 {
+			return errors.New(fmt.Sprintf("0x38a1f5d9 update fail(%v, rows:%d), row:%s", err, rows, rowInfo))
+		}
+	}
+
+	lease := node.lease()
+	_, err = tx.Exec("INSERT INTO leaseTbl(uuid, sub_id, roleType, expire, released, closed) VALUES ( ?, ?, ?, ?, ?, ? ) "+
+		"ON CONFLICT(uuid) DO UPDATE SET sub_id=excluded.sub_id, roleType=excluded.roleType, expire=excluded.expire, released=excluded.released, closed=excluded.closed",
+		lease.Uuid, lease.SubId, lease.RoleType, lease.Expire, lease.Released, lease.Closed)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x5b7e20c4 upsert lease fail(%s), row:%s", err, rowInfo))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x27d4a9e1 commit fail(%s), row:%s", err, rowInfo))
+	}
+	return nil
+}
+
+// ReleaseNetConfig 删除网络参数并标记租约已回收
+func (s *sqliteStoreT) ReleaseNetConfig(uuid string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x0c6f93b8 begin tx fail(%s), uuid:%s", err, uuid))
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM netConfigTbl WHERE uuid = ?", uuid)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x7d1a4e52 delete fail(%s), uuid:%s", err, uuid))
+	}
+	_, err = tx.Exec("UPDATE leaseTbl SET released = 1 WHERE uuid = ?", uuid)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x49e8b0c3 release lease fail(%s), uuid:%s", err, uuid))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x63c5d7a0 commit fail(%s), uuid:%s", err, uuid))
+	}
+	return nil
+}
+
+// ReplaceNetConfig 删除 uuid 的所有网络参数(可能有重复行)后重新写入
+func (s *sqliteStoreT) ReplaceNetConfig(uuid string, node *NodeT) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x4a1e7c30 begin tx fail(%s), uuid:%s", err, uuid))
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM netConfigTbl WHERE uuid = ?", uuid)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x15d8b2f6 delete fail(%s), uuid:%s", err, uuid))
+	}
+	if node == nil {
+		_, err = tx.Exec("UPDATE leaseTbl SET released = 1 WHERE uuid = ?", uuid)
+		if err != nil {
+			return errors.New(fmt.Sprintf("0x6e2f94a1 release lease fail(%s), uuid:%s", err, uuid))
+		}
+	} else {
+		_, err = tx.Exec("INSERT INTO netConfigTbl(sub_id, uuid, ip, ipv6, roleType, ver, ts) VALUES ( ?, ?, ?, ?, ?, ?, ? )",
+			node.SubId, node.Uuid, node.IP, node.IPv6, node.RoleType, node.Ver, node.Ping)
+		if err != nil {
+			return errors.New(fmt.Sprintf("0x3b07d5e8 insert fail(%s), uuid:%s, subId:%d", err, uuid, node.SubId))
+		}
+		lease := node.lease()
+		_, err = tx.Exec("INSERT INTO leaseTbl(uuid, sub_id, roleType, expire, released, closed) VALUES ( ?, ?, ?, ?, ?, ? ) "+
+			"ON CONFLICT(uuid) DO UPDATE SET sub_id=excluded.sub_id, roleType=excluded.roleType, expire=excluded.expire, released=excluded.released, closed=excluded.closed",
+			lease.Uuid, lease.SubId, lease.RoleType, lease.Expire, lease.Released, lease.Closed)
+		if err != nil {
+			return errors.New(fmt.Sprintf("0x70c4a8d2 upsert lease fail(%s), uuid:%s", err, uuid))
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x2849f1b7 commit fail(%s), uuid:%s", err, uuid))
+	}
+	return nil
+}
+
+// DeleteNetConfig 删除网络参数及租约
+func (s *sqliteStoreT) DeleteNetConfig(uuid string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x3e8a51d7 begin tx fail(%s), uuid:%s", err, uuid))
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM netConfigTbl WHERE uuid = ?", uuid)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x6b0f2c94 delete fail(%s), uuid:%s", err, uuid))
+	}
+	_, err = tx.Exec("DELETE FROM leaseTbl WHERE uuid = ?", uuid)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x1a97d4e6 delete lease fail(%s), uuid:%s", err, uuid))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x58c2b0f3 commit fail(%s), uuid:%s", err, uuid))
+	}
+	return nil
+}
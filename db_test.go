@@ -5,17 +5,28 @@ import (
 	"github.com/shankusu2017/proto_pb/go/proto"
 	"github.com/shankusu2017/utils"
 	"math/rand"
+	"path/filepath"
 	"testing"
 	"time"
 )
 
+// newTestSqliteStore 每个测试使用独立的库文件，互不影响
+func newTestSqliteStore(t *testing.T) *sqliteStoreT {
+	store, err := NewSqliteStore(filepath.Join(t.TempDir(), "nodeInfo.db"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
 func TestDBInit(t *testing.T) {
-	InitDB("./etc/nodeInfo.db")
+	newTestSqliteStore(t)
 	//FindNetConfigItemByUuid("ff")
 }
 
 func TestDBInsert(t *testing.T) {
-	InitDB("./etc/nodeInfo.db")
+	store := newTestSqliteStore(t)
 
 	subId := int(rand.Uint32()%255) + 100000
 	node := &NodeT{
@@ -26,7 +37,7 @@ func TestDBInsert(t *testing.T) {
 		Ping:     time.Now(),
 		Ver:      "ver-test",
 	}
-	err := InsertNetConfig(node)
+	err := store.InsertNetConfig(node)
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
@@ -41,18 +52,18 @@ func TestDBInsert(t *testing.T) {
 		Ping:     time.Now(),
 		Ver:      "ver-test",
 	}
-	err = InsertNetConfig(node)
+	err = store.InsertNetConfig(node)
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
-	err = InsertNetConfig(node)
+	err = store.InsertNetConfig(node)
 	if err == nil {
 		t.Fatalf(err.Error())
 	}
 }
 
 func TestUpdateNetConfigRowByUuid(t *testing.T) {
-	InitDB("./etc/nodeInfo.db")
+	store := newTestSqliteStore(t)
 
 	subId := int(rand.Uint32()%255) + 100000
 	node := &NodeT{
@@ -64,12 +75,12 @@ func TestUpdateNetConfigRowByUuid(t *testing.T) {
 		Ver:      "ver-test-insert",
 	}
 
-	InsertNetConfig(node)
+	store.InsertNetConfig(node)
 
 	node.Ver = "ver-test-update"
-	UpdateNetConfigRowByUuid(node)
+	store.UpdateNetConfigRowByUuid(node)
 
-	retLst, _ := FindNetConfigItemByUuid(node.Uuid)
+	retLst, _ := store.FindNetConfigItemByUuid(node.Uuid)
 	if len(retLst) != 1 {
 		t.Fatalf("0x15734373 db error")
 	}
@@ -86,7 +97,7 @@ func TestUpdateNetConfigRowByUuid(t *testing.T) {
 }
 
 func TestDeleteNetConfigItemByUuid(t *testing.T) {
-	InitDB("./etc/nodeInfo.db")
+	store := newTestSqliteStore(t)
 
 	subId := int(rand.Uint32()%255) + 100000
 	node := &NodeT{
@@ -98,21 +109,21 @@ func TestDeleteNetConfigItemByUuid(t *testing.T) {
 		Ver:      "ver-test-insert",
 	}
 
-	InsertNetConfig(node)
-	retLst, _ := FindNetConfigItemByUuid(node.Uuid)
+	store.InsertNetConfig(node)
+	retLst, _ := store.FindNetConfigItemByUuid(node.Uuid)
 	if len(retLst) != 1 {
 		t.Fatalf("0x2d348d02 db error")
 	}
 
-	DeleteNetConfigItemByUuid(node.Uuid)
-	retLst, _ = FindNetConfigItemByUuid(node.Uuid)
+	store.DeleteNetConfigItemByUuid(node.Uuid)
+	retLst, _ = store.FindNetConfigItemByUuid(node.Uuid)
 	if len(retLst) != 0 {
 		t.Fatalf("0x66b0f5a9 db error")
 	}
 }
 
 func TestLoadAllRow(t *testing.T) {
-	InitDB("./etc/nodeInfo.db")
+	store := newTestSqliteStore(t)
 
 	subId := int(rand.Uint32()%255) + 100000
 	node := &NodeT{
@@ -123,11 +134,11 @@ func TestLoadAllRow(t *testing.T) {
 		Ping:     time.Now(),
 		Ver:      "ver-test-insert",
 	}
-	InsertNetConfig(node)
+	store.InsertNetConfig(node)
 
 	// 刚插进入的，肯定在List中
 	found := false
-	allRow, _ := LoadNetConfigItemAll()
+	allRow, _ := store.LoadNetConfigItemAll()
 	for _, dNode := range allRow {
 		if dNode.Uuid == node.Uuid {
 			found = true
@@ -140,7 +151,7 @@ func TestLoadAllRow(t *testing.T) {
 }
 
 func TestInsertEvent(t *testing.T) {
-	InitDB("./etc/nodeInfo.db")
+	store := newTestSqliteStore(t)
 
 	event := &proto.MsgEventPost{
 		Event: proto.Event_STARTED,
@@ -153,5 +164,47 @@ func TestInsertEvent(t *testing.T) {
 		Ver:  "ver-test-event-db",
 		Role: 103,
 	}
-	InsertNodeEvent("192.168.1.1033", event)
+	err := store.InsertNodeEvent(newNodeEvent("192.168.1.1033", proto.Role(103), "", event))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+}
+
+func TestUpsertLease(t *testing.T) {
+	store := newTestSqliteStore(t)
+
+	lease := &LeaseT{
+		Uuid:     utils.MakeHexString(4),
+		SubId:    int(rand.Uint32()%255) + 100000,
+		RoleType: 101,
+		Expire:   time.Now().Add(time.Minute),
+	}
+	err := store.UpsertLease(lease)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	// 续约
+	lease.Expire = lease.Expire.Add(time.Hour)
+	err = store.UpsertLease(lease)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	err = store.ReleaseLeaseByUuid(lease.Uuid)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	found := false
+	allLease, _ := store.LoadLeaseAll()
+	for _, dLease := range allLease {
+		if dLease.Uuid == lease.Uuid {
+			found = true
+			if dLease.SubId != lease.SubId || dLease.Expire.Unix() != lease.Expire.Unix() || dLease.Released != true {
+				t.Fatalf("0x6f2a1d48 db error")
+			}
+		}
+	}
+	if found != true {
+		t.Fatalf("0x1c83e5b0 db error")
+	}
 }
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"log"
+	"sort"
+	"time"
+)
+
+// DrainCfgT repeater 排空: 不再返回给客户端，租约、keepalive 照常，到期自动取消
+type DrainCfgT struct {
+	Deadline DurationT `json:"deadline"` // 未指定时的排空时长
+	Max      DurationT `json:"max"`      // 可指定的最长排空时长
+}
+
+func (cfg *DrainCfgT) check() error {
+	if cfg.Deadline <= 0 || cfg.Max < cfg.Deadline {
+		return errors.New(fmt.Sprintf("0x2b7e94d0 invalid drain deadline(%s) or max(%s)",
+			time.Duration(cfg.Deadline), time.Duration(cfg.Max)))
+	}
+	return nil
+}
+
+// DrainStatT 排空中的 repeater，assigned 降为 0 或过了 safeAt 后可安全停止
+type DrainStatT struct {
+	Uuid          string    `json:"uuid"`
+	IP            string    `json:"ip,omitempty"`
+	Since         time.Time `json:"since"`
+	Until         time.Time `json:"until"`         // 到期自动取消排空
+	DrainAssigned int       `json:"drainAssigned"` // 开始排空时分配到的客户端数
+	Assigned      int       `json:"assigned"`      // 仍分配到的客户端数，客户端再次请求或分配超时后减少
+	SafeAt        time.Time `json:"safeAt"`        // 最后一个分配超时释放的时间，assigned 为 0 时为当前时间
+	Safe          bool      `json:"safe"`
+}
+
+// draining 调用方需持有 dataMtx
+func (mgr *nodeMgrT) draining(uuid string, now time.Time) bool {
+	attr, ok := mgr.attrMap[uuid]
+	return ok && now.Before(attr.DrainUntil)
+}
+
+// drainSafeAt 分配到该 repeater 的客户端最晚在何时超时释放，没有时返回 now，调用方需持有 dataMtx
+func (mgr *nodeMgrT) drainSafeAt(uuid string, now time.Time) time.Time {
+	safeAt := now
+	for _, assign := range mgr.assignMap {
+		for _, id := range assign.repeaterLst {
+			if id != uuid {
+				continue
+			}
+			if ts := assign.ts.Add(time.Duration(mgr.repeater.AssignTTL)); ts.After(safeAt) {
+				safeAt = ts
+			}
+		}
+	}
+	return safeAt
+}
+
+// nodeDrain 开始或取消排空，deadline 为 0 时用 drain.deadline；已在排空中的只延长到期时间
+func (mgr *nodeMgrT) nodeDrain(uuid string, on bool, deadline time.Duration, now time.Time) (NodeT, NodeT, error) {
+	if deadline == 0 {
+		deadline = time.Duration(mgr.drain.Deadline)
+	}
+	if deadline < 0 || deadline > time.Duration(mgr.drain.Max) {
+		return NodeT{}, NodeT{}, errors.New(fmt.Sprintf("0x61c0f3a8 invalid drain deadline(%s), max:%s", deadline, time.Duration(mgr.drain.Max)))
+	}
+
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	node, err := mgr.nodeLookup(uuid)
+	if err != nil {
+		return NodeT{}, NodeT{}, err
+	}
+	if on && node.RoleType != int(proto.Role_Repeater) {
+		return NodeT{}, NodeT{}, errors.New(fmt.Sprintf("0x4e19a7d3 uuid(%s) role(%d) is not repeater", uuid, node.RoleType))
+	}
+	before := mgr.nodeView(node)
+	draining := mgr.draining(uuid, now)
+	err = mgr.setNodeAttr(uuid, now, func(attr *NodeAttrT) {
+		switch {
+		case !on:
+			attr.DrainSince, attr.DrainUntil, attr.DrainAssigned = time.Time{}, time.Time{}, 0
+		case draining:
+			attr.DrainUntil = now.Add(deadline)
+		default:
+			attr.DrainSince, attr.DrainUntil, attr.DrainAssigned = now, now.Add(deadline), mgr.assignCntMap[uuid]
+		}
+	})
+	if err != nil {
+		return NodeT{}, NodeT{}, err
+	}
+	mgr.refreshRepeaterVer(now)
+	return before, mgr.nodeView(node), nil
+}
+
+// expireDrain 取消到期的排空，调用方需持有 dataMtx
+func (mgr *nodeMgrT) expireDrain(now time.Time) {
+	for uuid, attr := range mgr.attrMap {
+		if attr.DrainUntil.IsZero() || now.Before(attr.DrainUntil) {
+			continue
+		}
+		err := mgr.setNodeAttr(uuid, now, func(attr *NodeAttrT) {
+			attr.DrainSince, attr.DrainUntil, attr.DrainAssigned = time.Time{}, time.Time{}, 0
+		})
+		if err != nil {
+			log.Printf("ERROR 0x3d85b0e2 expire drain of uuid(%s) fail:%s", uuid, err)
+			continue
+		}
+		log.Printf("LOG 0x7a20c6f9 drain of uuid(%s) expired", uuid)
+	}
+}
+
+// DrainGetAll 排空中的 repeater，按 uuid 排序
+func DrainGetAll() []DrainStatT {
+	nodeMgr.dataMtx.Lock()
+	defer nodeMgr.dataMtx.Unlock()
+
+	now := time.Now()
+	lst := make([]DrainStatT, 0)
+	for uuid, attr := range nodeMgr.attrMap {
+		if !nodeMgr.draining(uuid, now) {
+			continue
+		}
+		stat := DrainStatT{Uuid: uuid, Since: attr.DrainSince, Until: attr.DrainUntil, DrainAssigned: attr.DrainAssigned,
+			Assigned: nodeMgr.assignCntMap[uuid], SafeAt: nodeMgr.drainSafeAt(uuid, now)}
+		if node, ok := nodeMgr.nodeUuidMap[uuid]; ok {
+			stat.IP = nodeAddr(node)
+		}
+		stat.Safe = stat.Assigned == 0
+		lst = append(lst, stat)
+	}
+	sort.Slice(lst, func(i, j int) bool { return lst[i].Uuid < lst[j].Uuid })
+	return lst
+}
+
+// AdminNodeDrain {"drain": true, "deadline": "30m"}
+func AdminNodeDrain(c *gin.Context) {
+	adminNodeAction(c, ADMIN_ACTION_DRAIN, func(uuid string, req *AdminNodeReqT, now time.Time) (NodeT, NodeT, error) {
+		return nodeMgr.nodeDrain(uuid, req.Drain, time.Duration(req.Deadline), now)
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDrainNode(t *testing.T) {
+	NodeMgrInit(NewMemStore(), configDefault())
+	t.Cleanup(nodeMgr.stopReaper)
+	if _, _, err := nodeMgr.bootNode("uuid-r1", "8.8.8.8", "v1", 0); err != nil {
+		t.Fatalf("0x7d1ed8e7 boot fail:%v", err)
+	}
+	if _, _, err := nodeMgr.bootNode("uuid-r2", "8.8.4.4", "v1", 0); err != nil {
+		t.Fatalf("0x30985db8 boot fail:%v", err)
+	}
+	now := time.Now()
+	for _, client := range []string{"client-a", "client-b"} {
+		if lst := nodeMgr.assignRepeater(client, "", now); len(lst) != 2 {
+			t.Fatalf("0x5a0e7c13 assign:%d", len(lst))
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/v1/admin/node/:uuid/drain", AdminNodeDrain)
+	call := func(uuid, body string) (int, NodeT) {
+		rsp := httptest.NewRecorder()
+		r.ServeHTTP(rsp, httptest.NewRequest(http.MethodPost, "/v1/admin/node/"+uuid+"/drain", strings.NewReader(body)))
+		var node NodeT
+		json.Unmarshal(rsp.Body.Bytes(), &node)
+		return rsp.Code, node
+	}
+
+	if code, _ := call("uuid-r1", `{"drain": true, "deadline": "48h"}`); code != http.StatusConflict {
+		t.Fatalf("0x2e94b0d7 deadline over max code:%d", code)
+	}
+	code, node := call("uuid-r1", `{"drain": true, "deadline": "30m"}`)
+	if code != http.StatusOK || !node.Drain {
+		t.Fatalf("0x71c3a5e8 drain code:%d, node:%+v", code, node)
+	}
+
+	// 排空中的不再返回给客户端，之前分配的客户端再次请求时换掉
+	lst := nodeMgr.assignRepeater("client-a", "", now)
+	if len(lst) != 1 || lst[0].Uuid != "uuid-r2" {
+		t.Fatalf("0x0b58f2c6 draining repeater assigned:%+v", lst)
+	}
+	drainLst := DrainGetAll()
+	if len(drainLst) != 1 || drainLst[0].DrainAssigned != 2 || drainLst[0].Assigned != 1 || drainLst[0].Safe ||
+		drainLst[0].SafeAt.Before(now.Add(time.Minute*29)) {
+		t.Fatalf("0x48d1e7a0 drain stat:%+v", drainLst)
+	}
+
+	// 租约、keepalive 照常
+	if n, _, err := nodeMgr.bootNode("uuid-r1", "8.8.8.8", "v1", 0); err != nil || !n.LeaseExpire.After(now) {
+		t.Fatalf("0x3f27c9b4 boot draining node:%+v, err:%v", n, err)
+	}
+
+	// 到期自动取消
+	nodeMgr.scanDeadNode(now.Add(time.Minute * 31))
+	if lst := DrainGetAll(); len(lst) != 0 {
+		t.Fatalf("0x6c90d1e3 drain not expired:%+v", lst)
+	}
+	if attr := nodeMgr.attrMap["uuid-r1"]; !attr.DrainUntil.IsZero() || attr.DrainAssigned != 0 {
+		t.Fatalf("0x1d7ae52f drain attr after expired:%+v", attr)
+	}
+}
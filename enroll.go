@@ -0,0 +1,396 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// 登记申请的状态，同时作为 MsgEventRsp 的 6 号字段返回给未通过审批的 node
+const (
+	ENROLL_STATUS_PENDING  = "pending"
+	ENROLL_STATUS_APPROVED = "approved"
+	ENROLL_STATUS_REJECTED = "rejected"
+
+	enrollTokenLen = 16
+)
+
+// EnrollCfgT 登记审批，开启后未知的 uuid 需审批或持有一次性 token 才分配子网号
+type EnrollCfgT struct {
+	Approval   bool      `json:"approval"`   // 开启登记审批，默认关闭(首次 STARTED 即分配)
+	MaxPending int       `json:"maxPending"` // 待审批队列的上限，超出的申请不入队
+	TokenTTL   DurationT `json:"tokenTtl"`   // 一次性 token 的默认有效期
+}
+
+func (cfg *EnrollCfgT) check() error {
+	if cfg.MaxPending <= 0 || cfg.TokenTTL <= 0 {
+		return errors.New(fmt.Sprintf("0x2b6f0d93 invalid enroll maxPending(%d) or tokenTtl(%s)", cfg.MaxPending, time.Duration(cfg.TokenTTL)))
+	}
+	return nil
+}
+
+func enrollTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// enrollKnown 已分配过子网号、有预留或已审批通过的 uuid 不需要审批，调用方需持有 dataMtx
+func (mgr *nodeMgrT) enrollKnown(uuid string) bool {
+	if _, ok := mgr.nodeUuidMap[uuid]; ok {
+		return true
+	}
+	if _, ok := mgr.reserveUuidMap[uuid]; ok {
+		return true
+	}
+	if _, ok := mgr.stickyMap[uuid]; ok {
+		return true
+	}
+	return mgr.enrollApproved(uuid)
+}
+
+// checkEnrollToken 校验一次性 token(不消耗)，返回其 hash，调用方需持有 dataMtx
+func (mgr *nodeMgrT) checkEnrollToken(uuid, token string, now time.Time) (string, error) {
+	hash := enrollTokenHash(token)
+	t, ok := mgr.tokenMap[hash]
+	if !ok {
+		return hash, errors.New(fmt.Sprintf("0x5d08c7e1 uuid(%s) unknown enroll token", uuid))
+	}
+	if now.After(t.Expire) {
+		return hash, errors.New(fmt.Sprintf("0x11a9f4b6 uuid(%s) enroll token expired at %s", uuid, t.Expire.Format(time.RFC3339)))
+	}
+	if len(t.Uuid) > 0 && t.Uuid != uuid {
+		return hash, errors.New(fmt.Sprintf("0x6c3e1a58 enroll token bound to uuid(%s), not %s", t.Uuid, uuid))
+	}
+	return hash, nil
+}
+
+// useEnrollToken 校验并消耗一次性 token，调用方需持有 dataMtx
+func (mgr *nodeMgrT) useEnrollToken(uuid, token string, now time.Time) error {
+	hash, err := mgr.checkEnrollToken(uuid, token, now)
+	if err != nil {
+		return err
+	}
+
+	err = mgr.store.DeleteEnrollToken(hash)
+	if err != nil {
+		return err
+	}
+	delete(mgr.tokenMap, hash)
+	return nil
+}
+
+// enrollCheck 登记审批，返回 approved 时按原流程分配子网号，否则返回 pending/rejected 及需记录的事件
+// 未知的 uuid 持有效 token 时直接通过，否则进入待审批队列；
+// 未开启审批或已知的 uuid 总是 approved，带的 token 只用于登记(之后从使用 token 的地址 boot 时才签发密钥，见 authIssuable)
+func (mgr *nodeMgrT) enrollCheck(uuid, ip, ver, token string, now time.Time) (string, *EventItemDBT) {
+	if !mgr.enroll.Approval && len(token) == 0 {
+		return ENROLL_STATUS_APPROVED, nil
+	}
+
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	known := !mgr.enroll.Approval || mgr.enrollKnown(uuid)
+	enroll, exist := mgr.enrollMap[uuid]
+	if (exist && enroll.Status == ENROLL_STATUS_APPROVED) || (known && len(token) == 0) {
+		return ENROLL_STATUS_APPROVED, nil
+	}
+
+	e := &EventItemDBT{Uuid: uuid, IP: ip, TS: now, Ver: ver}
+	if !exist {
+		enroll = &EnrollT{Uuid: uuid, Status: ENROLL_STATUS_PENDING, First: now}
+	}
+	if len(token) > 0 {
+		err := mgr.useEnrollToken(uuid, token, now)
+		if err == nil {
+			enroll.Status = ENROLL_STATUS_APPROVED
+			enroll.Note = "token"
+			e.EType, e.EMsg = EVENT_ENROLL_APPROVED, "approved by token"
+		} else {
+			log.Printf("WARN 0x3a71d2c9 %s, ip:%s", err, ip)
+		}
+	}
+	if known && enroll.Status != ENROLL_STATUS_APPROVED {
+		return ENROLL_STATUS_APPROVED, nil
+	}
+	if !exist && enroll.Status == ENROLL_STATUS_PENDING {
+		if mgr.enrollPendingCnt() >= mgr.enroll.MaxPending {
+			log.Printf("WARN 0x48e5b0a7 enroll pending queue full(%d), drop uuid(%s) ip:%s", mgr.enroll.MaxPending, uuid, ip)
+			return ENROLL_STATUS_PENDING, nil
+		}
+		e.EType, e.EMsg = EVENT_ENROLL_PENDING, "waiting for approval"
+	}
+
+	enroll.IP = ip
+	enroll.Ver = ver
+	enroll.Last = now
+	enroll.Count++
+	err := mgr.store.UpsertEnroll(enroll)
+	if err != nil {
+		log.Printf("ERROR 0x7e2c85f1 save enroll fail:%s", err)
+	}
+	mgr.enrollMap[uuid] = enroll
+	if e.EType == 0 {
+		e = nil
+	}
+	if known {
+		return ENROLL_STATUS_APPROVED, e
+	}
+	return enroll.Status, e
+}
+
+// enrollApproved 审批通过或使用过 token 的 uuid，调用方需持有 dataMtx
+func (mgr *nodeMgrT) enrollApproved(uuid string) bool {
+	enroll, ok := mgr.enrollMap[uuid]
+	return ok && enroll.Status == ENROLL_STATUS_APPROVED
+}
+
+// enrollApprovedAt 审批通过或使用过 token，且申请(或使用 token)时的地址为 ip，调用方需持有 dataMtx
+func (mgr *nodeMgrT) enrollApprovedAt(uuid, ip string) bool {
+	enroll, ok := mgr.enrollMap[uuid]
+	return ok && enroll.Status == ENROLL_STATUS_APPROVED && len(enroll.IP) > 0 && enroll.IP == ip
+}
+
+// enrollPendingCnt 调用方需持有 dataMtx
+func (mgr *nodeMgrT) enrollPendingCnt() int {
+	cnt := 0
+	for _, enroll := range mgr.enrollMap {
+		if enroll.Status == ENROLL_STATUS_PENDING {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// enrollSet 审批通过或拒绝，uuid 不在队列中时也可预先审批
+func (mgr *nodeMgrT) enrollSet(uuid, status, note string, now time.Time) (*EnrollT, error) {
+	if len(uuid) == 0 {
+		return nil, errors.New(fmt.Sprintf("0x0d4b96e3 invalid enroll uuid"))
+	}
+
+	mgr.dataMtx.Lock()
+	enroll, exist := mgr.enrollMap[uuid]
+	if !exist {
+		enroll = &EnrollT{Uuid: uuid, First: now}
+	}
+	e := *enroll
+	e.Status = status
+	e.Last = now
+	if len(note) > 0 {
+		e.Note = note
+	}
+	err := mgr.store.UpsertEnroll(&e)
+	if err == nil {
+		mgr.enrollMap[uuid] = &e
+	}
+	mgr.dataMtx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	eType := EVENT_ENROLL_APPROVED
+	if status == ENROLL_STATUS_REJECTED {
+		eType = EVENT_ENROLL_REJECTED
+	}
+	log.Printf("LOG 0x29f7a0c4 enroll uuid(%s) %s", uuid, status)
+	mgr.recordEvent(&EventItemDBT{Uuid: uuid, IP: e.IP, TS: now, Ver: e.Ver, EType: eType, EMsg: fmt.Sprintf("%s by admin %s", status, note)})
+	return &e, nil
+}
+
+// enrollDel 删除申请记录，被拒绝的 uuid 下次 STARTED 时重新进入队列
+func (mgr *nodeMgrT) enrollDel(uuid string) error {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	if _, ok := mgr.enrollMap[uuid]; !ok {
+		return errors.New(fmt.Sprintf("0x64f1b28d enroll not found, uuid:%s", uuid))
+	}
+	err := mgr.store.DeleteEnrollByUuid(uuid)
+	if err != nil {
+		return err
+	}
+	delete(mgr.enrollMap, uuid)
+	return nil
+}
+
+// enrollGetAll status 为空时返回所有申请，按首次申请时间排序
+func (mgr *nodeMgrT) enrollGetAll(status string) []EnrollT {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	lst := make([]EnrollT, 0)
+	for _, enroll := range mgr.enrollMap {
+		if len(status) == 0 || enroll.Status == status {
+			lst = append(lst, *enroll)
+		}
+	}
+	sort.Slice(lst, func(i, j int) bool { return lst[i].First.Before(lst[j].First) })
+	return lst
+}
+
+// enrollTokenNew 生成一次性 token，只返回这一次，之后只能看到其 hash
+func (mgr *nodeMgrT) enrollTokenNew(uuid string, ttl time.Duration, note string, now time.Time) (string, *EnrollTokenT, error) {
+	if ttl <= 0 {
+		ttl = time.Duration(mgr.enroll.TokenTTL)
+	}
+	buf := make([]byte, enrollTokenLen)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", nil, errors.New(fmt.Sprintf("0x1f9d6a42 gen enroll token fail:%s", err))
+	}
+	token := hex.EncodeToString(buf)
+	t := &EnrollTokenT{Hash: enrollTokenHash(token), Uuid: uuid, Expire: now.Add(ttl), Created: now, Note: note}
+
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	err = mgr.store.UpsertEnrollToken(t)
+	if err != nil {
+		return "", nil, err
+	}
+	mgr.tokenMap[t.Hash] = t
+	return token, t, nil
+}
+
+func (mgr *nodeMgrT) enrollTokenDel(hash string) error {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	if _, ok := mgr.tokenMap[hash]; !ok {
+		return errors.New(fmt.Sprintf("0x7b0e3c56 enroll token not found, hash:%s", hash))
+	}
+	err := mgr.store.DeleteEnrollToken(hash)
+	if err != nil {
+		return err
+	}
+	delete(mgr.tokenMap, hash)
+	return nil
+}
+
+// enrollTokenGetAll 顺带清理已过期的 token
+func (mgr *nodeMgrT) enrollTokenGetAll(now time.Time) []EnrollTokenT {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	lst := make([]EnrollTokenT, 0, len(mgr.tokenMap))
+	for hash, t := range mgr.tokenMap {
+		if now.After(t.Expire) {
+			if err := mgr.store.DeleteEnrollToken(hash); err == nil {
+				delete(mgr.tokenMap, hash)
+			}
+			continue
+		}
+		lst = append(lst, *t)
+	}
+	sort.Slice(lst, func(i, j int) bool { return lst[i].Created.Before(lst[j].Created) })
+	return lst
+}
+
+// enrollRsp 未通过审批时的响应，不含网络参数
+func enrollRsp(msg *proto.MsgEventPost, status string) *proto.MsgEventRsp {
+	rsp := &proto.MsgEventRsp{
+		Event:   msg.Event,
+		Machine: &proto.Machine{UUID: msg.GetMachine().GetUUID()},
+		Status:  status,
+	}
+	return rsp
+}
+
+// AdminEnrollGet ?status=pending|approved|rejected
+func AdminEnrollGet(c *gin.Context) {
+	c.JSON(http.StatusOK, nodeMgr.enrollGetAll(c.Query("status")))
+}
+
+type AdminEnrollReqT struct {
+	Note string `json:"note"`
+}
+
+func adminEnrollSet(c *gin.Context, status string) {
+	var req AdminEnrollReqT
+	// body 可以为空
+	if c.Request.ContentLength != 0 {
+		err := c.ShouldBindJSON(&req)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, &AdminRspT{Err: err.Error()})
+			return
+		}
+	}
+
+	enroll, err := nodeMgr.enrollSet(c.Param("uuid"), status, req.Note, time.Now())
+	if err != nil {
+		log.Printf("ERROR 0x0c8a5e17 set enroll fail:%s", err)
+		c.JSON(http.StatusBadRequest, &AdminRspT{Err: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, enroll)
+}
+
+// AdminEnrollApprove 审批通过，该 node 下次 STARTED 时分配子网号
+func AdminEnrollApprove(c *gin.Context) {
+	adminEnrollSet(c, ENROLL_STATUS_APPROVED)
+}
+
+func AdminEnrollReject(c *gin.Context) {
+	adminEnrollSet(c, ENROLL_STATUS_REJECTED)
+}
+
+func AdminEnrollDel(c *gin.Context) {
+	err := nodeMgr.enrollDel(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, &AdminRspT{Err: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, &AdminRspT{})
+}
+
+type AdminEnrollTokenReqT struct {
+	Uuid string    `json:"uuid"` // 为空时任意 uuid 可用
+	TTL  DurationT `json:"ttl"`  // 为空时为 enroll.tokenTtl
+	Note string    `json:"note"`
+}
+
+type AdminEnrollTokenRspT struct {
+	Token string `json:"token"`
+	EnrollTokenT
+}
+
+func AdminEnrollTokenGet(c *gin.Context) {
+	c.JSON(http.StatusOK, nodeMgr.enrollTokenGetAll(time.Now()))
+}
+
+// AdminEnrollTokenNew 生成一次性 token，node 在 STARTED 消息的 6 号字段中带上
+func AdminEnrollTokenNew(c *gin.Context) {
+	var req AdminEnrollTokenReqT
+	if c.Request.ContentLength != 0 {
+		err := c.ShouldBindJSON(&req)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, &AdminRspT{Err: err.Error()})
+			return
+		}
+	}
+
+	token, t, err := nodeMgr.enrollTokenNew(req.Uuid, time.Duration(req.TTL), req.Note, time.Now())
+	if err != nil {
+		log.Printf("ERROR 0x5a27d4e0 new enroll token fail:%s", err)
+		c.JSON(http.StatusInternalServerError, &AdminRspT{Err: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, &AdminEnrollTokenRspT{Token: token, EnrollTokenT: *t})
+}
+
+func AdminEnrollTokenDel(c *gin.Context) {
+	err := nodeMgr.enrollTokenDel(c.Param("hash"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, &AdminRspT{Err: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, &AdminRspT{})
+}
@@ -8,11 +8,61 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"strconv"
+	"time"
 )
 
+// nodeMgr 自己产生的事件类型，与 proto.Event 共用 eventType 字段，从 2000 开始
+const (
+	EVENT_NODE_ONLINE  = 2000 // 存活状态切换
+	EVENT_NODE_SUSPECT = 2001
+	EVENT_NODE_OFFLINE = 2002
+	EVENT_NODE_EXPIRED = 2003
+
+	// 只推送(EventStream)，不写入事件表
+	EVENT_NODE_JOIN   = 2004 // 新 node 上线
+	EVENT_NODE_ROLE   = 2005 // 角色变化(同时换了子网号)
+	EVENT_NODE_SUBNET = 2006 // 子网号变化
+
+	EVENT_AUTH_REJECT = 2007 // 安全事件: 节点消息签名校验失败
+
+	EVENT_ENROLL_PENDING  = 2008 // 未知 uuid 进入待审批队列
+	EVENT_ENROLL_APPROVED = 2009 // 审批通过或使用了一次性 token
+	EVENT_ENROLL_REJECTED = 2010
+
+	EVENT_NODE_EVICT = 2011 // 管理员驱逐 node，释放子网号
+)
+
+var eventLocalName = map[int]string{
+	EVENT_NODE_ONLINE:  "NODE_ONLINE",
+	EVENT_NODE_SUSPECT: "NODE_SUSPECT",
+	EVENT_NODE_OFFLINE: "NODE_OFFLINE",
+	EVENT_NODE_EXPIRED: "NODE_EXPIRED",
+	EVENT_NODE_JOIN:    "NODE_JOIN",
+	EVENT_NODE_ROLE:    "NODE_ROLE",
+	EVENT_NODE_SUBNET:  "NODE_SUBNET",
+	EVENT_AUTH_REJECT:  "AUTH_REJECT",
+
+	EVENT_ENROLL_PENDING:  "ENROLL_PENDING",
+	EVENT_ENROLL_APPROVED: "ENROLL_APPROVED",
+	EVENT_ENROLL_REJECTED: "ENROLL_REJECTED",
+	EVENT_NODE_EVICT:      "NODE_EVICT",
+}
+
 type EventHelpT struct {
-	Text string `json:Text`
+	Text string `json:"Text"`
+}
+
+// newNodeEvent 根据上报的消息生成一条事件记录
+func newNodeEvent(ip string, role proto.Role, addMsg string, event *proto.MsgEventPost) *EventItemDBT {
+	return &EventItemDBT{
+		Uuid:     event.GetMachine().GetUUID(),
+		IP:       ip,
+		RoleType: int(role),
+		TS:       time.Now(),
+		Ver:      event.GetNode().GetVer(),
+		EType:    int(event.GetEvent()),
+		EMsg:     addMsg,
+	}
 }
 
 func EventPost(c *gin.Context) {
@@ -28,11 +78,12 @@ func EventPost(c *gin.Context) {
 	err = pb.Unmarshal(bodyBytes, &msg)
 	if err != nil {
 		log.Printf("0x5a9debca Invalid request body(%v), ip:%s", bodyBytes, ip)
+		metricDecodeFail.WithLabelValues("EventPost").Inc()
 		return
 	}
 
 	{
-		jBuf, _ := json.Marshal(msg)
+		jBuf, _ := json.Marshal(&msg)
 		log.Printf("0x09d8bb7d recv a event:[%s], cli:%s", string(jBuf), ip)
 	}
 
@@ -43,12 +94,19 @@ func EventPost(c *gin.Context) {
 	}
 
 	event := msg.GetEvent()
+	metricEventTotal.WithLabelValues(event.String()).Inc()
+	if !nodeMgr.authNode(ip, &msg, bodyBytes, c.GetHeader(AUTH_SIGN_HEADER)) {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
 	if event == proto.Event_STARTED {
 		NodeBootEvent(c, &msg)
 	} else if event == proto.Event_KEEPALIVE {
 		NodePingEvent(c, &msg)
 	} else if event == proto.Event_PINGLOSTPERCENT20 || event == proto.Event_PINGACKNULL {
 		NodeAbnormalEvent(c, &msg)
+	} else if event == proto.Event_CLOSED {
+		NodeCloseEvent(c, &msg)
 	} else {
 		log.Printf("0x1ae4262b recv invalid event(%s)", event)
 		return
@@ -56,45 +114,56 @@ func EventPost(c *gin.Context) {
 }
 
 func EventGet(c *gin.Context) {
-	eLst, err := SelectEventAll()
+	q, err := parseEventQuery(c)
 	if err != nil {
-		log.Printf("0x4111d800 SelectEventAll fail: %s", err)
+		c.JSON(http.StatusBadRequest, &AdminRspT{Err: err.Error()})
 		return
 	}
 
-	/* 过滤出指定类型的数据 */
-	typeArg := c.Query("type")
-	typeInt := -1
-	var tLst []*EventItemDBT
-	if len(typeArg) > 0 {
-		typeInt, err = strconv.Atoi(typeArg)
-		if err != nil {
-			typeInt = -1
-		}
-	}
-	if typeInt != -1 {
-		for _, event := range eLst {
-			if event.EType == typeInt {
-				tLst = append(tLst, event)
-			}
-		}
-	} else {
-		tLst = eLst
+	page, err := nodeMgr.store.SelectEvent(q)
+	if err != nil {
+		log.Printf("0x4111d800 SelectEvent fail: %s", err)
+		c.JSON(http.StatusInternalServerError, &AdminRspT{Err: err.Error()})
+		return
 	}
-	log.Printf("DEBUG 0x52dce36d eType: %d", typeInt)
 
-	c.JSON(http.StatusOK, tLst)
+	c.JSON(http.StatusOK, page)
 }
 
 func EventHelp(c *gin.Context) {
 	textHelp := `
 	OPTIONS
-    	 --type
+    	 --type     事件类型(数值或名称，不区分大小写)，可重复(type=0&type=1000)或逗号分隔(type=0,PINGACKNULL)
 			STARTED: 0
 			KEEPALIVE: 1
 			PINGLOSTPERCENT20: 1000
 			PINGACKNULL: 1001
 			CLOSED: 65535
+			NODE_ONLINE: 2000
+			NODE_SUSPECT: 2001
+			NODE_OFFLINE: 2002
+			NODE_EXPIRED: 2003
+			NODE_JOIN: 2004(仅推送)
+			NODE_ROLE: 2005(仅推送)
+			NODE_SUBNET: 2006(仅推送)
+			AUTH_REJECT: 2007
+			ENROLL_PENDING: 2008
+			ENROLL_APPROVED: 2009
+			ENROLL_REJECTED: 2010
+			NODE_EVICT: 2011
+    	 --uuid     node 的 uuid
+    	 --ip       上报事件的 ip
+    	 --role     角色，名称或数值(Default: 0, Pac: 1, Repeater: 1000)
+    	 --ver      node 的版本
+    	 --since    起始时间(含)，RFC3339 或毫秒时间戳
+    	 --until    结束时间(不含)，RFC3339 或毫秒时间戳
+    	 --q        eventMsg 中包含的文本
+    	 --order    asc(默认，旧的在前) 或 desc
+    	 --limit    每页条数，默认 100，最大 1000
+    	 --cursor   上一页返回的 nextCursor，不填则从第一页开始
+
+	RESPONSE
+		{"total": 满足条件的总条数, "nextCursor": 下一页游标(没有下一页时不返回), "events": [...]}
 	`
 
 	txt := &EventHelpT{
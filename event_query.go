@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EVENT_QUERY_LIMIT_DEFAULT = 100
+	EVENT_QUERY_LIMIT_MAX     = 1000
+)
+
+// EventQueryT 事件查询条件，零值的字段不参与过滤
+type EventQueryT struct {
+	Uuid  string
+	IP    string
+	Role  int // -1:不过滤
+	Ver   string
+	Types []int     // 事件类型，任一匹配即可
+	Since time.Time // ts >= Since
+	Until time.Time // ts < Until
+	Text  string    // eventMsg 包含的文本
+
+	Cursor int64 // 上一页返回的 nextCursor，0:从头开始
+	Limit  int
+	Desc   bool // true:按 id 倒序(新的在前)
+}
+
+// EventPageT 一页查询结果，Total 为满足过滤条件的总条数(不受分页影响)
+type EventPageT struct {
+	Total      int             `json:"total"`
+	NextCursor int64           `json:"nextCursor,omitempty"` // 0:没有下一页
+	Events     []*EventItemDBT `json:"events"`
+}
+
+// parseEventTime 支持 RFC3339 和毫秒时间戳(与 MsgEventPost.Ts 一致)
+func parseEventTime(s string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// parseEventQuery 从 url 参数中解析查询条件，参数说明见 EventHelp
+func parseEventQuery(c *gin.Context) (*EventQueryT, error) {
+	q := &EventQueryT{Role: -1, Limit: EVENT_QUERY_LIMIT_DEFAULT}
+	q.Uuid = c.Query("uuid")
+	q.IP = c.Query("ip")
+	q.Ver = c.Query("ver")
+	q.Text = c.Query("q")
+
+	if arg := c.Query("role"); len(arg) > 0 {
+		role, err := strconv.Atoi(arg)
+		if err != nil {
+			role, err = parseRole(arg)
+			if err != nil {
+				return nil, err
+			}
+		}
+		q.Role = role
+	}
+
+	// type=0&type=1000 或 type=0,PINGACKNULL
+	for _, arg := range c.QueryArray("type") {
+		for _, s := range strings.Split(arg, ",") {
+			if len(s) == 0 {
+				continue
+			}
+			eType, err := parseEventType(s)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("0x3b8d0e52 invalid type(%s)", s))
+			}
+			q.Types = append(q.Types, eType)
+		}
+	}
+
+	var err error
+	if arg := c.Query("since"); len(arg) > 0 {
+		q.Since, err = parseEventTime(arg)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("0x1f6e9c37 invalid since(%s)", arg))
+		}
+	}
+	if arg := c.Query("until"); len(arg) > 0 {
+		q.Until, err = parseEventTime(arg)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("0x64a2d7f0 invalid until(%s)", arg))
+		}
+	}
+
+	if arg := c.Query("cursor"); len(arg) > 0 {
+		q.Cursor, err = strconv.ParseInt(arg, 10, 64)
+		if err != nil || q.Cursor < 0 {
+			return nil, errors.New(fmt.Sprintf("0x2ac5813e invalid cursor(%s)", arg))
+		}
+	}
+	if arg := c.Query("limit"); len(arg) > 0 {
+		q.Limit, err = strconv.Atoi(arg)
+		if err != nil || q.Limit <= 0 {
+			return nil, errors.New(fmt.Sprintf("0x5e07b4c9 invalid limit(%s)", arg))
+		}
+		if q.Limit > EVENT_QUERY_LIMIT_MAX {
+			q.Limit = EVENT_QUERY_LIMIT_MAX
+		}
+	}
+
+	switch strings.ToLower(c.DefaultQuery("order", "asc")) {
+	case "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return nil, errors.New(fmt.Sprintf("0x7d49f2a1 invalid order(%s)", c.Query("order")))
+	}
+
+	return q, nil
+}
+
+// match 不含分页条件，供不支持 sql 的 Store 实现使用
+func (q *EventQueryT) match(e *EventItemDBT) bool {
+	if len(q.Uuid) > 0 && e.Uuid != q.Uuid {
+		return false
+	}
+	if len(q.IP) > 0 && e.IP != q.IP {
+		return false
+	}
+	if q.Role >= 0 && e.RoleType != q.Role {
+		return false
+	}
+	if len(q.Ver) > 0 && e.Ver != q.Ver {
+		return false
+	}
+	if len(q.Types) > 0 {
+		found := false
+		for _, eType := range q.Types {
+			if e.EType == eType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !q.Since.IsZero() && e.TS.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.TS.Before(q.Until) {
+		return false
+	}
+	if len(q.Text) > 0 && !strings.Contains(e.EMsg, q.Text) {
+		return false
+	}
+	return true
+}
+
+// eventPagerT 按查询的顺序(q.Desc)逐条传入事件，过滤、分页
+type eventPagerT struct {
+	q   *EventQueryT
+	ret *EventPageT
+}
+
+func (q *EventQueryT) pager() *eventPagerT {
+	return &eventPagerT{q: q, ret: &EventPageT{Events: make([]*EventItemDBT, 0)}}
+}
+
+func (p *eventPagerT) add(e *EventItemDBT) {
+	q, ret := p.q, p.ret
+	if !q.match(e) {
+		return
+	}
+	ret.Total++
+
+	if q.Cursor > 0 && ((!q.Desc && e.Id <= q.Cursor) || (q.Desc && e.Id >= q.Cursor)) {
+		return
+	}
+	if len(ret.Events) < q.Limit {
+		ret.Events = append(ret.Events, e)
+	} else if ret.NextCursor == 0 {
+		ret.NextCursor = ret.Events[len(ret.Events)-1].Id
+	}
+}
+
+// page 对按 id 升序排列的全部事件过滤、分页
+func (q *EventQueryT) page(allLst []*EventItemDBT) *EventPageT {
+	pager := q.pager()
+	for i := range allLst {
+		e := allLst[i]
+		if q.Desc {
+			e = allLst[len(allLst)-1-i]
+		}
+		pager.add(e)
+	}
+	return pager.ret
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseEventQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	parse := func(rawQuery string) (*EventQueryT, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/v1/event?"+rawQuery, nil)
+		return parseEventQuery(c)
+	}
+
+	q, err := parse("type=0,PINGACKNULL&type=keepalive&role=Repeater&order=desc")
+	if err != nil || len(q.Types) != 3 || q.Types[1] != 1001 || q.Types[2] != 1 || q.Role != 1000 || !q.Desc {
+		t.Fatalf("0x2d5b8e61 parse query:%+v, err:%v", q, err)
+	}
+	if _, err = parse("type=NOT_EXIST"); err == nil {
+		t.Fatalf("0x7c40a1f3 invalid type accepted")
+	}
+}
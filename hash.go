@@ -0,0 +1,208 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// repeater 的挑选方式
+const (
+	REPEATER_SELECT_HASH = "hash" // 按客户端 uuid 做带权重的 rendezvous hash，repeater 增减时只影响最少的客户端
+	REPEATER_SELECT_LOAD = "load" // 按 (已分配数+1)/权重 挑选，之前的分配仍可用时沿用
+)
+
+// rendezvousScore 带权重的 rendezvous hash(HRW)，分数越大越优先
+// score = weight / -ln(u)，u 为 (client, repeater) 的 hash 映射到 (0, 1)
+func rendezvousScore(client, repeater string, weight int) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(client))
+	h.Write([]byte{0})
+	h.Write([]byte(repeater))
+	// fnv 的低位分布不均，再混淆一次(splitmix64)
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	u := (float64(x>>11) + 0.5) / float64(uint64(1)<<53)
+	return float64(weight) / -math.Log(u)
+}
+
+type hashCandT struct {
+	cand  *repeaterCandT
+	full  bool
+	score float64
+}
+
+// hashOrder 按客户端排序: 健康的在前，region 优先的在前，仅有 ipv6 的在后，hash 分数从大到小
+// 顺序与容量无关，容量只在 hashPick 挑选时考虑，免得 repeater 满/不满时打乱其他客户端的顺序
+// 调用方需持有 dataMtx，且已按客户端计算 region 优先级
+func (mgr *nodeMgrT) hashOrder(client string, candLst []*repeaterCandT) []*hashCandT {
+	hashLst := make([]*hashCandT, 0, len(candLst))
+	for _, cand := range candLst {
+		weight, capacity := mgr.repeaterWeight(&cand.node)
+		hashLst = append(hashLst, &hashCandT{
+			cand:  cand,
+			full:  capacity > 0 && mgr.assignCntMap[cand.node.Uuid] >= capacity && !mgr.assignedTo(client, cand.node.Uuid),
+			score: rendezvousScore(client, cand.node.Uuid, weight),
+		})
+	}
+	sort.SliceStable(hashLst, func(i, j int) bool {
+		a, b := hashLst[i], hashLst[j]
+		if a.cand.healthy != b.cand.healthy {
+			return a.cand.healthy
+		}
+		if a.cand.prefer != b.cand.prefer {
+			return a.cand.prefer < b.cand.prefer
+		}
+		if (len(a.cand.node.IP) > 0) != (len(b.cand.node.IP) > 0) {
+			return len(a.cand.node.IP) > 0
+		}
+		return a.score > b.score
+	})
+	return hashLst
+}
+
+// assignedTo 该 repeater 是否已分配给该客户端，调用方需持有 dataMtx
+func (mgr *nodeMgrT) assignedTo(client, repeater string) bool {
+	assign, ok := mgr.assignMap[client]
+	if !ok {
+		return false
+	}
+	for _, uuid := range assign.repeaterLst {
+		if uuid == repeater {
+			return true
+		}
+	}
+	return false
+}
+
+// hashPick 按 hash 顺序挑选 count 个，先跳过容量已满的，不足时再按顺序补充，结果保持 hash 顺序
+func hashPick(hashLst []*hashCandT, count int) []*hashCandT {
+	if count > len(hashLst) {
+		count = len(hashLst)
+	}
+	pickMap := make(map[*hashCandT]bool, count)
+	for _, h := range hashLst {
+		if len(pickMap) < count && !h.full {
+			pickMap[h] = true
+		}
+	}
+	for _, h := range hashLst {
+		if len(pickMap) < count && !pickMap[h] {
+			pickMap[h] = true
+		}
+	}
+	lst := make([]*hashCandT, 0, count)
+	for _, h := range hashLst {
+		if pickMap[h] {
+			lst = append(lst, h)
+		}
+	}
+	return lst
+}
+
+// pickByHash 按 hash 顺序挑选 count 个，调用方需持有 dataMtx
+func (mgr *nodeMgrT) pickByHash(client string, candLst []*repeaterCandT, count int) []NodeT {
+	lst := make([]NodeT, 0, count)
+	for _, h := range hashPick(mgr.hashOrder(client, candLst), count) {
+		lst = append(lst, h.cand.node)
+	}
+	return lst
+}
+
+// RingNodeT hash 环上的一个 repeater
+type RingNodeT struct {
+	Uuid     string  `json:"uuid"`
+	IP       string  `json:"ip,omitempty"`
+	IPv6     string  `json:"ipv6,omitempty"`
+	Region   string  `json:"region,omitempty"`
+	State    string  `json:"state"`
+	Healthy  bool    `json:"healthy"`
+	Abnormal int     `json:"abnormal"` // 窗口内相关的异常事件数
+	Weight   int     `json:"weight"`
+	Capacity int     `json:"capacity"`
+	Assigned int     `json:"assigned"`
+	Share    float64 `json:"share"`           // 按权重应分到的客户端比例(count 为 1 时，不考虑 region)，不健康的为 0
+	Prefer   int     `json:"prefer"`          // 指定 client 时 region 的优先级，越小越优先
+	Score    float64 `json:"score,omitempty"` // 指定 client 时该 repeater 的 hash 分数
+	Full     bool    `json:"full,omitempty"`  // 指定 client 时是否因容量已满只在不足时补充
+}
+
+// RingT 当前参与挑选的 repeater，指定 client 时附带该客户端会拿到的列表
+type RingT struct {
+	Select string      `json:"select"`
+	Count  int         `json:"count"`
+	Nodes  []RingNodeT `json:"nodes"`
+	Client string      `json:"client,omitempty"`
+	IP     string      `json:"ip,omitempty"`     // 客户端地址，用于查 region
+	Region string      `json:"region,omitempty"` // 客户端地址所属的 region
+	Pick   []string    `json:"pick,omitempty"`   // repeater.uuid，按返回顺序
+}
+
+func (mgr *nodeMgrT) ringGet(client, clientIP string, now time.Time) *RingT {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	candLst := mgr.repeaterCand(now)
+	ring := &RingT{Select: mgr.repeater.Select, Count: mgr.repeater.Count, Nodes: make([]RingNodeT, 0, len(candLst)), Client: client, IP: clientIP}
+	ring.Region = mgr.preferRegion(clientIP, candLst)
+
+	weightSum := 0
+	for _, cand := range candLst {
+		if weight, _ := mgr.repeaterWeight(&cand.node); cand.healthy {
+			weightSum += weight
+		}
+	}
+	idxMap := make(map[string]int)
+	for _, cand := range candLst {
+		weight, capacity := mgr.repeaterWeight(&cand.node)
+		node := RingNodeT{
+			Uuid:     cand.node.Uuid,
+			IP:       cand.node.IP,
+			IPv6:     cand.node.IPv6,
+			Region:   cand.node.Region,
+			State:    cand.node.State,
+			Healthy:  cand.healthy,
+			Abnormal: cand.abnormal,
+			Weight:   weight,
+			Capacity: capacity,
+			Assigned: mgr.assignCntMap[cand.node.Uuid],
+			Prefer:   cand.prefer,
+		}
+		if cand.healthy && weightSum > 0 {
+			node.Share = float64(weight) / float64(weightSum)
+		}
+		idxMap[node.Uuid] = len(ring.Nodes)
+		ring.Nodes = append(ring.Nodes, node)
+	}
+
+	if len(client) == 0 || ring.Select != REPEATER_SELECT_HASH {
+		return ring
+	}
+	count := mgr.repeater.Count
+	if count <= 0 || count > len(candLst) {
+		count = len(candLst)
+	}
+	hashLst := mgr.hashOrder(client, candLst)
+	for _, h := range hashLst {
+		node := &ring.Nodes[idxMap[h.cand.node.Uuid]]
+		node.Score = h.score
+		node.Full = h.full
+	}
+	for _, h := range hashPick(hashLst, count) {
+		ring.Pick = append(ring.Pick, h.cand.node.Uuid)
+	}
+	return ring
+}
+
+// AdminRingGet 查看 repeater 的 hash 环，?uuid=客户端 uuid[&ip=客户端地址] 时附带该客户端的挑选结果(不记录分配)
+func AdminRingGet(c *gin.Context) {
+	c.JSON(http.StatusOK, nodeMgr.ringGet(c.Query("uuid"), c.Query("ip"), time.Now()))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPickByHash(t *testing.T) {
+	cfg := configDefault()
+	cfg.Repeater.Count = 2
+	NodeMgrInit(NewMemStore(), cfg)
+	t.Cleanup(nodeMgr.stopReaper)
+	mgr := nodeMgr
+	for i := 0; i < 5; i++ {
+		uuid := fmt.Sprintf("uuid-%d", i)
+		if err := mgr.reservationSet(&ReservationT{Uuid: uuid, SubId: 150 + i, RoleType: 1000}); err != nil {
+			t.Fatalf("0x1c3ddec9 reserve fail:%v", err)
+		}
+		if _, _, err := mgr.bootNode(uuid, fmt.Sprintf("1.1.1.%d", i+1), "v1", 0); err != nil {
+			t.Fatalf("0xea192ad2 boot fail:%v", err)
+		}
+	}
+
+	now := time.Now()
+	pick := func(client string) []string {
+		lst := make([]string, 0)
+		for _, node := range mgr.assignRepeater(client, "", now) {
+			lst = append(lst, node.Uuid)
+		}
+		return lst
+	}
+
+	before := make(map[string][]string)
+	for i := 0; i < 200; i++ {
+		client := fmt.Sprintf("client-%d", i)
+		before[client] = pick(client)
+		if len(before[client]) != 2 {
+			t.Fatalf("0x1e6a4c07 pick len:%d", len(before[client]))
+		}
+		// 同一客户端再次请求，列表及顺序不变
+		if fmt.Sprint(pick(client)) != fmt.Sprint(before[client]) {
+			t.Fatalf("0x58b2d9f3 pick not stable:%s", client)
+		}
+	}
+
+	// 移除一个 repeater，只有用到它的客户端变化
+	mgr.dataMtx.Lock()
+	delete(mgr.nodeUuidMap, "uuid-3")
+	mgr.dataMtx.Unlock()
+	moved := 0
+	for client, old := range before {
+		cur := pick(client)
+		if old[0] != "uuid-3" && old[1] != "uuid-3" {
+			if fmt.Sprint(cur) != fmt.Sprint(old) {
+				t.Fatalf("0x7c30e8a5 %s moved without removed repeater:%v -> %v", client, old, cur)
+			}
+			continue
+		}
+		moved++
+		for _, uuid := range old {
+			if uuid != "uuid-3" && uuid != cur[0] && uuid != cur[1] {
+				t.Fatalf("0x2f9d61b4 %s lost kept repeater:%v -> %v", client, old, cur)
+			}
+		}
+	}
+	if moved == 0 || moved == len(before) {
+		t.Fatalf("0x64a8b1e2 moved:%d", moved)
+	}
+
+	ring := mgr.ringGet("client-0", "", now)
+	if len(ring.Nodes) != 4 || fmt.Sprint(ring.Pick) != fmt.Sprint(pick("client-0")) {
+		t.Fatalf("0x0da57f93 ring error:%+v", ring)
+	}
+	if ring.Nodes[0].Share != 0.25 {
+		t.Fatalf("0x3b1e90c6 ring share:%f", ring.Nodes[0].Share)
+	}
+}
+
+func TestHashPickFull(t *testing.T) {
+	hashLst := make([]*hashCandT, 0)
+	for i := 0; i < 4; i++ {
+		hashLst = append(hashLst, &hashCandT{cand: &repeaterCandT{node: NodeT{Uuid: fmt.Sprintf("uuid-%d", i)}}, full: i == 0})
+	}
+	uuids := func(lst []*hashCandT) string {
+		str := ""
+		for _, h := range lst {
+			str += h.cand.node.Uuid + ","
+		}
+		return str
+	}
+	// 容量已满的跳过，其余保持 hash 顺序
+	if str := uuids(hashPick(hashLst, 2)); str != "uuid-1,uuid-2," {
+		t.Fatalf("0x4b7e2c19 pick:%s", str)
+	}
+	// 不足时补充容量已满的，仍按 hash 顺序返回
+	if str := uuids(hashPick(hashLst, 4)); str != "uuid-0,uuid-1,uuid-2,uuid-3," {
+		t.Fatalf("0x8d31f6a0 pick:%s", str)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// NodeAddrT node 的公网地址(双栈)
+type NodeAddrT struct {
+	IPv4 string `json:"ipv4,omitempty"`
+	IPv6 string `json:"ipv6,omitempty"`
+}
+
+// ulaAllocT 按子网号从 ULA /48 前缀中划出 /64：prefix:subId::/64
+type ulaAllocT struct {
+	prefix net.IP
+}
+
+func isIPv6(ip string) bool {
+	addr := net.ParseIP(ip)
+	return addr != nil && addr.To4() == nil
+}
+
+// clientIPv6 客户端可使用仅有 ipv6 的 repeater: 通过 ipv6 请求，或同 uuid 的 node 登记过 ipv6 地址，调用方需持有 dataMtx
+// 其他(仅 ipv4、不认识 RepeaterServerNode 2 号字段的旧客户端)只会看到 IPv4 为空的条目
+func (mgr *nodeMgrT) clientIPv6(client, clientIP string) bool {
+	if isIPv6(clientIP) {
+		return true
+	}
+	node, ok := mgr.nodeUuidMap[client]
+	return ok && len(node.IPv6) > 0
+}
+
+// ipv4Cand 去掉仅有 ipv6 的 repeater
+func ipv4Cand(candLst []*repeaterCandT) []*repeaterCandT {
+	lst := make([]*repeaterCandT, 0, len(candLst))
+	for _, cand := range candLst {
+		if len(cand.node.IP) > 0 {
+			lst = append(lst, cand)
+		}
+	}
+	return lst
+}
+
+// setAddr 按地址族记录公网地址，另一族的地址保持不变
+func (node *NodeT) setAddr(ip string) {
+	if isIPv6(ip) {
+		node.IPv6 = ip
+	} else {
+		node.IP = ip
+	}
+}
+
+// newUlaAlloc prefix 为空时不分配 ULA
+func newUlaAlloc(prefix string) (*ulaAllocT, error) {
+	if len(prefix) == 0 {
+		return nil, nil
+	}
+
+	ip, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x43a9e07c invalid ula prefix(%s):%s", prefix, err))
+	}
+	ones, bits := ipNet.Mask.Size()
+	if ip.To4() != nil || bits != 128 || ones != 48 || ipNet.IP[0] != 0xfd {
+		return nil, errors.New(fmt.Sprintf("0x5e17b2c8 ula prefix(%s) must be fdxx:xxxx:xxxx::/48", prefix))
+	}
+
+	return &ulaAllocT{prefix: ipNet.IP}, nil
+}
+
+// subnetOf 子网号对应的 /64
+func (u *ulaAllocT) subnetOf(subId int) string {
+	if u == nil || subId <= 0 {
+		return ""
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, u.prefix)
+	ip[6] = byte(subId >> 8)
+	ip[7] = byte(subId)
+	return fmt.Sprintf("%s/64", ip.String())
+}
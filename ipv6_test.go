@@ -0,0 +1,71 @@
+package main
+
+import (
+	"github.com/shankusu2017/proto_pb/go/proto"
+	pb "google.golang.org/protobuf/proto"
+	"testing"
+	"time"
+)
+
+func TestUlaSubnetOf(t *testing.T) {
+	ula, err := newUlaAlloc("fd12:3456:789a::/48")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if v := ula.subnetOf(120); v != "fd12:3456:789a:78::/64" {
+		t.Fatalf("0x3c71e9b2 ula error:%s", v)
+	}
+
+	// 未配置
+	ula, err = newUlaAlloc("")
+	if err != nil || ula.subnetOf(120) != "" {
+		t.Fatalf("0x1f9a06d4 empty ula error")
+	}
+
+	for _, prefix := range []string{"2001:db8::/48", "fd12:3456::/32", "10.0.0.0/8"} {
+		_, err = newUlaAlloc(prefix)
+		if err == nil {
+			t.Fatalf("0x58e2b7a0 prefix(%s) should be invalid", prefix)
+		}
+	}
+}
+
+func TestRepeaterNodeIPv6(t *testing.T) {
+	node := &proto.RepeaterServerNode{IPv4: "1.2.3.4", IPv6: "2001:db8::1"}
+
+	buf, err := pb.Marshal(node)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	var dec proto.RepeaterServerNode
+	err = pb.Unmarshal(buf, &dec)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if dec.IPv4 != "1.2.3.4" || dec.GetIPv6() != "2001:db8::1" {
+		t.Fatalf("0x6d04c3a9 ipv6 field error")
+	}
+}
+
+func TestAssignIPv6Only(t *testing.T) {
+	NodeMgrInit(NewMemStore(), configDefault())
+	t.Cleanup(nodeMgr.stopReaper)
+	mgr := nodeMgr
+	for i, ip := range []string{"1.1.1.1", "2001:db8::1"} {
+		uuid := []string{"uuid-v4", "uuid-v6"}[i]
+		if err := mgr.reservationSet(&ReservationT{Uuid: uuid, SubId: 150 + i, RoleType: 1000}); err != nil {
+			t.Fatalf("0x2b8e4f61 reserve fail:%v", err)
+		}
+		if _, _, err := mgr.bootNode(uuid, ip, "v1", 0); err != nil {
+			t.Fatalf("0x5c0a7d93 boot fail:%v", err)
+		}
+	}
+
+	// 仅 ipv4 的客户端不返回仅有 ipv6 的 repeater
+	if lst := mgr.assignRepeater("client-a", "8.8.8.8", time.Now()); len(lst) != 1 || lst[0].Uuid != "uuid-v4" {
+		t.Fatalf("0x71d3c5a8 v4 client:%+v", lst)
+	}
+	if lst := mgr.assignRepeater("client-b", "2001:db8::9", time.Now()); len(lst) != 2 {
+		t.Fatalf("0x0e9b2f46 v6 client:%+v", lst)
+	}
+}
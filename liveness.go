@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// node 的存活状态
+// online: 最近 keepalive*suspectMiss 内有 ping
+// suspect: 漏了 suspectMiss 次以上的 keepalive
+// offline: 超过 offline 时长没有 ping，或上报了 CLOSED 之后没有再 boot/ping
+// expired: 租约已过期，子网号仍保留，超过 lease.grace 后回收
+const (
+	NODE_STATE_ONLINE  = "online"
+	NODE_STATE_SUSPECT = "suspect"
+	NODE_STATE_OFFLINE = "offline"
+	NODE_STATE_EXPIRED = "expired"
+)
+
+// node 上报 CLOSED 时子网号的处理
+const (
+	CLOSE_RELEASE_KEEP   = "keep"   // 保留，租约过期再过 lease.grace 后回收
+	CLOSE_RELEASE_STICKY = "sticky" // 立即释放，再次上线时子网号未被占用则沿用
+	CLOSE_RELEASE_FREE   = "free"   // 立即释放，不再为该 uuid 保留
+)
+
+// CloseCfgT node 正常退出(CLOSED)时的处理，静态预留、维护中的总是保留
+type CloseCfgT struct {
+	Release string `json:"release"` // keep(默认)、sticky、free
+}
+
+func (cfg *CloseCfgT) check() error {
+	if cfg.Release != CLOSE_RELEASE_KEEP && cfg.Release != CLOSE_RELEASE_STICKY && cfg.Release != CLOSE_RELEASE_FREE {
+		return errors.New(fmt.Sprintf("0x0d6a3f27 invalid close release(%s)", cfg.Release))
+	}
+	return nil
+}
+
+// LivenessCfgT 存活状态的判定阈值
+type LivenessCfgT struct {
+	Keepalive   DurationT `json:"keepalive"`   // node 上报 keepalive 的周期
+	SuspectMiss int       `json:"suspectMiss"` // 连续漏掉多少次 keepalive 判定为 suspect
+	Offline     DurationT `json:"offline"`     // 多久没有 ping 判定为 offline
+	Sweep       DurationT `json:"sweep"`       // 扫描周期
+}
+
+func (cfg *LivenessCfgT) check() error {
+	suspect := time.Duration(cfg.Keepalive) * time.Duration(cfg.SuspectMiss)
+	if cfg.Keepalive <= 0 || cfg.SuspectMiss <= 0 || cfg.Sweep <= 0 || time.Duration(cfg.Offline) <= suspect {
+		return errors.New(fmt.Sprintf("0x0b5e7d92 invalid liveness keepalive(%s), suspectMiss(%d), offline(%s), sweep(%s)",
+			time.Duration(cfg.Keepalive), cfg.SuspectMiss, time.Duration(cfg.Offline), time.Duration(cfg.Sweep)))
+	}
+	return nil
+}
+
+// nodeState 按最后一次 ping 和租约判定存活状态
+func (mgr *nodeMgrT) nodeState(node *NodeT, now time.Time) string {
+	if !node.leaseValid(now) {
+		return NODE_STATE_EXPIRED
+	}
+	if node.closed {
+		return NODE_STATE_OFFLINE
+	}
+	idle := now.Sub(node.Ping)
+	if idle >= time.Duration(mgr.liveness.Offline) {
+		return NODE_STATE_OFFLINE
+	}
+	if idle >= time.Duration(mgr.liveness.Keepalive)*time.Duration(mgr.liveness.SuspectMiss) {
+		return NODE_STATE_SUSPECT
+	}
+	return NODE_STATE_ONLINE
+}
+
+// transitNode 切换存活状态并记录事件，状态不变时不记录，调用方需持有 dataMtx
+func (mgr *nodeMgrT) transitNode(node *NodeT, state string, now time.Time) {
+	if node.State == state {
+		return
+	}
+
+	msg := fmt.Sprintf("%s -> %s, ping:%s", node.State, state, node.Ping.Format(time.RFC3339))
+	node.State = state
+	node.StateTS = now
+
+	eType, ok := nodeStateEvent[state]
+	if !ok {
+		return
+	}
+	mgr.recordEvent(&EventItemDBT{
+		Uuid:     node.Uuid,
+		IP:       node.IP,
+		RoleType: node.RoleType,
+		TS:       now,
+		Ver:      node.Ver,
+		EType:    eType,
+		EMsg:     msg,
+	})
+}
+
+var nodeStateEvent = map[string]int{
+	NODE_STATE_ONLINE:  EVENT_NODE_ONLINE,
+	NODE_STATE_SUSPECT: EVENT_NODE_SUSPECT,
+	NODE_STATE_OFFLINE: EVENT_NODE_OFFLINE,
+	NODE_STATE_EXPIRED: EVENT_NODE_EXPIRED,
+}
+
+// sweepNodeState 刷新所有 node 的存活状态，调用方需持有 dataMtx
+func (mgr *nodeMgrT) sweepNodeState(now time.Time) {
+	for _, node := range mgr.nodeUuidMap {
+		mgr.transitNode(node, mgr.nodeState(node, now), now)
+	}
+}
+
+// closeNode node 上报 CLOSED: 立即切换为 offline，按 close.release 释放子网号，返回 node 的副本及释放的说明
+func (mgr *nodeMgrT) closeNode(uuid string, now time.Time) (NodeT, string, error) {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	node, err := mgr.nodeLookup(uuid)
+	if err != nil {
+		return NodeT{}, "", err
+	}
+	node.closed = true
+	mgr.transitNode(node, mgr.nodeState(node, now), now)
+	defer mgr.refreshRepeaterVer(now)
+
+	_, reserved := mgr.reserveUuidMap[uuid]
+	attr, ok := mgr.attrMap[uuid]
+	if mgr.close.Release == CLOSE_RELEASE_KEEP || reserved || (ok && attr.Maintenance) {
+		// 保留子网号的随租约保存 closed，重启后仍为 offline；保存失败时仅本次运行内生效
+		err = mgr.store.UpsertLease(node.lease())
+		if err != nil {
+			log.Printf("ERROR 0x3d8e5a16 save closed node.uuid(%s) fail:%s, kept until restart", uuid, err)
+		}
+		return *node, "", nil
+	}
+	if mgr.close.Release == CLOSE_RELEASE_STICKY {
+		err = mgr.store.ReleaseNetConfig(uuid)
+	} else {
+		err = mgr.store.DeleteNetConfig(uuid)
+	}
+	if err != nil {
+		log.Printf("ERROR 0x5f2b08c4 release closed node.uuid(%s) fail:%s", uuid, err)
+		return *node, "", nil
+	}
+	delete(mgr.nodeUuidMap, uuid)
+	delete(mgr.nodeSubNetIdMap, node.SubId)
+	delete(mgr.abnormalMap, uuid)
+	mgr.unassignRepeater(uuid)
+	if mgr.close.Release == CLOSE_RELEASE_STICKY {
+		mgr.stickyMap[uuid] = node.SubId
+	} else {
+		delete(mgr.stickyMap, uuid)
+	}
+	return *node, fmt.Sprintf("release subId:%d (%s)", node.SubId, mgr.close.Release), nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNodeLiveness(t *testing.T) {
+	store := NewMemStore()
+	NodeMgrInit(store, configDefault())
+	t.Cleanup(nodeMgr.stopReaper)
+	mgr := nodeMgr
+	if err := mgr.reservationSet(&ReservationT{Uuid: "uuid-a", SubId: 150, RoleType: 1000}); err != nil {
+		t.Fatalf("0x6beb5524 reserve fail:%v", err)
+	}
+	node, _, err := mgr.bootNode("uuid-a", "1.1.1.1", "v1", 0)
+	if err != nil || node.State != NODE_STATE_ONLINE {
+		t.Fatalf("0x5e21c7a0 boot state error:%v, %s", err, node.State)
+	}
+
+	// 默认 keepalive 1m * 3 判定 suspect，10m offline，租约 30m
+	for _, c := range []struct {
+		after time.Duration
+		state string
+	}{
+		{time.Minute * 2, NODE_STATE_ONLINE},
+		{time.Minute * 4, NODE_STATE_SUSPECT},
+		{time.Minute * 11, NODE_STATE_OFFLINE},
+		{time.Minute * 31, NODE_STATE_EXPIRED},
+	} {
+		now := node.Ping.Add(c.after)
+		mgr.scanDeadNode(now)
+		n := mgr.nodeUuidMap["uuid-a"]
+		if n.State != c.state {
+			t.Fatalf("0x2b7f90d4 after %s state:%s, expect:%s", c.after, n.State, c.state)
+		}
+	}
+	if !mgr.nodeUuidMap["uuid-a"].StateTS.Equal(node.Ping.Add(time.Minute * 31)) {
+		t.Fatalf("0x6d0a3e18 state ts error")
+	}
+
+	mgr.updateNode("1.1.1.1", "uuid-a")
+	if mgr.nodeUuidMap["uuid-a"].State != NODE_STATE_ONLINE {
+		t.Fatalf("0x13c8f5b7 ping not online")
+	}
+
+	page, _ := store.SelectEvent(&EventQueryT{Uuid: "uuid-a", Role: -1, Types: []int{EVENT_NODE_ONLINE, EVENT_NODE_SUSPECT, EVENT_NODE_OFFLINE, EVENT_NODE_EXPIRED}, Limit: 10})
+	expect := []int{EVENT_NODE_ONLINE, EVENT_NODE_SUSPECT, EVENT_NODE_OFFLINE, EVENT_NODE_EXPIRED, EVENT_NODE_ONLINE}
+	if len(page.Events) != len(expect) {
+		t.Fatalf("0x48e9a2c1 transition event len:%d", len(page.Events))
+	}
+	for i, e := range page.Events {
+		if e.EType != expect[i] {
+			t.Fatalf("0x7a04d6e3 transition event %d type:%d", i, e.EType)
+		}
+	}
+}
+
+func TestLivenessCfgCheck(t *testing.T) {
+	cfg := configDefault().Liveness
+	if cfg.check() != nil {
+		t.Fatalf("0x0f6b2d95 default liveness invalid")
+	}
+	cfg.Offline = DurationT(time.Minute * 3)
+	if cfg.check() == nil {
+		t.Fatalf("0x3c91e7a8 offline not after suspect")
+	}
+}
+
+func TestNodeClosed(t *testing.T) {
+	cfg := configDefault()
+	store := NewMemStore()
+	// 结尾停止回收协程后关闭存储，不用 t.Cleanup
+	NodeMgrInit(store, cfg)
+	mgr := nodeMgr
+	if _, _, err := mgr.bootNode("uuid-a", "8.8.8.8", "v1", 0); err != nil {
+		t.Fatalf("0xfc80f86f boot fail:%v", err)
+	}
+	now := time.Now()
+
+	// 立即 offline，扫描时不会因 ping 较新而恢复，子网号默认保留
+	node, addMsg, err := mgr.closeNode("uuid-a", now)
+	if err != nil || node.State != NODE_STATE_OFFLINE || len(addMsg) != 0 {
+		t.Fatalf("0x3e0d7b92 close node:%+v, err:%v", node, err)
+	}
+	mgr.scanDeadNode(now.Add(time.Second))
+	if n := mgr.nodeUuidMap["uuid-a"]; n == nil || n.State != NODE_STATE_OFFLINE {
+		t.Fatalf("0x58c1a6f0 closed node after sweep:%+v", n)
+	}
+	// closed 随租约保存，重启后仍为 offline
+	mgr.stopReaper()
+	NodeMgrInit(store, cfg)
+	mgr = nodeMgr
+	if n := mgr.nodeUuidMap["uuid-a"]; n == nil || n.State != NODE_STATE_OFFLINE {
+		t.Fatalf("0x2d6b8f31 closed node after restart:%+v", n)
+	}
+	mgr.updateNode("8.8.8.8", "uuid-a")
+	if mgr.nodeUuidMap["uuid-a"].State != NODE_STATE_ONLINE {
+		t.Fatalf("0x0a6e49d3 ping after closed not online")
+	}
+	if _, _, err = mgr.closeNode("uuid-x", now); err == nil {
+		t.Fatalf("0x71f28b5c close unknown node")
+	}
+
+	// sticky: 立即释放，再次上线沿用原子网号
+	mgr.close.Release = CLOSE_RELEASE_STICKY
+	subId := node.SubId
+	if _, addMsg, _ = mgr.closeNode("uuid-a", now); len(addMsg) == 0 || mgr.nodeUuidMap["uuid-a"] != nil {
+		t.Fatalf("0x2b94e0c7 closed node not released:%s", addMsg)
+	}
+	if node, _, _ = mgr.bootNode("uuid-a", "8.8.8.8", "v1", 0); node.SubId != subId {
+		t.Fatalf("0x6f3a1d08 sticky subId:%d, expect:%d", node.SubId, subId)
+	}
+
+	// free: 删除租约，分配到它的客户端重新挑选
+	mgr.close.Release = CLOSE_RELEASE_FREE
+	if lst := mgr.assignRepeater("client-a", "", now); len(lst) != 1 {
+		t.Fatalf("0x0c5e8a73 assign:%+v", lst)
+	}
+	if _, addMsg, _ = mgr.closeNode("uuid-a", now); len(addMsg) == 0 || mgr.assignCntMap["uuid-a"] != 0 || len(mgr.assignMap) != 0 {
+		t.Fatalf("0x3a61f0d9 closed repeater still assigned:%v", mgr.assignCntMap)
+	}
+	if leaseLst, _ := store.LoadLeaseAll(); len(leaseLst) != 0 {
+		t.Fatalf("0x5d29b7e4 lease kept after free:%+v", leaseLst[0])
+	}
+
+	page, _ := store.SelectEvent(&EventQueryT{Uuid: "uuid-a", Role: -1, Types: []int{EVENT_NODE_OFFLINE}, Limit: 10})
+	if len(page.Events) != 3 {
+		t.Fatalf("0x4c07e5b1 offline event:%d", len(page.Events))
+	}
+
+	// 回收协程停止后关闭存储
+	mgr.stopReaper()
+	if err = mgr.closeStore(); err != nil {
+		t.Fatalf("0x19d6f2a4 close store:%v", err)
+	}
+}
@@ -1,28 +1,95 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/shankusu2017/url"
-	"github.com/shankusu2017/utils"
+	"log"
 	"math/rand"
 	"time"
 )
 
+var (
+	cfgPath string
+)
+
 func main() {
+	flag.StringVar(&cfgPath, "config", CONFIG_PATH_DEFAULT, "config file path")
+	migrateDryRun := flag.Bool("migrate-dry-run", false, "print pending schema migrations and exit")
+	migrateDownTo := flag.Int("migrate-down", -1, "roll schema back to the given version and exit")
+	reconcile := flag.Bool("reconcile", false, "repair conflicting node rows at startup instead of exiting")
+	flag.Parse()
+
 	rand.NewSource(time.Now().UnixNano())
-	utils.InitPac("./etc/cnIP.cfg", "./etc/outIP.cfg")
-	NodeMgrInit("./etc/nodeInfo.db")
+	cfg, err := ConfigLoad(cfgPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *reconcile {
+		cfg.Reconcile = true
+	}
+	if *migrateDryRun || *migrateDownTo >= 0 {
+		if cfg.Store.Driver != STORE_DRIVER_SQLITE {
+			log.Fatalf("0x6b3e8f05 schema migration only for %s store", STORE_DRIVER_SQLITE)
+		}
+		err = MigrateCmd(cfg.Store.Path, *migrateDryRun, *migrateDownTo)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	store, err := NewStore(&cfg.Store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store = newMetricStore(store)
+	NodeMgrInit(store, cfg)
+	ec := EventCompactInit(store, &cfg.Retention)
+	AlertInit(&cfg.Alert)
+	pw := PacWatchInit(&cfg.Pac)
 
 	r := gin.Default()
 
 	r.GET("/v1/monitor", MonitorGet)
+	r.GET("/v1/monitor/summary", MonitorSummaryGet)
+	r.GET("/metrics", MetricsGet())
+
+	admin := r.Group("/v1/admin", AdminAuth(&cfg.Admin))
+	admin.POST("/pool/reload", AdminPoolReload)
+	admin.GET("/reservation", AdminReservationGet)
+	admin.POST("/reservation", AdminReservationSet)
+	admin.DELETE("/reservation/:uuid", AdminReservationDel)
+	admin.GET("/ring", AdminRingGet)
+	admin.POST("/alert/reload", AdminAlertReload)
+	admin.GET("/credential", AdminCredentialGet)
+	admin.DELETE("/credential/:uuid", AdminCredentialDel)
+	admin.GET("/enroll", AdminEnrollGet)
+	admin.POST("/enroll/:uuid/approve", AdminEnrollApprove)
+	admin.POST("/enroll/:uuid/reject", AdminEnrollReject)
+	admin.DELETE("/enroll/:uuid", AdminEnrollDel)
+	admin.GET("/enroll/token", AdminEnrollTokenGet)
+	admin.POST("/enroll/token", AdminEnrollTokenNew)
+	admin.DELETE("/enroll/token/:hash", AdminEnrollTokenDel)
+	admin.DELETE("/node/:uuid", AdminNodeEvict)
+	admin.POST("/node/:uuid/role", AdminNodeRole)
+	admin.POST("/node/:uuid/subnet", AdminNodeSubnet)
+	admin.PATCH("/node/:uuid", AdminNodeEdit)
+	admin.POST("/node/:uuid/maintenance", AdminNodeMaintenance)
+	admin.POST("/node/:uuid/drain", AdminNodeDrain)
+	admin.GET("/audit", AdminAuditGet)
+	admin.POST("/pac/reload", AdminPacReload)
+	admin.GET("/pac/diff", AdminPacDiff)
+	admin.POST("/pac/migrate", AdminPacMigrate)
 
 	r.POST(fmt.Sprintf("%s", url.URL_REPEATER_SERVER), NodeRepeaterGet)
 	r.POST(fmt.Sprintf("%s", url.URL_EVENT_POST), EventPost)
 	r.GET(fmt.Sprintf("%s", url.URL_EVENT_GET), EventGet)
 	r.GET(fmt.Sprintf("%s", url.URL_EVENT_HELP), EventHelp)
+	r.GET("/v1/event/rollup", EventRollupGet)
+	r.GET("/v1/event/stream", EventStream)
+	r.GET("/v1/alert/history", AlertHistoryGet)
 
-	// 监听并在 0.0.0.0:7080 上启动服务
-	r.Run(fmt.Sprintf("%s:%d", "", url.PORT_NODEMGR)) // ":7080"
+	// 监听并在 0.0.0.0:7080 上启动服务，收到 SIGTERM/SIGQUIT 后退出
+	Serve(r, fmt.Sprintf("%s:%d", "", url.PORT_NODEMGR), &cfg.Shutdown, pw, ec) // ":7080"
 }
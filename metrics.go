@@ -0,0 +1,131 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"time"
+)
+
+const (
+	METRIC_NAMESPACE = "nodemgr"
+)
+
+var (
+	metricRegistry = prometheus.NewRegistry()
+
+	// 收到的事件数，速率用 rate() 计算
+	metricEventTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRIC_NAMESPACE,
+		Name:      "events_received_total",
+		Help:      "Events received by EventPost, by proto.Event type.",
+	}, []string{"type"})
+
+	metricDecodeFail = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRIC_NAMESPACE,
+		Name:      "protobuf_decode_failures_total",
+		Help:      "Request bodies that failed protobuf decoding, by handler.",
+	}, []string{"handler"})
+
+	metricStoreLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: METRIC_NAMESPACE,
+		Name:      "store_op_duration_seconds",
+		Help:      "Latency of Store operations.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"op"})
+
+	metricReaperEvict = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: METRIC_NAMESPACE,
+		Name:      "reaper_evictions_total",
+		Help:      "Nodes whose lease expired past the grace period and whose sub ID was reclaimed.",
+	})
+
+	metricStreamDrop = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: METRIC_NAMESPACE,
+		Name:      "stream_subscriber_drops_total",
+		Help:      "Event stream subscribers disconnected because their buffer was full.",
+	})
+)
+
+var (
+	descNodeCount = prometheus.NewDesc(METRIC_NAMESPACE+"_nodes",
+		"Registered nodes by role and version.", []string{"role", "version"}, nil)
+	descPoolSize = prometheus.NewDesc(METRIC_NAMESPACE+"_subnet_pool_size",
+		"Sub IDs in the pool.", []string{"pool", "role"}, nil)
+	descPoolUsed = prometheus.NewDesc(METRIC_NAMESPACE+"_subnet_pool_used",
+		"Sub IDs in the pool assigned to a node.", []string{"pool", "role"}, nil)
+	descPoolReserved = prometheus.NewDesc(METRIC_NAMESPACE+"_subnet_pool_reserved",
+		"Sub IDs in the pool statically reserved.", []string{"pool", "role"}, nil)
+	descPoolUtil = prometheus.NewDesc(METRIC_NAMESPACE+"_subnet_pool_utilization",
+		"Ratio of sub IDs in the pool assigned to a node.", []string{"pool", "role"}, nil)
+	descLastPing = prometheus.NewDesc(METRIC_NAMESPACE+"_node_last_ping_seconds",
+		"Seconds since the last ping of the node.", []string{"uuid", "role"}, nil)
+)
+
+// nodeCollectorT 抓取时从 nodeMgr 读取当前状态
+type nodeCollectorT struct{}
+
+func (nodeCollectorT) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descNodeCount
+	ch <- descPoolSize
+	ch <- descPoolUsed
+	ch <- descPoolReserved
+	ch <- descPoolUtil
+	ch <- descLastPing
+}
+
+func (nodeCollectorT) Collect(ch chan<- prometheus.Metric) {
+	if nodeMgr == nil {
+		return
+	}
+
+	type roleVerT struct {
+		role string
+		ver  string
+	}
+	now := time.Now()
+	cntMap := make(map[roleVerT]int)
+
+	nodeMgr.dataMtx.Lock()
+	poolLst := nodeMgr.subnet.stat(nodeMgr.nodeSubNetIdMap, nodeMgr.reserveSubIdMap)
+	for _, node := range nodeMgr.nodeUuidMap {
+		role := proto.Role(node.RoleType).String()
+		cntMap[roleVerT{role, node.Ver}]++
+		ch <- prometheus.MustNewConstMetric(descLastPing, prometheus.GaugeValue, now.Sub(node.Ping).Seconds(), node.Uuid, role)
+	}
+	nodeMgr.dataMtx.Unlock()
+
+	for k, cnt := range cntMap {
+		ch <- prometheus.MustNewConstMetric(descNodeCount, prometheus.GaugeValue, float64(cnt), k.role, k.ver)
+	}
+	for _, pool := range poolLst {
+		role := proto.Role(pool.Role).String()
+		ch <- prometheus.MustNewConstMetric(descPoolSize, prometheus.GaugeValue, float64(pool.Size), pool.Name, role)
+		ch <- prometheus.MustNewConstMetric(descPoolUsed, prometheus.GaugeValue, float64(pool.Used), pool.Name, role)
+		ch <- prometheus.MustNewConstMetric(descPoolReserved, prometheus.GaugeValue, float64(pool.Reserved), pool.Name, role)
+		util := 0.0
+		if pool.Size > 0 {
+			util = float64(pool.Used) / float64(pool.Size)
+		}
+		ch <- prometheus.MustNewConstMetric(descPoolUtil, prometheus.GaugeValue, util, pool.Name, role)
+	}
+}
+
+func init() {
+	metricRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metricEventTotal,
+		metricDecodeFail,
+		metricStoreLatency,
+		metricReaperEvict,
+		metricStreamDrop,
+		nodeCollectorT{},
+	)
+}
+
+func MetricsGet() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(metricRegistry, promhttp.HandlerOpts{}))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsGet(t *testing.T) {
+	NodeMgrInit(newMetricStore(NewMemStore()), configDefault())
+	t.Cleanup(nodeMgr.stopReaper)
+	if err := nodeMgr.reservationSet(&ReservationT{Uuid: "uuid-a", SubId: 150, RoleType: 1000}); err != nil {
+		t.Fatalf("0xb08f04c5 reserve fail:%v", err)
+	}
+	_, _, err := nodeMgr.bootNode("uuid-a", "1.1.1.1", "v1", 0)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/event", EventPost)
+	r.GET("/metrics", MetricsGet())
+
+	rsp := httptest.NewRecorder()
+	r.ServeHTTP(rsp, httptest.NewRequest(http.MethodPost, "/event", bytes.NewReader([]byte{0xff, 0xff})))
+
+	rsp = httptest.NewRecorder()
+	r.ServeHTTP(rsp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rsp.Body)
+	for _, line := range []string{
+		`nodemgr_nodes{role="Repeater",version="v1"} 1`,
+		`nodemgr_subnet_pool_used{pool="repeater-default",role="Repeater"} 1`,
+		`nodemgr_subnet_pool_reserved{pool="repeater-default",role="Repeater"} 1`,
+		`nodemgr_subnet_pool_utilization{pool="repeater-default",role="Repeater"} 0.0125`,
+		`nodemgr_node_last_ping_seconds{role="Repeater",uuid="uuid-a"}`,
+		`nodemgr_protobuf_decode_failures_total{handler="EventPost"}`,
+		`nodemgr_store_op_duration_seconds_count{op="CommitNetConfig"}`,
+	} {
+		if !strings.Contains(string(body), line) {
+			t.Fatalf("0x4f81a2c6 metric not found:%s", line)
+		}
+	}
+}
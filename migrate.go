@@ -0,0 +1,353 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migrationT 一次表结构变更，Version 递增且不得修改已发布的迁移
+// Applied 用于识别迁移框架之前已手工完成的变更，返回 true 时只登记版本号
+type migrationT struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+	Applied func(tx *sql.Tx) (bool, error)
+}
+
+// createTable 建立的是版本 0 的表结构，之后的变更都追加在这里
+var migrations = []*migrationT{
+	{
+		Version: 1,
+		Name:    "nodeEventTbl index (uuid, ts)",
+		Up:      []string{"CREATE INDEX IF NOT EXISTS nodeEventTbl_uuid_ts ON nodeEventTbl(uuid, ts)"},
+		Down:    []string{"DROP INDEX IF EXISTS nodeEventTbl_uuid_ts"},
+	},
+	{
+		Version: 2,
+		Name:    "nodeEventTbl index (eventType, ts)",
+		Up:      []string{"CREATE INDEX IF NOT EXISTS nodeEventTbl_eventType_ts ON nodeEventTbl(eventType, ts)"},
+		Down:    []string{"DROP INDEX IF EXISTS nodeEventTbl_eventType_ts"},
+	},
+	{
+		// ipv6 node 的 ipv6 公网地址
+		Version: 3,
+		Name:    "netConfigTbl add column ipv6",
+		Up:      []string{"ALTER TABLE netConfigTbl ADD COLUMN ipv6 text NOT NULL DEFAULT ''"},
+		Down:    []string{"ALTER TABLE netConfigTbl DROP COLUMN ipv6"},
+		Applied: func(tx *sql.Tx) (bool, error) { return columnExist(tx, "netConfigTbl", "ipv6") },
+	},
+	{
+		// 事件时间改为 UTC、毫秒精度的定长字符串(见 eventTS)，按原始列比较即可使用 (uuid, ts)、(eventType, ts) 索引
+		// 旧格式仍可读取，回滚时不需要改回
+		Version: 4,
+		Name:    "nodeEventTbl normalize ts to utc",
+		Up: []string{"UPDATE nodeEventTbl SET ts = strftime('%Y-%m-%d %H:%M:%f+00:00', ts) " +
+			"WHERE strftime('%Y-%m-%d %H:%M:%f', ts) IS NOT NULL"},
+	},
+	{
+		// 过期事件删除前按小时、天汇总的每个 node 的事件数，start 为 UTC 时间戳(秒)
+		Version: 5,
+		Name:    "create nodeEventRollupTbl",
+		Up: []string{`CREATE TABLE IF NOT EXISTS nodeEventRollupTbl (
+			period text NOT NULL,
+			start INT NOT NULL,
+			uuid text NOT NULL,
+			eventType INT NOT NULL,
+			cnt INT NOT NULL,
+			PRIMARY KEY (period, start, uuid, eventType))`},
+		Down: []string{"DROP TABLE IF EXISTS nodeEventRollupTbl"},
+	},
+	{
+		// node 签名消息的密钥，created、confirmed 为毫秒时间戳，confirmed 为 0 表示未确认
+		Version: 6,
+		Name:    "create nodeCredentialTbl",
+		Up: []string{`CREATE TABLE IF NOT EXISTS nodeCredentialTbl (
+			uuid text PRIMARY KEY NOT NULL,
+			secret text NOT NULL,
+			created INT NOT NULL,
+			confirmed INT NOT NULL DEFAULT 0)`},
+		Down: []string{"DROP TABLE IF EXISTS nodeCredentialTbl"},
+	},
+	{
+		// 登记审批及一次性登记 token，时间均为毫秒时间戳
+		Version: 7,
+		Name:    "create nodeEnrollTbl, enrollTokenTbl",
+		Up: []string{`CREATE TABLE IF NOT EXISTS nodeEnrollTbl (
+			uuid text PRIMARY KEY NOT NULL,
+			status text NOT NULL,
+			ip text NOT NULL,
+			ver text NOT NULL,
+			first INT NOT NULL,
+			last INT NOT NULL,
+			cnt INT NOT NULL,
+			note text NOT NULL DEFAULT '')`,
+			`CREATE TABLE IF NOT EXISTS enrollTokenTbl (
+			hash text PRIMARY KEY NOT NULL,
+			uuid text NOT NULL,
+			expire INT NOT NULL,
+			created INT NOT NULL,
+			note text NOT NULL DEFAULT '')`},
+		Down: []string{"DROP TABLE IF EXISTS enrollTokenTbl", "DROP TABLE IF EXISTS nodeEnrollTbl"},
+	},
+	{
+		// 管理员设置的 node 属性及操作审计，时间均为毫秒时间戳
+		Version: 8,
+		Name:    "create nodeAttrTbl, auditTbl",
+		Up: []string{`CREATE TABLE IF NOT EXISTS nodeAttrTbl (
+			uuid text PRIMARY KEY NOT NULL,
+			role INT NOT NULL,
+			note text NOT NULL DEFAULT '',
+			maintenance INT NOT NULL,
+			updated INT NOT NULL)`,
+			`CREATE TABLE IF NOT EXISTS auditTbl (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ts INT NOT NULL,
+			actor text NOT NULL,
+			ip text NOT NULL,
+			action text NOT NULL,
+			uuid text NOT NULL,
+			detail text NOT NULL,
+			err text NOT NULL)`,
+			"CREATE INDEX IF NOT EXISTS auditTbl_uuid ON auditTbl(uuid)"},
+		Down: []string{"DROP TABLE IF EXISTS auditTbl", "DROP TABLE IF EXISTS nodeAttrTbl"},
+	},
+	{
+		// pac 重新加载后保持的角色，pacPinRole 为 0 时未保持
+		Version: 9,
+		Name:    "nodeAttrTbl add column pacPinRole, pacPinIP",
+		Up: []string{"ALTER TABLE nodeAttrTbl ADD COLUMN pacPinRole INT NOT NULL DEFAULT 0",
+			"ALTER TABLE nodeAttrTbl ADD COLUMN pacPinIP text NOT NULL DEFAULT ''"},
+		Down: []string{"ALTER TABLE nodeAttrTbl DROP COLUMN pacPinIP",
+			"ALTER TABLE nodeAttrTbl DROP COLUMN pacPinRole"},
+	},
+	{
+		// repeater 排空，毫秒时间戳，drainUntil 为 0 时未排空
+		Version: 10,
+		Name:    "nodeAttrTbl add column drainSince, drainUntil, drainAssigned",
+		Up: []string{"ALTER TABLE nodeAttrTbl ADD COLUMN drainSince INT NOT NULL DEFAULT 0",
+			"ALTER TABLE nodeAttrTbl ADD COLUMN drainUntil INT NOT NULL DEFAULT 0",
+			"ALTER TABLE nodeAttrTbl ADD COLUMN drainAssigned INT NOT NULL DEFAULT 0"},
+		Down: []string{"ALTER TABLE nodeAttrTbl DROP COLUMN drainAssigned",
+			"ALTER TABLE nodeAttrTbl DROP COLUMN drainUntil",
+			"ALTER TABLE nodeAttrTbl DROP COLUMN drainSince"},
+	},
+	{
+		// 最近一次 boot 请求的角色及决定角色的原因，重启后重新加载 pac 时按请求的角色计算差异
+		Version: 11,
+		Name:    "nodeAttrTbl add column reqRole, roleReason",
+		Up: []string{"ALTER TABLE nodeAttrTbl ADD COLUMN reqRole INT NOT NULL DEFAULT 0",
+			"ALTER TABLE nodeAttrTbl ADD COLUMN roleReason text NOT NULL DEFAULT ''"},
+		Down: []string{"ALTER TABLE nodeAttrTbl DROP COLUMN roleReason",
+			"ALTER TABLE nodeAttrTbl DROP COLUMN reqRole"},
+	},
+	{
+		// node 上报了 CLOSED，重启后仍为 offline
+		Version: 12,
+		Name:    "leaseTbl add column closed",
+		Up:      []string{"ALTER TABLE leaseTbl ADD COLUMN closed INT NOT NULL DEFAULT 0"},
+		Down:    []string{"ALTER TABLE leaseTbl DROP COLUMN closed"},
+	},
+}
+
+func columnExist(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, cType string
+		var dflt sql.NullString
+		err = rows.Scan(&cid, &name, &cType, &notNull, &dflt, &pk)
+		if err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+// schemaVersion 当前版本号，schema_version 表不存在时为 0(不创建，dry-run 时不改动库)
+func schemaVersion(db *sql.DB) (int, error) {
+	var cnt int
+	err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'").Scan(&cnt)
+	if err != nil || cnt == 0 {
+		return 0, err
+	}
+
+	var ver sql.NullInt64
+	err = db.QueryRow("SELECT max(version) FROM schema_version").Scan(&ver)
+	if err != nil {
+		return 0, err
+	}
+	return int(ver.Int64), nil
+}
+
+func createVersionTable(db *sql.DB) error {
+	// 已执行的迁移
+	// version 版本号
+	// name 描述
+	// ts 执行时间
+	sqlStmt := `
+	create table IF NOT EXISTS schema_version (
+		version INT NOT NULL PRIMARY KEY,
+		name text,
+		ts timestamp);
+	`
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%s: %s\n", err.Error(), sqlStmt)
+	}
+	return err
+}
+
+// migrateUp 按版本顺序执行未执行的迁移，dryRun 时只返回待执行的迁移
+func migrateUp(db *sql.DB, dryRun bool) ([]*migrationT, error) {
+	cur, err := schemaVersion(db)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x5a1e0c37 get schema version fail:%s", err))
+	}
+
+	var pending []*migrationT
+	for _, m := range migrations {
+		if m.Version > cur {
+			pending = append(pending, m)
+		}
+	}
+	if dryRun || len(pending) == 0 {
+		return pending, nil
+	}
+
+	err = createVersionTable(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range pending {
+		err = migrateApply(db, m, true)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("LOG 0x2c7d94e1 schema migrate up to %d: %s", m.Version, m.Name)
+	}
+
+	return pending, nil
+}
+
+// migrateDown 逆序回滚到 target 版本(不含)，dryRun 时只返回待回滚的迁移
+func migrateDown(db *sql.DB, target int, dryRun bool) ([]*migrationT, error) {
+	cur, err := schemaVersion(db)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x1b6e3f8a get schema version fail:%s", err))
+	}
+
+	var pending []*migrationT
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version > target && m.Version <= cur {
+			pending = append(pending, m)
+		}
+	}
+	if dryRun {
+		return pending, nil
+	}
+
+	for _, m := range pending {
+		err = migrateApply(db, m, false)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("LOG 0x6f03a2d5 schema migrate down from %d: %s", m.Version, m.Name)
+	}
+
+	return pending, nil
+}
+
+// migrateApply 在一个事务中执行迁移并登记/注销版本号
+func migrateApply(db *sql.DB, m *migrationT, up bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x4e9b7d12 begin tx fail:%s", err))
+	}
+	defer tx.Rollback()
+
+	stmtLst := m.Down
+	if up {
+		stmtLst = m.Up
+		if m.Applied != nil {
+			done, err := m.Applied(tx)
+			if err != nil {
+				return errors.New(fmt.Sprintf("0x37c1f6a0 migration(%d) check fail:%s", m.Version, err))
+			}
+			if done {
+				stmtLst = nil
+			}
+		}
+	}
+
+	for _, stmt := range stmtLst {
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return errors.New(fmt.Sprintf("0x0d58e2b9 migration(%d) fail:%s, sql:%s", m.Version, err, stmt))
+		}
+	}
+
+	if up {
+		_, err = tx.Exec("INSERT INTO schema_version(version, name, ts) VALUES ( ?, ?, ? )", m.Version, m.Name, time.Now())
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_version WHERE version = ?", m.Version)
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x72a4c0e6 migration(%d) update version fail:%s", m.Version, err))
+	}
+
+	return tx.Commit()
+}
+
+// MigrateCmd 命令行：downTo < 0 时执行(或预览)升级，否则回滚到 downTo 版本
+func MigrateCmd(dbPath string, dryRun bool, downTo int) error {
+	db, err := openDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var lst []*migrationT
+	if downTo < 0 {
+		if dryRun == false {
+			err = createTable(db)
+			if err != nil {
+				return err
+			}
+		}
+		lst, err = migrateUp(db, dryRun)
+	} else {
+		lst, err = migrateDown(db, downTo, dryRun)
+	}
+	if err != nil {
+		return err
+	}
+
+	cur, _ := schemaVersion(db)
+	fmt.Printf("schema version: %d, dry-run: %v\n", cur, dryRun)
+	for _, m := range lst {
+		stmtLst := m.Up
+		if downTo >= 0 {
+			stmtLst = m.Down
+		}
+		fmt.Printf("  %d %s\n", m.Version, m.Name)
+		for _, stmt := range stmtLst {
+			fmt.Printf("      %s\n", stmt)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func indexExist(t *testing.T, db *sql.DB, name string) bool {
+	var cnt int
+	err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'index' AND name = ?", name).Scan(&cnt)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	return cnt == 1
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "nodeInfo.db"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer db.Close()
+	err = createTable(db)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// dry-run 不改动库
+	pending, err := migrateUp(db, true)
+	if err != nil || len(pending) != len(migrations) {
+		t.Fatalf("0x3e61b0d7 dry-run error:%v", err)
+	}
+	if ver, _ := schemaVersion(db); ver != 0 || indexExist(t, db, "nodeEventTbl_uuid_ts") {
+		t.Fatalf("0x58d2f4a1 dry-run changed db")
+	}
+
+	_, err = migrateUp(db, false)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	last := migrations[len(migrations)-1].Version
+	if ver, _ := schemaVersion(db); ver != last {
+		t.Fatalf("0x1a7c93e6 version error:%d", ver)
+	}
+	if !indexExist(t, db, "nodeEventTbl_uuid_ts") || !indexExist(t, db, "nodeEventTbl_eventType_ts") {
+		t.Fatalf("0x6f28e0b5 index not created")
+	}
+
+	// 再次执行无待执行的迁移
+	pending, err = migrateUp(db, false)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("0x47b9d3c2 migrate twice error:%v", err)
+	}
+
+	_, err = migrateDown(db, 1, false)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if ver, _ := schemaVersion(db); ver != 1 || indexExist(t, db, "nodeEventTbl_eventType_ts") || !indexExist(t, db, "nodeEventTbl_uuid_ts") {
+		t.Fatalf("0x2de4a5f8 migrate down error")
+	}
+
+	_, err = migrateUp(db, false)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+}
+
+// 迁移框架之前已通过其他方式加上的列只登记版本
+func TestMigrateApplied(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "nodeInfo.db"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer db.Close()
+	createTable(db)
+	_, err = db.Exec("ALTER TABLE netConfigTbl ADD COLUMN ipv6 text NOT NULL DEFAULT ''")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = migrateUp(db, false)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+}
+
+// 旧数据的 ts 按写入时的时区保存，迁移后统一为 UTC，按原始列比较时使用索引
+func TestMigrateEventTS(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "nodeInfo.db"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer db.Close()
+	createTable(db)
+	ts := time.Date(2024, 5, 20, 8, 30, 0, 123456789, time.FixedZone("CST", 8*3600))
+	_, err = db.Exec("INSERT INTO nodeEventTbl(uuid, ip, roleType, ver, eventType, eventMsg, ts) VALUES ( ?, ?, ?, ?, ?, ?, ? )",
+		"uuid-a", "1.1.1.1", 1000, "v1", 1000, "msg", ts)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = migrateUp(db, false)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	var raw string
+	db.QueryRow("SELECT CAST(ts AS text) FROM nodeEventTbl").Scan(&raw)
+	if raw != eventTS(ts) {
+		t.Fatalf("0x4b19e6d0 ts not normalized:%s, expect:%s", raw, eventTS(ts))
+	}
+
+	var plan []string
+	rows, err := db.Query("EXPLAIN QUERY PLAN SELECT id FROM nodeEventTbl WHERE uuid = ? AND ts >= ?", "uuid-a", eventTS(ts))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		rows.Scan(&id, &parent, &notUsed, &detail)
+		plan = append(plan, detail)
+	}
+	if !strings.Contains(strings.Join(plan, ";"), "nodeEventTbl_uuid_ts (uuid=? AND ts>?)") {
+		t.Fatalf("0x62e0c8a5 index not used:%v", plan)
+	}
+}
@@ -4,8 +4,29 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// MonitorSummaryT 地址池、静态预留、存活状态及排空的汇总
+type MonitorSummaryT struct {
+	Pools        []SubnetPoolStatT `json:"pools"` // 地址池使用情况
+	Reservations []ReservationT    `json:"reservations"`
+	States       map[string]int    `json:"states"` // 各存活状态的 node 数
+	Drains       []DrainStatT      `json:"drains"` // 排空中的 repeater
+}
+
 func MonitorGet(c *gin.Context) {
 	nodeLst := NodeGetAll()
 
 	c.JSON(200, nodeLst)
 }
+
+func MonitorSummaryGet(c *gin.Context) {
+	var rsp MonitorSummaryT
+	rsp.States = make(map[string]int)
+	for _, node := range NodeGetAll() {
+		rsp.States[node.State]++
+	}
+	rsp.Pools = SubnetPoolStat()
+	rsp.Reservations = ReservationGetAll()
+	rsp.Drains = DrainGetAll()
+
+	c.JSON(200, &rsp)
+}
@@ -2,56 +2,138 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/shankusu2017/proto_pb/go/proto"
-	"github.com/shankusu2017/utils"
 	pb "google.golang.org/protobuf/proto"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
 
-const (
-	SUBNET_PAC_MIN      = 20
-	SUBNET_PAC_MAX      = 49
-	SUBNET_REPEATER_MIN = 120
-	SUBNET_REPEATER_MAX = 199
-)
-
 type nodeMgrT struct {
-	nodeUuidMap     map[string]*NodeT // uuid->node
-	nodeSubNetIdMap map[int]*NodeT    // subNetId->node
+	nodeUuidMap     map[string]*NodeT         // uuid->node
+	nodeSubNetIdMap map[int]*NodeT            // subNetId->node
+	subnet          *subnetAllocT             // 子网号地址池
+	stickyMap       map[string]int            // uuid->已回收的子网号，再次上线时优先沿用
+	reserveUuidMap  map[string]*ReservationT  // uuid->静态预留
+	reserveSubIdMap map[int]*ReservationT     // subNetId->静态预留
+	leaseDur        time.Duration             // 租约时长
+	leaseGrace      time.Duration             // 租约过期后子网号的保留时长
+	ula             *ulaAllocT                // ipv6 ULA 前缀，nil:不分配
+	region          *regionTableT             // CIDR->region，nil:不区分 region
+	liveness        LivenessCfgT              // 存活状态的判定阈值
+	repeater        RepeaterCfgT              // repeater 列表的健康过滤
+	abnormalMap     map[string][]time.Time    // repeater.uuid->窗口内相关异常事件的时间(升序)
+	assignMap       map[string]*clientAssignT // 客户端 uuid->分配的 repeater
+	assignCntMap    map[string]int            // repeater.uuid->分配到的客户端数
+	repeaterVer     repeaterVerT              // repeater 集合的版本号
+	stream          *streamHubT               // 事件推送
+	auth            AuthCfgT                  // 节点消息的签名校验
+	credMap         map[string]*CredentialT   // uuid->签名密钥
+	seenMap         map[authSeenT]time.Time   // replayWindow 内已接受的消息->Ts
+	seenPrune       time.Time                 // 上次清理 seenMap 的时间
+	authNetLst      []*net.IPNet              // auth.enrollCidr
+	authLegacyMap   map[string]NodeAddrT      // 启动时已有但没有密钥的 node->登记的地址，迁移期内可不带签名
+	enroll          EnrollCfgT                // 登记审批
+	enrollMap       map[string]*EnrollT       // uuid->登记申请
+	tokenMap        map[string]*EnrollTokenT  // hash->一次性登记 token
+	attrMap         map[string]*NodeAttrT     // uuid->管理员设置的属性
+	rolePolicy      *rolePolicyT              // 角色策略
+	pac             *pacTableT                // 按地址推断角色用的国内地址表
+	pacCfg          PacCfgT                   // 重新加载时读取的文件
+	regionCfg       RegionCfgT                // 重新加载 pac 时一并重新加载 region 表
+	pacPinMap       map[string]pacPinT        // uuid->重新加载后保持的角色
+	pacReport       *PacReportT               // 最近一次生效的重新加载的结果
+	drain           DrainCfgT                 // repeater 排空
+	close           CloseCfgT                 // node 正常退出时子网号的处理
+	reaperStop      chan struct{}             // 关闭后回收协程退出
+	reaperDone      chan struct{}
+	store           Store
 	dataMtx         sync.Mutex
 }
 
 type NodeT struct {
 	Uuid     string    `json:"uuid,omitempty"`
-	IP       string    `json:"ip,omitempty"`
-	SubId    int       `json:"SubId,omitempty"` // 子网 ID
+	IP       string    `json:"ip,omitempty"`       // ipv4 公网地址
+	IPv6     string    `json:"ipv6,omitempty"`     // ipv6 公网地址
+	ULA      string    `json:"ula,omitempty"`      // 按子网号分配的 ipv6 ULA /64(仅输出)
+	Region   string    `json:"region,omitempty"`   // 按地址查 region 表(仅输出)
+	SubId    int       `json:"SubId,omitempty"`    // 子网 ID
+	Pool     string    `json:"pool,omitempty"`     // SubId 所属的地址池
+	Reserved bool      `json:"reserved,omitempty"` // SubId 为静态预留
 	RoleType int       `json:"roleType,omitempty"`
 	Ping     time.Time `json:"ping,omitempty"` // 最后一次 ping 的时间
 	Ver      string    `json:"ver,omitempty"`
+
+	LeaseExpire time.Time `json:"leaseExpire,omitempty"` // 租约到期时间，过期后再保留 grace 时长才回收子网号
+	State       string    `json:"state,omitempty"`       // 存活状态: online, suspect, offline, expired
+	StateTS     time.Time `json:"stateTs,omitempty"`     // 最后一次状态切换的时间
+
+	// repeater 上报的权重和容量(0:未上报)，输出时为生效值
+	Weight   int `json:"weight,omitempty"`
+	Capacity int `json:"capacity,omitempty"`
+	Assigned int `json:"assigned,omitempty"` // 分配到的客户端数(仅输出)
+
+	// 最近一次决定角色的来源及原因，如 "cidr: 1.2.3.4 in 1.2.0.0/16"，与 reqRole 一起保存在 node 属性中
+	RoleReason string `json:"roleReason,omitempty"`
+
+	// 管理员设置的属性(仅输出)，见 NodeAttrT
+	Note        string `json:"note,omitempty"`
+	Maintenance bool   `json:"maintenance,omitempty"` // 维护中的 repeater 不返回给客户端，租约过期也不回收
+	Drain       bool   `json:"drain,omitempty"`       // 排空中的 repeater 不返回给客户端，租约、keepalive 照常，见 DrainStatT
+
+	reqRole int  // 最近一次 boot 时请求的角色，重新加载 pac 时用于计算差异
+	closed  bool // 上报了 CLOSED，再次 boot/ping 前为 offline，随租约保存
+}
+
+func (node *NodeT) lease() *LeaseT {
+	return &LeaseT{
+		Uuid:     node.Uuid,
+		SubId:    node.SubId,
+		RoleType: node.RoleType,
+		Expire:   node.LeaseExpire,
+		Closed:   node.closed,
+	}
+}
+
+// leaseValid 租约是否仍有效(过期但未回收的 node 不再对外提供服务)
+func (node *NodeT) leaseValid(now time.Time) bool {
+	return now.Before(node.LeaseExpire)
 }
 
 var (
 	nodeMgr *nodeMgrT
 )
 
-// 更新 ping 时间戳
-func (mgr *nodeMgrT) updateNode(ip, uuid string) {
+// 更新 ping 时间戳并续约，DB 失败时内存保持原样
+func (mgr *nodeMgrT) updateNode(ip, uuid string) error {
 	mgr.dataMtx.Lock()
 	defer mgr.dataMtx.Unlock()
 
 	node, ok := mgr.nodeUuidMap[uuid]
 	if !ok {
-		log.Printf("ERROR 0x5a43bf8d node is nil, uuid:%s, cli.ip: %s", uuid, ip)
-		return
+		return errors.New(fmt.Sprintf("ERROR 0x5a43bf8d node is nil, uuid:%s, cli.ip: %s", uuid, ip))
 	}
 
+	old := *node
 	node.Ping = time.Now()
+	node.LeaseExpire = node.Ping.Add(mgr.leaseDur)
+	node.closed = false
+	err := mgr.store.CommitNetConfig(node, false)
+	if err != nil {
+		mgr.rollbackNode(node, &old)
+		return err
+	}
+	mgr.transitNode(node, NODE_STATE_ONLINE, node.Ping)
+	mgr.refreshRepeaterVer(node.Ping)
+
+	return nil
 }
 
 // 查找指定的 Node
@@ -63,126 +145,216 @@ func (mgr *nodeMgrT) findNode(uuid string) *NodeT {
 	return node
 }
 
-// 根据参数，新增一个 Node,并插入 map
-func (mgr *nodeMgrT) newNode(uuid, ip, ver string) *NodeT {
-	mgr.dataMtx.Lock()
-	defer mgr.dataMtx.Unlock()
-
+// 根据参数，新增一个 Node(尚未插入 map，DB 提交成功后由调用方插入)，调用方需持有 dataMtx
+func (mgr *nodeMgrT) newNode(uuid, ip, ver string, reqRole int) *NodeT {
 	var node = NodeT{}
 	node.Uuid = uuid
-	node.IP = ip
-	subNetAllocDone := false
-
-	localIP := utils.IsLocalIP(ip)
-	if localIP {
-		for i := SUBNET_PAC_MIN; i <= SUBNET_PAC_MAX; i++ {
-			_, exist := mgr.nodeSubNetIdMap[i]
-			if exist == false {
-				node.SubId = i
-				subNetAllocDone = true
-				break
-			}
+	node.setAddr(ip)
+	node.reqRole = reqRole
+
+	if res, reserved := mgr.reserveUuidMap[uuid]; reserved {
+		// 静态预留的直接使用预留的子网号和角色
+		if _, exist := mgr.nodeSubNetIdMap[res.SubId]; exist {
+			log.Printf("ERROR 0x2d81f6c0 reserved subId(%d) of uuid:%s in use", res.SubId, uuid)
+			return nil
 		}
-		node.RoleType = int(proto.Role_Pac)
+		node.RoleType = res.RoleType
+		node.SubId = res.SubId
+		node.Pool = mgr.subnet.poolOf(res.SubId)
+		node.Reserved = true
+		node.RoleReason = ROLE_SOURCE_RESERVATION
 	} else {
-		for i := SUBNET_REPEATER_MIN; i <= SUBNET_REPEATER_MAX; i++ {
-			_, exist := mgr.nodeSubNetIdMap[i]
-			if exist == false {
-				node.SubId = i
-				subNetAllocDone = true
-				break
-			}
+		node.RoleType, node.RoleReason = mgr.decideRole(uuid, ip, "", reqRole)
+		// 之前回收过的 uuid 优先沿用原子网号
+		prefer := mgr.stickyMap[uuid]
+		subId, pool, subNetAllocDone := mgr.subnet.allocPrefer(node.RoleType, prefer, mgr.subIdBusy)
+		if subNetAllocDone != true {
+			return nil
 		}
-		node.RoleType = int(proto.Role_Repeater)
-	}
-	if subNetAllocDone != true {
-		return nil
+		node.SubId = subId
+		node.Pool = pool
 	}
 
 	node.Ping = time.Now()
+	node.LeaseExpire = node.Ping.Add(mgr.leaseDur)
 	node.Ver = ver
 
-	mgr.nodeUuidMap[node.Uuid] = &node
-	mgr.nodeSubNetIdMap[node.SubId] = &node
-
 	return &node
 }
 
+// 切换角色并重新分配子网号，调用方需持有 dataMtx
 func (mgr *nodeMgrT) switchNodeSubNetIdRoleType(oldId int, newRole int, node *NodeT) bool {
+	subId, pool, ok := mgr.subnet.alloc(newRole, mgr.subIdBusy)
+	if !ok {
+		return false
+	}
+
+	delete(mgr.nodeSubNetIdMap, oldId)
+	node.SubId = subId
+	node.Pool = pool
+	node.RoleType = newRole
+	node.Ping = time.Now()
+	node.LeaseExpire = node.Ping.Add(mgr.leaseDur)
+	mgr.nodeSubNetIdMap[subId] = node
+	return true
+}
+
+// rollbackNode DB 提交失败，内存中的 node 恢复为 old，调用方需持有 dataMtx
+func (mgr *nodeMgrT) rollbackNode(node *NodeT, old *NodeT) {
+	if node.SubId != old.SubId {
+		delete(mgr.nodeSubNetIdMap, node.SubId)
+		mgr.nodeSubNetIdMap[old.SubId] = node
+	}
+	*node = *old
+}
+
+// bootNode node 启动：新分配或沿用子网号，按角色策略(reqRole 为 node 请求的角色)决定的角色变化时切换子网号
+// 内存修改与 DB 提交在同一把锁内完成，DB 失败时回滚内存，返回 node 的副本
+func (mgr *nodeMgrT) bootNode(uuid, ip, ver string, reqRole int) (NodeT, string, error) {
 	mgr.dataMtx.Lock()
 	defer mgr.dataMtx.Unlock()
 
-	if int(proto.Role_Pac) == newRole {
-		for i := SUBNET_PAC_MIN; i <= SUBNET_PAC_MAX; i++ {
-			_, exist := mgr.nodeSubNetIdMap[i]
-			if exist == false {
-				delete(mgr.nodeSubNetIdMap, oldId)
-				node.SubId = i
-				node.RoleType = newRole
-				node.Ping = time.Now()
-				mgr.nodeSubNetIdMap[i] = node
-				return true
-			}
+	addMsg := ""
+	node, exist := mgr.nodeUuidMap[uuid]
+	if !exist {
+		node = mgr.newNode(uuid, ip, ver, reqRole)
+		if node == nil {
+			return NodeT{}, "", errors.New(fmt.Sprintf("ERROR 0x554a57ea newNode fail, uuid:%s", uuid))
+		}
+		err := mgr.store.CommitNetConfig(node, true)
+		if err != nil {
+			return NodeT{}, "", err
+		}
+		delete(mgr.stickyMap, uuid)
+		mgr.nodeUuidMap[node.Uuid] = node
+		mgr.nodeSubNetIdMap[node.SubId] = node
+		mgr.saveBootRole(node, node.Ping)
+		mgr.publishNode(EVENT_NODE_JOIN, node, node.Ping)
+		mgr.transitNode(node, NODE_STATE_ONLINE, node.Ping)
+		mgr.refreshRepeaterVer(node.Ping)
+		return *node, addMsg, nil
+	}
+
+	old := *node
+	if reserved, changed := mgr.applyReservation(node); reserved {
+		// 静态预留的不随 ip 切换角色
+		node.RoleReason = ROLE_SOURCE_RESERVATION
+		if changed {
+			addMsg = fmt.Sprintf("switch 2 reserved type: %d, subNet: %d", node.RoleType, node.SubId)
 		}
 	} else {
-		for i := SUBNET_REPEATER_MIN; i <= SUBNET_REPEATER_MAX; i++ {
-			_, exist := mgr.nodeSubNetIdMap[i]
-			if exist == false {
-				delete(mgr.nodeSubNetIdMap, oldId)
-				node.SubId = i
-				node.RoleType = newRole
-				node.Ping = time.Now()
-				mgr.nodeSubNetIdMap[i] = node
-				return true
+		newRole, reason := mgr.decideRole(uuid, ip, node.IP, reqRole)
+		node.RoleReason = reason
+		node.reqRole = reqRole
+		// 角色没变，沿用之前的子网参数
+		if node.RoleType != newRole {
+			// 尝试切换到新的角色并获取新的网络参数，释放旧的参数
+			done := mgr.switchNodeSubNetIdRoleType(node.SubId, newRole, node)
+			if done == false {
+				return NodeT{}, "", errors.New(fmt.Sprintf("ERROR 0x74b3cf20 switchNodeSubNetIdRoleType fail, uuid:%s", uuid))
 			}
+			addMsg = fmt.Sprintf("switch 2 newType: %d, subNet: %d", node.RoleType, node.SubId)
 		}
 	}
 
-	return false
+	// 刷新下
+	node.setAddr(ip)
+	node.Ping = time.Now()
+	node.LeaseExpire = node.Ping.Add(mgr.leaseDur)
+	node.Ver = ver
+	node.closed = false
+
+	err := mgr.store.CommitNetConfig(node, false)
+	if err != nil {
+		mgr.rollbackNode(node, &old)
+		return NodeT{}, "", err
+	}
+	mgr.saveBootRole(node, node.Ping)
+	if node.RoleType != old.RoleType {
+		mgr.publishNode(EVENT_NODE_ROLE, node, node.Ping)
+	} else if node.SubId != old.SubId {
+		mgr.publishNode(EVENT_NODE_SUBNET, node, node.Ping)
+	}
+	mgr.transitNode(node, NODE_STATE_ONLINE, node.Ping)
+	mgr.refreshRepeaterVer(node.Ping)
+
+	return *node, addMsg, nil
 }
 
-// 获取指定角色(pac或repeater)的node列表
-func (mgr *nodeMgrT) getNodeIPByRoleType(roleType int) []string {
+// 获取指定角色(pac或repeater)的node地址列表
+func (mgr *nodeMgrT) getNodeAddrByRoleType(roleType int) []NodeAddrT {
 	mgr.dataMtx.Lock()
 	defer mgr.dataMtx.Unlock()
 
-	ipList := make([]string, 0)
+	now := time.Now()
+	addrList := make([]NodeAddrT, 0)
 	for _, node := range mgr.nodeUuidMap {
-		if node.RoleType == roleType {
-			ipList = append(ipList, node.IP)
+		if node.RoleType == roleType && node.leaseValid(now) {
+			addrList = append(addrList, NodeAddrT{IPv4: node.IP, IPv6: node.IPv6})
 		}
 	}
 
-	return ipList
+	return addrList
 }
 
-// 删除过期的 node
+// 定期刷新存活状态，删除租约过期且超过保留期的 node，回收其子网号
 func (mgr *nodeMgrT) loopScanDeadNode() {
+	defer close(mgr.reaperDone)
+
+	ticker := time.NewTicker(time.Duration(mgr.liveness.Sweep))
+	defer ticker.Stop()
 	for {
-		time.Sleep(time.Minute * 1)
-		now := time.Now()
-
-		mgr.dataMtx.Lock()
-		for uuid, node := range mgr.nodeUuidMap {
-			// 有效期N分钟，后期可以再根据实际情况优化
-			if node.Ping.Before(now.Add(time.Minute * -30)) {
-				log.Printf("LOG 0x71bec216 node.uuid(%s) too old, delete it", uuid)
-				delete(mgr.nodeUuidMap, uuid)
-				delete(mgr.nodeSubNetIdMap, node.SubId)
-				err := DeleteNetConfigItemByUuid(uuid)
-				if err != nil {
-					log.Printf("%s", err)
-				}
+		select {
+		case <-mgr.reaperStop:
+			return
+		case now := <-ticker.C:
+			mgr.scanDeadNode(now)
+		}
+	}
+}
+
+// stopReaper 停止回收协程，等正在进行的一轮扫描完成
+func (mgr *nodeMgrT) stopReaper() {
+	close(mgr.reaperStop)
+	<-mgr.reaperDone
+}
+
+func (mgr *nodeMgrT) scanDeadNode(now time.Time) {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	mgr.sweepNodeState(now)
+	mgr.expireAssign(now)
+	mgr.expireDrain(now)
+	for uuid, node := range mgr.nodeUuidMap {
+		// 静态预留、维护中的不回收
+		if _, reserved := mgr.reserveUuidMap[uuid]; reserved {
+			continue
+		}
+		if attr, ok := mgr.attrMap[uuid]; ok && attr.Maintenance {
+			continue
+		}
+		if node.LeaseExpire.Add(mgr.leaseGrace).Before(now) {
+			// DB 删除失败则保留在内存中，下一轮再试
+			err := mgr.store.ReleaseNetConfig(uuid)
+			if err != nil {
+				log.Printf("ERROR 0x2a8c51f6 reclaim node.uuid(%s) fail:%s", uuid, err)
+				continue
 			}
+			log.Printf("LOG 0x71bec216 node.uuid(%s) lease expired at %s, reclaim subId:%d", uuid, node.LeaseExpire, node.SubId)
+			delete(mgr.nodeUuidMap, uuid)
+			delete(mgr.nodeSubNetIdMap, node.SubId)
+			mgr.stickyMap[uuid] = node.SubId
+			delete(mgr.abnormalMap, uuid)
+			metricReaperEvict.Inc()
 		}
-		mgr.dataMtx.Unlock()
 	}
+	mgr.refreshRepeaterVer(now)
 }
 
 func NodeBootEvent(c *gin.Context, msg *proto.MsgEventPost) {
 	ip := c.RemoteIP()
 
-	var node *NodeT
 	mMachine := msg.GetMachine()
 	if mMachine == nil {
 		log.Printf("ERROR 0x443ff8d3 machine is nil, cli.ip:%s", ip)
@@ -195,59 +367,34 @@ func NodeBootEvent(c *gin.Context, msg *proto.MsgEventPost) {
 	}
 	uuid := mMachine.GetUUID()
 	ver := mNode.GetVer()
-	isNewNode := false
-	addMsg := ""
 
-	// 新生成的 Node 还是已有的 Node?
-	node = nodeMgr.findNode(uuid)
-	if node == nil {
-		node = nodeMgr.newNode(uuid, ip, ver)
-		if node == nil {
-			log.Printf("ERROR 0x554a57ea newNode fail, uuid:%s", uuid)
-			return
-		}
-		isNewNode = true
-	} else {
-		isLocal := utils.IsLocalIP(ip)
-		// 角色没变，沿用之前的子网参数
-		if (isLocal && node.RoleType == int(proto.Role_Pac)) || (isLocal == false && node.RoleType == int(proto.Role_Repeater)) {
-			// node.RoleType = proto.Role_Pac
-		} else {
-			newRole := proto.Role_Default
-			if isLocal {
-				newRole = proto.Role_Pac
-			} else {
-				newRole = proto.Role_Repeater
-			}
-			// 尝试切换到新的角色并获取新的网络参数，释放旧的参数
-			done := nodeMgr.switchNodeSubNetIdRoleType(node.SubId, int(newRole), node)
-			if done == false {
-				log.Printf("ERROR 0x74b3cf20 switchNodeSubNetIdRoleType fail")
-				return
-			}
-			addMsg = fmt.Sprintf("switch 2 newType: %d, subNet: %d", node.RoleType, node.SubId)
-		}
+	// 开启登记审批时，未知的 uuid 需审批通过或持有 token
+	status, e := nodeMgr.enrollCheck(uuid, ip, ver, msg.GetToken(), time.Now())
+	if e != nil {
+		nodeMgr.recordEvent(e)
+	}
+	if status != ENROLL_STATUS_APPROVED {
+		log.Printf("LOG 0x6e93b1f2 node.uuid(%s) ip:%s enroll %s", uuid, ip, status)
+		c.ProtoBuf(http.StatusOK, enrollRsp(msg, status))
+		return
 	}
 
-	// 刷新下
-	node.IP = ip
-	node.Ping = time.Now()
-	node.Ver = ver
-
-	if isNewNode {
-		InsertNetConfig(node)
-	} else {
-		UpdateNetConfigRowByUuid(node)
+	// 新生成的 Node 还是已有的 Node?
+	node, addMsg, err := nodeMgr.bootNode(uuid, ip, ver, int(mNode.GetRole()))
+	if err != nil {
+		log.Printf("ERROR 0x3e6a9b25 boot node fail:%s", err)
+		return
 	}
+	nodeMgr.reportWeight(uuid, msg.GetNode())
 
-	addMsg = fmt.Sprintf("%s roleType.now: %d", addMsg, node.RoleType)
+	addMsg = fmt.Sprintf("%s roleType.now: %d (%s)", addMsg, node.RoleType, node.RoleReason)
 	eMsg := msg.GetMsg()
 	if eMsg != nil {
 		addMsg = fmt.Sprintf("%s [%s]", eMsg.Msg, addMsg)
 	}
 
 	// 存DB
-	InsertNodeEvent(ip, proto.Role(node.RoleType), addMsg, msg)
+	nodeMgr.recordEvent(newNodeEvent(ip, proto.Role(node.RoleType), addMsg, msg))
 
 	{
 		var rsp proto.MsgEventRsp
@@ -260,7 +407,10 @@ func NodeBootEvent(c *gin.Context, msg *proto.MsgEventPost) {
 		}
 		rsp.Net = &proto.Net{
 			SubId: int32(node.SubId),
+			ULA:   nodeMgr.ula.subnetOf(node.SubId),
 		}
+		// 登记时下发签名密钥，直到收到第一条带签名的消息
+		rsp.Secret = nodeMgr.enrollNode(uuid, ip)
 		// 返回网络参数给 node
 		c.ProtoBuf(http.StatusOK, &rsp)
 	}
@@ -275,12 +425,12 @@ func NodePingEvent(c *gin.Context, msg *proto.MsgEventPost) {
 	}
 	uuid := msg.GetMachine().GetUUID()
 
-	nodeMgr.updateNode(ip, uuid)
-
-	err := UpdateNetConfigPingByUuid(uuid)
+	err := nodeMgr.updateNode(ip, uuid)
 	if err != nil {
 		log.Printf("0x56d90c0b ping update err:%s", err)
+		return
 	}
+	nodeMgr.reportWeight(uuid, msg.GetNode())
 }
 
 func NodeAbnormalEvent(c *gin.Context, msg *proto.MsgEventPost) {
@@ -290,43 +440,193 @@ func NodeAbnormalEvent(c *gin.Context, msg *proto.MsgEventPost) {
 		log.Printf("ERROR 0x7e07ffea data has nil, cli.ip:%s, packet.json:%s", c.RemoteIP(), string(jsonTxt))
 		return
 	}
-	InsertNodeEvent(c.RemoteIP(), proto.Role(msg.GetNode().Role), msg.GetMsg().Msg, msg)
+	now := time.Now()
+	nodeMgr.dataMtx.Lock()
+	subjectLst := nodeMgr.recordAbnormal(msg.GetMachine().GetUUID(), msg.GetMsg().Msg, now)
+	nodeMgr.refreshRepeaterVer(now)
+	nodeMgr.dataMtx.Unlock()
+
+	// 告警按涉及的 repeater 统计，多个客户端报告同一 repeater 时一起计数
+	nodeMgr.recordEvent(newNodeEvent(c.RemoteIP(), proto.Role(msg.GetNode().Role), msg.GetMsg().Msg, msg), subjectLst...)
+}
+
+// NodeCloseEvent node 正常退出
+func NodeCloseEvent(c *gin.Context, msg *proto.MsgEventPost) {
+	mMachine := msg.GetMachine()
+	if mMachine == nil {
+		log.Printf("ERROR 0x4c7e1b93 machine is nil, cli.ip:%s", c.RemoteIP())
+		return
+	}
+	uuid := mMachine.GetUUID()
+	node, addMsg, err := nodeMgr.closeNode(uuid, time.Now())
+	if err != nil {
+		log.Printf("0x2e7c49a0 close node err:%s, cli.ip:%s", err, c.RemoteIP())
+		return
+	}
+	if len(msg.GetMsg().GetMsg()) > 0 {
+		addMsg = strings.TrimSpace(fmt.Sprintf("%s %s", msg.GetMsg().GetMsg(), addMsg))
+	}
+	log.Printf("LOG 0x6a91d3f5 node.uuid(%s) closed, %s", uuid, addMsg)
+	nodeMgr.recordEvent(newNodeEvent(c.RemoteIP(), proto.Role(node.RoleType), addMsg, msg))
 }
 
-func NodeMgrInit(dbPath string) {
+func NodeMgrInit(store Store, cfg *ConfigT) {
+	subnet, err := newSubnetAlloc(cfg.SubnetPools)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	nodeMgr = &nodeMgrT{}
 	nodeMgr.nodeUuidMap = make(map[string]*NodeT)
 	nodeMgr.nodeSubNetIdMap = make(map[int]*NodeT)
-
-	InitDB(dbPath)
-	allNode, err := LoadNetConfigItemAll()
+	nodeMgr.subnet = subnet
+	nodeMgr.store = store
+	nodeMgr.stickyMap = make(map[string]int)
+	nodeMgr.reserveUuidMap = make(map[string]*ReservationT)
+	nodeMgr.reserveSubIdMap = make(map[int]*ReservationT)
+	nodeMgr.leaseDur = time.Duration(cfg.Lease.Duration)
+	nodeMgr.leaseGrace = time.Duration(cfg.Lease.Grace)
+	nodeMgr.liveness = cfg.Liveness
+	nodeMgr.repeater = cfg.Repeater
+	nodeMgr.abnormalMap = make(map[string][]time.Time)
+	nodeMgr.assignMap = make(map[string]*clientAssignT)
+	nodeMgr.assignCntMap = make(map[string]int)
+	nodeMgr.repeaterVer = newRepeaterVer(time.Now())
+	nodeMgr.stream = newStreamHub(&cfg.Stream)
+	nodeMgr.auth = cfg.Auth
+	nodeMgr.credMap = make(map[string]*CredentialT)
+	nodeMgr.seenMap = make(map[authSeenT]time.Time)
+	nodeMgr.authLegacyMap = make(map[string]NodeAddrT)
+	nodeMgr.authNetLst, err = cfg.Auth.enrollNetLst()
+	if err != nil {
+		log.Fatal(err)
+	}
+	nodeMgr.enroll = cfg.Enroll
+	nodeMgr.enrollMap = make(map[string]*EnrollT)
+	nodeMgr.tokenMap = make(map[string]*EnrollTokenT)
+	nodeMgr.attrMap = make(map[string]*NodeAttrT)
+	nodeMgr.rolePolicy, err = newRolePolicy(&cfg.Role)
+	if err != nil {
+		log.Fatal(err)
+	}
+	nodeMgr.ula, err = newUlaAlloc(cfg.IPv6.ULAPrefix)
+	if err != nil {
+		log.Fatal(err)
+	}
+	nodeMgr.region, err = newRegionTable(&cfg.Region)
+	if err != nil {
+		log.Fatal(err)
+	}
+	nodeMgr.pac, err = newPacTable(&cfg.Pac)
+	if err != nil {
+		log.Fatal(err)
+	}
+	nodeMgr.pacCfg = cfg.Pac
+	nodeMgr.regionCfg = cfg.Region
+	nodeMgr.pacPinMap = make(map[string]pacPinT)
+	nodeMgr.drain = cfg.Drain
+	nodeMgr.close = cfg.Close
+	nodeMgr.reaperStop = make(chan struct{})
+	nodeMgr.reaperDone = make(chan struct{})
+
+	allNode, err := store.LoadNetConfigItemAll()
 	if err != nil {
 		log.Fatal(err)
 	}
+	allLease, err := store.LoadLeaseAll()
+	if err != nil {
+		log.Fatal(err)
+	}
+	allRes, err := store.LoadReservationAll()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, res := range allRes {
+		nodeMgr.reserveUuidMap[res.Uuid] = res
+		nodeMgr.reserveSubIdMap[res.SubId] = res
+	}
+	allCred, err := store.LoadCredentialAll()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, cred := range allCred {
+		nodeMgr.credMap[cred.Uuid] = cred
+	}
+	allEnroll, err := store.LoadEnrollAll()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, enroll := range allEnroll {
+		nodeMgr.enrollMap[enroll.Uuid] = enroll
+	}
+	allToken, err := store.LoadEnrollTokenAll()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, token := range allToken {
+		nodeMgr.tokenMap[token.Hash] = token
+	}
+	allAttr, err := store.LoadNodeAttrAll()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, attr := range allAttr {
+		nodeMgr.attrMap[attr.Uuid] = attr
+		if attr.PacPinRole != 0 {
+			nodeMgr.pacPinMap[attr.Uuid] = pacPinT{role: attr.PacPinRole, ip: attr.PacPinIP}
+		}
+	}
+	leaseMap := make(map[string]*LeaseT)
+	for _, lease := range allLease {
+		if lease.Released {
+			nodeMgr.stickyMap[lease.Uuid] = lease.SubId
+		} else {
+			leaseMap[lease.Uuid] = lease
+		}
+	}
 
-	/* 加载、校验数据 */
+	/* 加载、校验数据，冲突时按配置修复或退出 */
+	allNode, err = nodeMgr.reconcileNetConfig(allNode, leaseMap, cfg.Reconcile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	now := time.Now()
 	for _, node := range allNode {
-		var n NodeT
-		n.Uuid = node.Uuid
-		n.IP = node.IP
-		n.SubId = node.SubId
-		n.RoleType = node.RoleType
-		n.Ping = node.TS
-		n.Ver = node.Ver
-		{ // 不得重复
-			_, existA := nodeMgr.nodeUuidMap[node.Uuid]
-			_, existB := nodeMgr.nodeSubNetIdMap[node.SubId]
-			if existA == true || existB == true {
-				log.Fatal(fmt.Sprintf("0x5b6cd7cc uuid or subId exist, uuid: %s, subNetId:%d", node.Uuid, node.SubId))
-			}
+		n := nodeMgr.nodeOfNetConfig(node, leaseMap[node.Uuid])
+		// 重启前的状态切换时间未保存，以启动时间为准，不记录事件
+		n.State = nodeMgr.nodeState(n, now)
+		n.StateTS = now
+		if attr, ok := nodeMgr.attrMap[n.Uuid]; ok {
+			n.reqRole, n.RoleReason = attr.ReqRole, attr.RoleReason
+		}
+		nodeMgr.nodeUuidMap[n.Uuid] = n
+		nodeMgr.nodeSubNetIdMap[n.SubId] = n
+		if _, ok := nodeMgr.credMap[n.Uuid]; !ok {
+			nodeMgr.authLegacyMap[n.Uuid] = NodeAddrT{IPv4: n.IP, IPv6: n.IPv6}
 		}
-		nodeMgr.nodeUuidMap[node.Uuid] = &n
-		nodeMgr.nodeSubNetIdMap[node.SubId] = &n
 	}
+	nodeMgr.repeaterVer.sum = nodeMgr.repeaterSum(nodeMgr.repeaterCand(now))
 
 	go nodeMgr.loopScanDeadNode()
 }
 
+// nodeView 补齐仅输出的字段，调用方需持有 dataMtx
+func (mgr *nodeMgrT) nodeView(node *NodeT) NodeT {
+	n := *node
+	n.ULA = mgr.ula.subnetOf(n.SubId)
+	n.Region = mgr.region.nodeRegion(node)
+	if n.RoleType == int(proto.Role_Repeater) {
+		n.Weight, n.Capacity = mgr.repeaterWeight(node)
+		n.Assigned = mgr.assignCntMap[n.Uuid]
+	}
+	if attr, ok := mgr.attrMap[n.Uuid]; ok {
+		n.Note = attr.Note
+		n.Maintenance = attr.Maintenance
+		n.Drain = mgr.draining(n.Uuid, time.Now())
+	}
+	return n
+}
+
 func NodeGetAll() []NodeT {
 	nodeMgr.dataMtx.Lock()
 	defer nodeMgr.dataMtx.Unlock()
@@ -334,7 +634,7 @@ func NodeGetAll() []NodeT {
 	lst := make([]NodeT, 0)
 
 	for _, node := range nodeMgr.nodeUuidMap {
-		lst = append(lst, *node)
+		lst = append(lst, nodeMgr.nodeView(node))
 	}
 
 	return lst
@@ -353,6 +653,7 @@ func NodeRepeaterGet(c *gin.Context) {
 	err = pb.Unmarshal(bodyBytes, &req)
 	if err != nil {
 		log.Printf("0x4842fc43 Invalid request body(%v), ip:%s", bodyBytes, ip)
+		metricDecodeFail.WithLabelValues("NodeRepeaterGet").Inc()
 		return
 	}
 
@@ -363,14 +664,35 @@ func NodeRepeaterGet(c *gin.Context) {
 	}
 	log.Printf("0x2e6b9922 req repeater server list client(ip:%s, id:%s)", ip, machine.GetUUID())
 
+	// 版本号在挑选之前读取，期间集合变化时客户端下次请求会拿到新列表
+	ver := nodeMgr.repeaterVersion(time.Now())
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	if etagMatch(ifNoneMatch, ver) {
+		// long-poll: 等到集合变化或超时
+		wait := parseLongPollWait(c.Query("wait"), time.Duration(nodeMgr.repeater.LongPollMax))
+		if wait > 0 {
+			ver = nodeMgr.waitRepeaterVer(c.Request.Context(), ver, wait)
+		}
+		if etagMatch(ifNoneMatch, ver) {
+			nodeMgr.touchAssign(machine.GetUUID(), time.Now())
+			c.Header("ETag", repeaterETag(ver))
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
 	var rsp proto.MsgRepeaterServerInfoRsp
 
-	ipLst := nodeMgr.getNodeIPByRoleType(int(proto.Role_Repeater))
-	for _, iP := range ipLst {
-		node := &proto.RepeaterServerNode{IPv4: iP}
-		rsp.Servers = append(rsp.Servers, node)
+	// 按 hash 或负载挑选，健康的、客户端 region 优先的在前；仅有 ipv6 的 repeater 其 IPv4 为空，只返回给能使用 ipv6 的客户端
+	nodeLst := nodeMgr.assignRepeater(machine.GetUUID(), ip, time.Now())
+	addrLst := make([]NodeAddrT, 0, len(nodeLst))
+	for _, n := range nodeLst {
+		addrLst = append(addrLst, NodeAddrT{IPv4: n.IP, IPv6: n.IPv6})
+		rsp.Servers = append(rsp.Servers, &proto.RepeaterServerNode{IPv4: n.IP, IPv6: n.IPv6})
 	}
-	log.Printf("DEBUG 0x2eda1c94 iplst:%v", ipLst)
+	log.Printf("DEBUG 0x2eda1c94 addrLst:%v", addrLst)
+	rsp.Version = ver
 
+	c.Header("ETag", repeaterETag(ver))
 	c.ProtoBuf(http.StatusOK, &rsp)
 }
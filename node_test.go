@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// failStoreT 提交网络参数时按需返回错误
+type failStoreT struct {
+	*memStoreT
+	fail bool
+}
+
+func (s *failStoreT) CommitNetConfig(node *NodeT, insert bool) error {
+	if s.fail {
+		return errors.New("0x3c8e5a1f commit fail")
+	}
+	return s.memStoreT.CommitNetConfig(node, insert)
+}
+
+func TestBootNodeRollback(t *testing.T) {
+	store := &failStoreT{memStoreT: NewMemStore()}
+	NodeMgrInit(store, configDefault())
+	t.Cleanup(nodeMgr.stopReaper)
+	mgr := nodeMgr
+
+	// 静态预留的 node 不依赖 ip 判断角色
+	if err := mgr.reservationSet(&ReservationT{Uuid: "uuid-a", SubId: 150, RoleType: 1000}); err != nil {
+		t.Fatalf("0x877d616a reserve fail:%v", err)
+	}
+	node, _, err := mgr.bootNode("uuid-a", "1.1.1.1", "v1", 0)
+	if err != nil || node.SubId != 150 {
+		t.Fatalf("0x1d7f2c48 boot fail:%v, subId:%d", err, node.SubId)
+	}
+
+	// 预留改为其他子网号，DB 失败时内存保持原样
+	if err := mgr.reservationSet(&ReservationT{Uuid: "uuid-a", SubId: 151, RoleType: 1000}); err != nil {
+		t.Fatalf("0x781e9b41 reserve fail:%v", err)
+	}
+	store.fail = true
+	_, _, err = mgr.bootNode("uuid-a", "1.1.1.1", "v2", 0)
+	if err == nil {
+		t.Fatalf("0x4b0e6a93 commit error ignored")
+	}
+	n := mgr.nodeUuidMap["uuid-a"]
+	if n.SubId != 150 || n.Ver != "v1" || mgr.nodeSubNetIdMap[150] != n || mgr.nodeSubNetIdMap[151] != nil {
+		t.Fatalf("0x6f25d8b1 not rollback, subId:%d, ver:%s", n.SubId, n.Ver)
+	}
+	if mgr.updateNode("1.1.1.1", "uuid-a") == nil || !n.Ping.Equal(node.Ping) {
+		t.Fatalf("0x2e91c7a5 ping not rollback")
+	}
+
+	// 新 node 提交失败不进入内存
+	if err := mgr.reservationSet(&ReservationT{Uuid: "uuid-b", SubId: 152, RoleType: 1000}); err != nil {
+		t.Fatalf("0x8a9c30b9 reserve fail:%v", err)
+	}
+	_, _, err = mgr.bootNode("uuid-b", "2.2.2.2", "v1", 0)
+	if err == nil || mgr.nodeUuidMap["uuid-b"] != nil || mgr.nodeSubNetIdMap[152] != nil {
+		t.Fatalf("0x58c3a0e7 new node not rollback")
+	}
+
+	store.fail = false
+	node, _, err = mgr.bootNode("uuid-a", "1.1.1.1", "v2", 0)
+	if err != nil || node.SubId != 151 || mgr.nodeSubNetIdMap[151] == nil || mgr.nodeSubNetIdMap[150] != nil {
+		t.Fatalf("0x0a7d4e36 boot after recover fail:%v, subId:%d", err, node.SubId)
+	}
+	rowLst, _ := store.FindNetConfigItemByUuid("uuid-a")
+	if len(rowLst) != 1 || rowLst[0].SubId != 151 {
+		t.Fatalf("0x7c12b9f0 db not committed")
+	}
+}
+
+func TestReconcileNetConfig(t *testing.T) {
+	now := time.Now()
+	allNode := []*NetConfigT{
+		{Uuid: "uuid-a", SubId: 120, RoleType: 1000, TS: now.Add(-time.Hour)},
+		{Uuid: "uuid-a", SubId: 121, RoleType: 1000, TS: now},
+		{Uuid: "uuid-b", SubId: 121, RoleType: 1000, TS: now.Add(-time.Minute)},
+		{Uuid: "uuid-c", SubId: 300, RoleType: 1000, TS: now},
+		{Uuid: "uuid-d", SubId: 150, RoleType: 1000, TS: now},
+		{Uuid: "uuid-e", SubId: 122, RoleType: 1000, TS: now},
+	}
+
+	store := NewMemStore()
+	NodeMgrInit(store, configDefault())
+	t.Cleanup(nodeMgr.stopReaper)
+	mgr := nodeMgr
+	if err := mgr.reservationSet(&ReservationT{Uuid: "uuid-x", SubId: 150, RoleType: 1000}); err != nil {
+		t.Fatalf("0x89096c75 reserve fail:%v", err)
+	}
+	// 内存实现不允许重复，只写入不冲突的部分
+	for _, i := range []int{1, 3, 4, 5} {
+		r := *allNode[i]
+		store.netConfigMap[r.Uuid] = &r
+	}
+
+	_, err := mgr.reconcileNetConfig(append([]*NetConfigT{}, allNode...), make(map[string]*LeaseT), false)
+	if err == nil {
+		t.Fatalf("0x46e1d08b conflict not detected")
+	}
+
+	leaseMap := map[string]*LeaseT{"uuid-e": {Uuid: "uuid-e", SubId: 199, RoleType: 1000}}
+	keepLst, err := mgr.reconcileNetConfig(allNode, leaseMap, true)
+	if err != nil || len(keepLst) != 2 {
+		t.Fatalf("0x13f7c6a2 reconcile fail:%v, keep:%d", err, len(keepLst))
+	}
+	keepMap := make(map[string]int)
+	for _, row := range keepLst {
+		keepMap[row.Uuid] = row.SubId
+	}
+	if keepMap["uuid-a"] != 121 || keepMap["uuid-e"] != 122 {
+		t.Fatalf("0x5ad28e40 keep error:%v", keepMap)
+	}
+	if leaseMap["uuid-e"].SubId != 122 || leaseMap["uuid-a"].SubId != 121 {
+		t.Fatalf("0x29b0f7d5 lease not repaired")
+	}
+
+	leaseLst, _ := store.LoadLeaseAll()
+	for _, lease := range leaseLst {
+		if keepMap[lease.Uuid] != lease.SubId {
+			t.Fatalf("0x61c4a3e8 db lease error, uuid:%s, subId:%d", lease.Uuid, lease.SubId)
+		}
+	}
+	rowLst, _ := store.FindNetConfigItemByUuid("uuid-a")
+	if len(rowLst) != 1 || rowLst[0].SubId != 121 {
+		t.Fatalf("0x0e83d5b6 db row not repaired")
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"log"
+	"net/http"
+	"time"
+)
+
+// 管理操作的名称，记录在审计表的 action 中
+const (
+	ADMIN_ACTION_EVICT       = "evict"
+	ADMIN_ACTION_ROLE        = "role"
+	ADMIN_ACTION_SUBNET      = "subnet"
+	ADMIN_ACTION_EDIT        = "edit"
+	ADMIN_ACTION_MAINTENANCE = "maintenance"
+	ADMIN_ACTION_DRAIN       = "drain"
+)
+
+var errNodeNotFound = errors.New("0x58c2e7a1 node not found")
+
+// nodeLookup 调用方需持有 dataMtx
+func (mgr *nodeMgrT) nodeLookup(uuid string) (*NodeT, error) {
+	node, ok := mgr.nodeUuidMap[uuid]
+	if !ok {
+		return nil, fmt.Errorf("%w, uuid:%s", errNodeNotFound, uuid)
+	}
+	return node, nil
+}
+
+// setNodeAttr 修改并保存 node 属性，调用方需持有 dataMtx
+func (mgr *nodeMgrT) setNodeAttr(uuid string, now time.Time, fn func(attr *NodeAttrT)) error {
+	attr := NodeAttrT{Uuid: uuid}
+	if old, ok := mgr.attrMap[uuid]; ok {
+		attr = *old
+	}
+	fn(&attr)
+	attr.Updated = now
+
+	err := mgr.store.UpsertNodeAttr(&attr)
+	if err != nil {
+		return err
+	}
+	mgr.attrMap[uuid] = &attr
+	return nil
+}
+
+// nodeEvict 驱逐 node: 吊销密钥及登记审批，释放分配到它的客户端，释放子网号(与 DB 一致，再次上线时优先沿用)，
+// 返回驱逐前的 node 及只有 uuid 的空 node；预留的需先删除预留
+func (mgr *nodeMgrT) nodeEvict(uuid string, now time.Time) (NodeT, NodeT, error) {
+	mgr.dataMtx.Lock()
+	node, err := mgr.nodeLookup(uuid)
+	if err != nil {
+		mgr.dataMtx.Unlock()
+		return NodeT{}, NodeT{}, err
+	}
+	if _, reserved := mgr.reserveUuidMap[uuid]; reserved {
+		mgr.dataMtx.Unlock()
+		return NodeT{}, NodeT{}, errors.New(fmt.Sprintf("0x1b7f30d6 uuid(%s) has reservation, delete it first", uuid))
+	}
+	before := mgr.nodeView(node)
+	// 先吊销，失败时不驱逐，避免被驱逐的 node 仍可签名上报
+	err = mgr.revokeNode(uuid)
+	if err != nil {
+		mgr.dataMtx.Unlock()
+		return NodeT{}, NodeT{}, err
+	}
+	err = mgr.store.ReleaseNetConfig(uuid)
+	if err != nil {
+		mgr.dataMtx.Unlock()
+		return NodeT{}, NodeT{}, err
+	}
+	err = mgr.store.DeleteNodeAttrByUuid(uuid)
+	if err != nil {
+		log.Printf("ERROR 0x6e40a9c3 delete attr of evicted uuid(%s) fail:%s", uuid, err)
+	}
+	delete(mgr.nodeUuidMap, uuid)
+	delete(mgr.nodeSubNetIdMap, node.SubId)
+	delete(mgr.abnormalMap, uuid)
+	delete(mgr.attrMap, uuid)
+	delete(mgr.pacPinMap, uuid)
+	mgr.unassignRepeater(uuid)
+	// DB 中的租约已标记回收，与重启后 NodeMgrInit 加载的结果一致
+	mgr.stickyMap[uuid] = node.SubId
+	mgr.refreshRepeaterVer(now)
+	mgr.dataMtx.Unlock()
+
+	mgr.recordEvent(&EventItemDBT{
+		Uuid:     uuid,
+		IP:       node.IP,
+		RoleType: node.RoleType,
+		TS:       now,
+		Ver:      node.Ver,
+		EType:    EVENT_NODE_EVICT,
+		EMsg:     fmt.Sprintf("evicted, release subId:%d", node.SubId),
+	})
+	return before, NodeT{Uuid: uuid}, nil
+}
+
+// nodeForceRole 强制角色并切换子网号，之后 boot 时按角色策略中 override 的顺序生效；role 为 0 时取消
+func (mgr *nodeMgrT) nodeForceRole(uuid string, role int, now time.Time) (NodeT, NodeT, error) {
+	if role != 0 && role != int(proto.Role_Pac) && role != int(proto.Role_Repeater) {
+		return NodeT{}, NodeT{}, errors.New(fmt.Sprintf("0x4a2d8f1e invalid role(%d)", role))
+	}
+
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	node, err := mgr.nodeLookup(uuid)
+	if err != nil {
+		return NodeT{}, NodeT{}, err
+	}
+	if _, reserved := mgr.reserveUuidMap[uuid]; reserved {
+		return NodeT{}, NodeT{}, errors.New(fmt.Sprintf("0x7c19e4b5 uuid(%s) role decided by reservation", uuid))
+	}
+	before := mgr.nodeView(node)
+	if role != 0 && role != node.RoleType {
+		old := *node
+		if !mgr.switchNodeSubNetIdRoleType(node.SubId, role, node) {
+			return NodeT{}, NodeT{}, errors.New(fmt.Sprintf("0x2f6b0a93 no free subId for role(%d), uuid:%s", role, uuid))
+		}
+		err = mgr.store.CommitNetConfig(node, false)
+		if err != nil {
+			mgr.rollbackNode(node, &old)
+			return NodeT{}, NodeT{}, err
+		}
+		mgr.publishNode(EVENT_NODE_ROLE, node, now)
+	}
+	if role != 0 {
+		node.RoleReason = fmt.Sprintf("%s: %s", ROLE_SOURCE_OVERRIDE, proto.Role(role))
+	}
+	err = mgr.setNodeAttr(uuid, now, func(attr *NodeAttrT) { attr.Role, attr.RoleReason = role, node.RoleReason })
+	if err != nil {
+		return NodeT{}, NodeT{}, err
+	}
+	mgr.refreshRepeaterVer(now)
+	return before, mgr.nodeView(node), nil
+}
+
+// nodeMove 换到指定的子网号，子网号需空闲，属于某个池时池的角色需一致
+func (mgr *nodeMgrT) nodeMove(uuid string, subId int, now time.Time) (NodeT, NodeT, error) {
+	if subId < SUBNET_ID_MIN || subId > SUBNET_ID_MAX {
+		return NodeT{}, NodeT{}, errors.New(fmt.Sprintf("0x0c5e7b28 invalid subId(%d)", subId))
+	}
+
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	node, err := mgr.nodeLookup(uuid)
+	if err != nil {
+		return NodeT{}, NodeT{}, err
+	}
+	if _, reserved := mgr.reserveUuidMap[uuid]; reserved {
+		return NodeT{}, NodeT{}, errors.New(fmt.Sprintf("0x5d3a81f4 uuid(%s) subId decided by reservation", uuid))
+	}
+	before := mgr.nodeView(node)
+	if subId == node.SubId {
+		return before, before, nil
+	}
+	if mgr.subIdBusy(subId) {
+		return NodeT{}, NodeT{}, errors.New(fmt.Sprintf("0x68e2c0b7 subId(%d) in use or reserved", subId))
+	}
+	if pool, ok := mgr.subnet.idPoolMp[subId]; ok && pool.role != node.RoleType {
+		return NodeT{}, NodeT{}, errors.New(fmt.Sprintf("0x3b90f5d2 subId(%d) in pool(%s) of role(%d)", subId, pool.name, pool.role))
+	}
+
+	old := *node
+	delete(mgr.nodeSubNetIdMap, node.SubId)
+	node.SubId = subId
+	node.Pool = mgr.subnet.poolOf(subId)
+	mgr.nodeSubNetIdMap[subId] = node
+	err = mgr.store.CommitNetConfig(node, false)
+	if err != nil {
+		mgr.rollbackNode(node, &old)
+		return NodeT{}, NodeT{}, err
+	}
+	mgr.publishNode(EVENT_NODE_SUBNET, node, now)
+	mgr.refreshRepeaterVer(now)
+	return before, mgr.nodeView(node), nil
+}
+
+// nodeEdit 修改版本号、备注，为 nil 的不修改；版本号在 node 下次 boot 时被上报值覆盖
+func (mgr *nodeMgrT) nodeEdit(uuid string, ver, note *string, now time.Time) (NodeT, NodeT, error) {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	node, err := mgr.nodeLookup(uuid)
+	if err != nil {
+		return NodeT{}, NodeT{}, err
+	}
+	before := mgr.nodeView(node)
+	if ver != nil && *ver != node.Ver {
+		old := *node
+		node.Ver = *ver
+		err = mgr.store.CommitNetConfig(node, false)
+		if err != nil {
+			mgr.rollbackNode(node, &old)
+			return NodeT{}, NodeT{}, err
+		}
+	}
+	if note != nil {
+		err = mgr.setNodeAttr(uuid, now, func(attr *NodeAttrT) { attr.Note = *note })
+		if err != nil {
+			return NodeT{}, NodeT{}, err
+		}
+	}
+	return before, mgr.nodeView(node), nil
+}
+
+// nodeMaintain 标记或取消维护
+func (mgr *nodeMgrT) nodeMaintain(uuid string, on bool, now time.Time) (NodeT, NodeT, error) {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	node, err := mgr.nodeLookup(uuid)
+	if err != nil {
+		return NodeT{}, NodeT{}, err
+	}
+	before := mgr.nodeView(node)
+	err = mgr.setNodeAttr(uuid, now, func(attr *NodeAttrT) { attr.Maintenance = on })
+	if err != nil {
+		return NodeT{}, NodeT{}, err
+	}
+	mgr.refreshRepeaterVer(now)
+	return before, mgr.nodeView(node), nil
+}
+
+// AdminNodeReqT 各操作用到的字段不同，见各接口
+type AdminNodeReqT struct {
+	Role        string    `json:"role,omitempty"`        // role: Pac 或 Repeater，为空时取消强制
+	SubId       int       `json:"subId,omitempty"`       // subnet
+	Ver         *string   `json:"ver,omitempty"`         // edit
+	Note        *string   `json:"note,omitempty"`        // edit
+	Maintenance bool      `json:"maintenance,omitempty"` // maintenance
+	Drain       bool      `json:"drain,omitempty"`       // drain
+	Deadline    DurationT `json:"deadline,omitempty"`    // drain: 排空时长，如 "30m"，为空时用 drain.deadline
+}
+
+// adminNodeAction 执行操作、记录审计，成功时返回操作后的 node
+func adminNodeAction(c *gin.Context, action string, fn func(uuid string, req *AdminNodeReqT, now time.Time) (NodeT, NodeT, error)) {
+	uuid := c.Param("uuid")
+	var req AdminNodeReqT
+	var err error
+	// body 可以为空
+	if c.Request.ContentLength != 0 {
+		err = c.ShouldBindJSON(&req)
+	}
+	if err != nil {
+		nodeMgr.audit(c, action, uuid, &AuditDetailT{}, err)
+		c.JSON(http.StatusBadRequest, &AdminRspT{Err: err.Error()})
+		return
+	}
+
+	before, after, err := fn(uuid, &req, time.Now())
+	detail := &AuditDetailT{Req: &req}
+	if err == nil {
+		detail.Before, detail.After = &before, &after
+	}
+	nodeMgr.audit(c, action, uuid, detail, err)
+	if errors.Is(err, errNodeNotFound) {
+		c.JSON(http.StatusNotFound, &AdminRspT{Err: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, &AdminRspT{Err: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, &after)
+}
+
+// AdminNodeEvict 驱逐 node 并释放子网号，返回只有 uuid 的空 node
+func AdminNodeEvict(c *gin.Context) {
+	adminNodeAction(c, ADMIN_ACTION_EVICT, func(uuid string, req *AdminNodeReqT, now time.Time) (NodeT, NodeT, error) {
+		return nodeMgr.nodeEvict(uuid, now)
+	})
+}
+
+// AdminNodeRole {"role": "Repeater"}
+func AdminNodeRole(c *gin.Context) {
+	adminNodeAction(c, ADMIN_ACTION_ROLE, func(uuid string, req *AdminNodeReqT, now time.Time) (NodeT, NodeT, error) {
+		role := 0
+		if len(req.Role) > 0 {
+			var err error
+			role, err = parseRole(req.Role)
+			if err != nil {
+				return NodeT{}, NodeT{}, err
+			}
+		}
+		return nodeMgr.nodeForceRole(uuid, role, now)
+	})
+}
+
+// AdminNodeSubnet {"subId": 130}
+func AdminNodeSubnet(c *gin.Context) {
+	adminNodeAction(c, ADMIN_ACTION_SUBNET, func(uuid string, req *AdminNodeReqT, now time.Time) (NodeT, NodeT, error) {
+		return nodeMgr.nodeMove(uuid, req.SubId, now)
+	})
+}
+
+// AdminNodeEdit {"ver": "1.2.0", "note": "..."}
+func AdminNodeEdit(c *gin.Context) {
+	adminNodeAction(c, ADMIN_ACTION_EDIT, func(uuid string, req *AdminNodeReqT, now time.Time) (NodeT, NodeT, error) {
+		return nodeMgr.nodeEdit(uuid, req.Ver, req.Note, now)
+	})
+}
+
+// AdminNodeMaintenance {"maintenance": true}
+func AdminNodeMaintenance(c *gin.Context) {
+	adminNodeAction(c, ADMIN_ACTION_MAINTENANCE, func(uuid string, req *AdminNodeReqT, now time.Time) (NodeT, NodeT, error) {
+		return nodeMgr.nodeMaintain(uuid, req.Maintenance, now)
+	})
+}
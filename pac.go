@@ -0,0 +1,346 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	pacLocal = "local"
+	pacOut   = "out"
+)
+
+// PacCfgT 按地址推断角色用的 CIDR 列表，修改后 SIGHUP、POST /v1/admin/pac/reload 或 watch 时重新加载
+type PacCfgT struct {
+	Local   string    `json:"local"`   // 国内地址列表(每行一个 CIDR)
+	Out     string    `json:"out"`     // 即使在 local 中也视为国外的地址列表
+	Watch   DurationT `json:"watch"`   // 检查文件(含 region 的列表)修改时间的周期，0 为不检查
+	Migrate bool      `json:"migrate"` // 重新加载后角色会变化的 node 在下次 boot 时切换，否则保持原角色直到 POST /v1/admin/pac/migrate
+}
+
+func (cfg *PacCfgT) check() error {
+	if len(cfg.Local) == 0 || len(cfg.Out) == 0 || cfg.Watch < 0 {
+		return errors.New(fmt.Sprintf("0x7e35a0c1 invalid pac local(%s), out(%s) or watch(%s)", cfg.Local, cfg.Out, time.Duration(cfg.Watch)))
+	}
+	return nil
+}
+
+// pacTableT 国内地址表，out 优先于 local
+type pacTableT struct {
+	local *regionTableT
+	out   *regionTableT
+}
+
+func newPacTable(cfg *PacCfgT) (*pacTableT, error) {
+	local, err := newRegionTable(&RegionCfgT{Lists: map[string]string{pacLocal: cfg.Local}})
+	if err != nil {
+		return nil, err
+	}
+	out, err := newRegionTable(&RegionCfgT{Lists: map[string]string{pacOut: cfg.Out}})
+	if err != nil {
+		return nil, err
+	}
+	return &pacTableT{local: local, out: out}, nil
+}
+
+func (t *pacTableT) isLocalIP(ip string) bool {
+	return t.out.lookup(ip) != pacOut && t.local.lookup(ip) == pacLocal
+}
+
+// pacAddr 按地址推断时实际使用的地址
+func pacAddr(ip, ipv4 string) string {
+	if isIPv6(ip) && len(ipv4) > 0 {
+		return ipv4
+	}
+	return ip
+}
+
+// isLocalAddr 判断是否为国内地址，cnIP.cfg 只有 ipv4 数据，
+// ipv6 接入的按已知的 ipv4 地址判断，都没有则视为国外地址
+func (t *pacTableT) isLocalAddr(ip, ipv4 string) bool {
+	if isIPv6(ip) == false {
+		return t.isLocalIP(ip)
+	}
+	if len(ipv4) > 0 {
+		return t.isLocalIP(ipv4)
+	}
+	return false
+}
+
+// pacPinT 重新加载后角色会变化、但未开启 migrate 的 node 保持原角色，地址变化后失效；保存在 node 属性中，重启后仍生效
+type pacPinT struct {
+	role int
+	ip   string
+}
+
+// PacChangeT 重新加载后角色会变化的 node
+type PacChangeT struct {
+	Uuid    string `json:"uuid"`
+	IP      string `json:"ip"`
+	Role    int    `json:"role"`
+	NewRole int    `json:"newRole"`
+	Reason  string `json:"reason"` // 新的角色的决定原因
+}
+
+// PacReportT 一次重新加载的结果
+type PacReportT struct {
+	TS      time.Time    `json:"ts"`
+	DryRun  bool         `json:"dryRun,omitempty"` // 只计算差异，未生效
+	Migrate bool         `json:"migrate"`          // changes 中的 node 在下次 boot 时切换角色，否则保持原角色
+	Local   int          `json:"local"`            // local 列表的 CIDR 数
+	Out     int          `json:"out"`
+	Changes []PacChangeT `json:"changes"`
+}
+
+// nodeAddr 决定角色时用的地址，有 ipv4 的按 ipv4
+func nodeAddr(node *NodeT) string {
+	if len(node.IP) > 0 {
+		return node.IP
+	}
+	return node.IPv6
+}
+
+// pacDiff 按当前的 mgr.pac 重新决定已登记 node 的角色，返回会变化的，调用方需持有 dataMtx
+func (mgr *nodeMgrT) pacDiff() []PacChangeT {
+	changeLst := make([]PacChangeT, 0)
+	for _, node := range mgr.nodeUuidMap {
+		if _, reserved := mgr.reserveUuidMap[node.Uuid]; reserved {
+			continue
+		}
+		ip := nodeAddr(node)
+		role, reason := mgr.decideRole(node.Uuid, ip, node.IP, node.reqRole)
+		if role != node.RoleType {
+			changeLst = append(changeLst, PacChangeT{Uuid: node.Uuid, IP: ip, Role: node.RoleType, NewRole: role, Reason: reason})
+		}
+	}
+	sort.Slice(changeLst, func(i, j int) bool { return changeLst[i].Uuid < changeLst[j].Uuid })
+	return changeLst
+}
+
+// pacReload 重新加载地址列表及 region 表，全部加载成功后在同一把锁内替换；dryRun 时只返回差异
+func (mgr *nodeMgrT) pacReload(dryRun bool, now time.Time) (*PacReportT, error) {
+	pac, err := newPacTable(&mgr.pacCfg)
+	if err != nil {
+		return nil, err
+	}
+	region, err := newRegionTable(&mgr.regionCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	oldPac, oldPin := mgr.pac, mgr.pacPinMap
+	mgr.pac, mgr.pacPinMap = pac, make(map[string]pacPinT)
+	report := &PacReportT{TS: now, DryRun: dryRun, Migrate: mgr.pacCfg.Migrate,
+		Local: len(pac.local.netMap), Out: len(pac.out.netMap), Changes: mgr.pacDiff()}
+	if dryRun {
+		mgr.pac, mgr.pacPinMap = oldPac, oldPin
+		return report, nil
+	}
+
+	if !mgr.pacCfg.Migrate {
+		for _, change := range report.Changes {
+			mgr.pacPinMap[change.Uuid] = pacPinT{role: change.Role, ip: change.IP}
+		}
+	}
+	for uuid := range oldPin {
+		if _, ok := mgr.pacPinMap[uuid]; !ok {
+			mgr.savePacPin(uuid, pacPinT{}, now)
+		}
+	}
+	for uuid, pin := range mgr.pacPinMap {
+		mgr.savePacPin(uuid, pin, now)
+	}
+	mgr.region = region
+	mgr.pacReport = report
+	mgr.refreshRepeaterVer(now)
+	log.Printf("LOG 0x2f90c6d4 pac reloaded, local:%d, out:%d, role changes:%d, migrate:%t",
+		report.Local, report.Out, len(report.Changes), report.Migrate)
+	return report, nil
+}
+
+// pacMigrate 取消保持，uuid 为空时取消所有，这些 node 在下次 boot 时切换角色，返回取消的个数
+func (mgr *nodeMgrT) pacMigrate(uuid string) int {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	now := time.Now()
+	if len(uuid) > 0 {
+		if _, ok := mgr.pacPinMap[uuid]; !ok {
+			return 0
+		}
+		delete(mgr.pacPinMap, uuid)
+		mgr.savePacPin(uuid, pacPinT{}, now)
+		return 1
+	}
+	cnt := len(mgr.pacPinMap)
+	for id := range mgr.pacPinMap {
+		mgr.savePacPin(id, pacPinT{}, now)
+	}
+	mgr.pacPinMap = make(map[string]pacPinT)
+	return cnt
+}
+
+// savePacPin 保存保持的角色，pin 为零值时清除；保存失败时仅本次运行内生效，调用方需持有 dataMtx
+func (mgr *nodeMgrT) savePacPin(uuid string, pin pacPinT, now time.Time) {
+	if attr, ok := mgr.attrMap[uuid]; (!ok && pin.role == 0) || (ok && attr.PacPinRole == pin.role && attr.PacPinIP == pin.ip) {
+		return
+	}
+	err := mgr.setNodeAttr(uuid, now, func(attr *NodeAttrT) {
+		attr.PacPinRole, attr.PacPinIP = pin.role, pin.ip
+	})
+	if err != nil {
+		log.Printf("ERROR 0x0a4f7c3e save pac pin of uuid(%s) fail:%s, kept until restart", uuid, err)
+	}
+}
+
+// saveBootRole 保存 boot 时请求的角色及决定角色的原因，重启后 pacDiff 仍按请求的角色计算；
+// 没有变化时不写，保存失败时仅本次运行内生效，调用方需持有 dataMtx
+func (mgr *nodeMgrT) saveBootRole(node *NodeT, now time.Time) {
+	if attr, ok := mgr.attrMap[node.Uuid]; ok && attr.ReqRole == node.reqRole && attr.RoleReason == node.RoleReason {
+		return
+	}
+	err := mgr.setNodeAttr(node.Uuid, now, func(attr *NodeAttrT) {
+		attr.ReqRole, attr.RoleReason = node.reqRole, node.RoleReason
+	})
+	if err != nil {
+		log.Printf("ERROR 0x6e3a0d57 save boot role of uuid(%s) fail:%s, kept until restart", node.Uuid, err)
+	}
+}
+
+// pacPinned 调用方需持有 dataMtx
+func (mgr *nodeMgrT) pacPinned(uuid, ip string) (int, string, bool) {
+	pin, ok := mgr.pacPinMap[uuid]
+	if !ok || pin.ip != ip {
+		return 0, "", false
+	}
+	return pin.role, fmt.Sprintf("%s: %s kept %s after pac reload", ROLE_SOURCE_IP, ip, proto.Role(pin.role)), true
+}
+
+// pacStamp 文件的修改时间及大小，有变化时重新加载
+func pacStamp(pathLst []string) string {
+	lst := make([]string, 0, len(pathLst))
+	for _, path := range pathLst {
+		st, err := os.Stat(path)
+		if err != nil {
+			lst = append(lst, path)
+			continue
+		}
+		lst = append(lst, fmt.Sprintf("%s|%d|%d", path, st.ModTime().UnixNano(), st.Size()))
+	}
+	return strings.Join(lst, ",")
+}
+
+func (mgr *nodeMgrT) pacFiles() []string {
+	lst := []string{mgr.pacCfg.Local, mgr.pacCfg.Out}
+	if len(mgr.regionCfg.Table) > 0 {
+		lst = append(lst, mgr.regionCfg.Table)
+	}
+	for _, path := range mgr.regionCfg.Lists {
+		lst = append(lst, path)
+	}
+	sort.Strings(lst)
+	return lst
+}
+
+// pacWatchT 重新加载 pac 的协程
+type pacWatchT struct {
+	sigCh  chan os.Signal
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// stop 停止监听，等正在进行的重新加载完成
+func (pw *pacWatchT) stop() {
+	signal.Stop(pw.sigCh)
+	close(pw.stopCh)
+	<-pw.doneCh
+}
+
+// PacWatchInit SIGHUP 或 watch 周期内文件有变化时重新加载，失败时保留原来的列表
+func PacWatchInit(cfg *PacCfgT) *pacWatchT {
+	pw := &pacWatchT{sigCh: make(chan os.Signal, 1), stopCh: make(chan struct{}), doneCh: make(chan struct{})}
+	signal.Notify(pw.sigCh, syscall.SIGHUP)
+	watch := time.Duration(cfg.Watch)
+
+	go func() {
+		defer close(pw.doneCh)
+
+		var tick <-chan time.Time
+		if watch > 0 {
+			ticker := time.NewTicker(watch)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		stamp := pacStamp(nodeMgr.pacFiles())
+		for {
+			select {
+			case <-pw.stopCh:
+				return
+			case <-pw.sigCh:
+				log.Printf("LOG 0x51c8e3a7 recv SIGHUP, reload pac")
+			case <-tick:
+				cur := pacStamp(nodeMgr.pacFiles())
+				if cur == stamp {
+					continue
+				}
+				log.Printf("LOG 0x0b6d4f92 pac file changed, reload pac")
+			}
+			stamp = pacStamp(nodeMgr.pacFiles())
+			_, err := nodeMgr.pacReload(false, time.Now())
+			if err != nil {
+				log.Printf("ERROR 0x64a2e0f5 reload pac fail:%s", err)
+			}
+		}
+	}()
+	return pw
+}
+
+// AdminPacReload 重新加载并返回差异，?dryRun=1 时只计算差异
+func AdminPacReload(c *gin.Context) {
+	dryRun := c.Query("dryRun") == "1" || c.Query("dryRun") == "true"
+	report, err := nodeMgr.pacReload(dryRun, time.Now())
+	nodeMgr.audit(c, "pacReload", "", &AuditDetailT{Req: c.Request.URL.RawQuery}, err)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &AdminRspT{Err: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// AdminPacDiff 最近一次生效的重新加载的结果
+func AdminPacDiff(c *gin.Context) {
+	nodeMgr.dataMtx.Lock()
+	report := nodeMgr.pacReport
+	nodeMgr.dataMtx.Unlock()
+	if report == nil {
+		c.JSON(http.StatusNotFound, &AdminRspT{Err: "0x3a7f1e28 pac not reloaded yet"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+type AdminPacMigrateRspT struct {
+	Migrated int `json:"migrated"`
+}
+
+// AdminPacMigrate ?uuid= 为空时所有保持原角色的 node 在下次 boot 时切换角色
+func AdminPacMigrate(c *gin.Context) {
+	uuid := c.Query("uuid")
+	cnt := nodeMgr.pacMigrate(uuid)
+	nodeMgr.audit(c, "pacMigrate", uuid, &AuditDetailT{Req: cnt}, nil)
+	c.JSON(http.StatusOK, &AdminPacMigrateRspT{Migrated: cnt})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPacReload(t *testing.T) {
+	dir := t.TempDir()
+	local := filepath.Join(dir, "cnIP.cfg")
+	out := filepath.Join(dir, "outIP.cfg")
+	os.WriteFile(local, []byte("1.2.0.0/16\n5.6.0.0/16\n"), 0644)
+	os.WriteFile(out, []byte("1.2.9.0/24\n"), 0644)
+
+	cfg := configDefault()
+	cfg.Pac.Local = local
+	cfg.Pac.Out = out
+	store := NewMemStore()
+	NodeMgrInit(store, cfg)
+	t.Cleanup(nodeMgr.stopReaper)
+	for ip, role := range map[string]int{"1.2.3.4": 1, "1.2.9.9": 1000, "5.6.7.8": 1} {
+		if n, _, _ := nodeMgr.bootNode("uuid-"+ip, ip, "v1", 0); n.RoleType != role {
+			t.Fatalf("0x1e6c0a47 boot %s role:%d", ip, n.RoleType)
+		}
+	}
+
+	// 5.6.0.0/16 移出国内列表，1.2.9.0/24 不再视为国外
+	os.WriteFile(local, []byte("1.2.0.0/16\n"), 0644)
+	os.WriteFile(out, []byte("# none\n"), 0644)
+	report, err := nodeMgr.pacReload(true, time.Now())
+	if err != nil || len(report.Changes) != 2 || report.Changes[0].Uuid != "uuid-1.2.9.9" || report.Changes[0].NewRole != 1 ||
+		report.Changes[1].Uuid != "uuid-5.6.7.8" || report.Changes[1].NewRole != 1000 {
+		t.Fatalf("0x5b27d9e3 dry run report:%+v, err:%v", report, err)
+	}
+	if n, _, _ := nodeMgr.bootNode("uuid-5.6.7.8", "5.6.7.8", "v1", 0); n.RoleType != 1 {
+		t.Fatalf("0x38a4f1c6 dry run applied")
+	}
+
+	// 未开启 migrate 时保持原角色，地址变化或 migrate 后切换
+	report, err = nodeMgr.pacReload(false, time.Now())
+	if err != nil || len(report.Changes) != 2 {
+		t.Fatalf("0x0f9e3b52 reload report:%+v, err:%v", report, err)
+	}
+	if n, _, _ := nodeMgr.bootNode("uuid-5.6.7.8", "5.6.7.8", "v1", 0); n.RoleType != 1 {
+		t.Fatalf("0x6d1c8e05 pinned role lost:%+v", n)
+	}
+	// 保持的角色重启后仍生效
+	NodeMgrInit(store, cfg)
+	t.Cleanup(nodeMgr.stopReaper)
+	if n, _, _ := nodeMgr.bootNode("uuid-5.6.7.8", "5.6.7.8", "v1", 0); n.RoleType != 1 || len(nodeMgr.pacPinMap) != 2 {
+		t.Fatalf("0x51e8c0a6 pinned role lost after restart:%+v", n)
+	}
+	if n, _, _ := nodeMgr.bootNode("uuid-1.2.9.9", "1.2.9.8", "v1", 0); n.RoleType != 1 {
+		t.Fatalf("0x27b5a0e9 pin kept after ip changed:%+v", n)
+	}
+	if cnt := nodeMgr.pacMigrate("uuid-5.6.7.8"); cnt != 1 {
+		t.Fatalf("0x4a8f26d3 migrate:%d", cnt)
+	}
+	if n, _, _ := nodeMgr.bootNode("uuid-5.6.7.8", "5.6.7.8", "v1", 0); n.RoleType != 1000 {
+		t.Fatalf("0x7c03e1b8 migrated role:%+v", n)
+	}
+	attrLst, _ := store.LoadNodeAttrAll()
+	for _, attr := range attrLst {
+		// 没有保持过角色的 node 也有属性(boot 时的角色及原因)
+		if attr.Uuid != "uuid-1.2.3.4" && (attr.Uuid == "uuid-5.6.7.8") != (attr.PacPinRole == 0) {
+			t.Fatalf("0x3e97b4d2 pin attr after migrate:%+v", attr)
+		}
+	}
+
+	// 开启 migrate 时下次 boot 直接切换
+	nodeMgr.pacCfg.Migrate = true
+	os.WriteFile(local, []byte("5.6.0.0/16\n"), 0644)
+	if report, err = nodeMgr.pacReload(false, time.Now()); err != nil || len(report.Changes) != 3 {
+		t.Fatalf("0x13d6b9f0 migrate reload report:%+v, err:%v", report, err)
+	}
+	if n, _, _ := nodeMgr.bootNode("uuid-1.2.3.4", "1.2.3.4", "v1", 0); n.RoleType != 1000 {
+		t.Fatalf("0x65e0a7c2 role not migrated:%+v", n)
+	}
+
+	// 加载失败时保留原来的列表
+	os.Remove(out)
+	if _, err = nodeMgr.pacReload(false, time.Now()); err == nil || nodeMgr.pacReport != report {
+		t.Fatalf("0x2f48c91d reload missing file err:%v", err)
+	}
+}
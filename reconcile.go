@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// reconcileNetConfig 校验启动时加载的网络参数，并与租约、静态预留比对
+// repair 为 false 时遇到冲突直接返回错误；为 true 时修复 DB 并返回修复后的数据:
+//  1. 子网号越界: 删除该行
+//  2. uuid 或子网号重复: 保留 ts 最新的一行
+//  3. 子网号已预留给其他 uuid: 删除该行，node 再次上线时重新分配
+//  4. 租约缺失或与网络参数不一致: 按网络参数重写租约(过期时间沿用租约，缺失时按 ts 推算)
+func (mgr *nodeMgrT) reconcileNetConfig(allNode []*NetConfigT, leaseMap map[string]*LeaseT, repair bool) ([]*NetConfigT, error) {
+	// ts 新的在前，重复时保留第一个
+	sort.SliceStable(allNode, func(i, j int) bool {
+		return allNode[i].TS.After(allNode[j].TS)
+	})
+
+	keepLst := make([]*NetConfigT, 0, len(allNode))
+	keepUuidMap := make(map[string]*NetConfigT)
+	keepSubIdMap := make(map[int]*NetConfigT)
+	dropUuidMap := make(map[string]bool)
+	for _, row := range allNode {
+		var reason string
+		if row.SubId < SUBNET_ID_MIN || row.SubId > SUBNET_ID_MAX {
+			reason = "invalid subId"
+		} else if _, exist := keepUuidMap[row.Uuid]; exist {
+			reason = "duplicate uuid"
+		} else if other, exist := keepSubIdMap[row.SubId]; exist {
+			reason = fmt.Sprintf("subId held by uuid:%s", other.Uuid)
+		} else if res, exist := mgr.reserveSubIdMap[row.SubId]; exist && res.Uuid != row.Uuid {
+			reason = fmt.Sprintf("subId reserved for uuid:%s", res.Uuid)
+		}
+
+		if len(reason) > 0 {
+			if !repair {
+				return nil, errors.New(fmt.Sprintf("0x5b6cd7cc %s, uuid: %s, subNetId:%d", reason, row.Uuid, row.SubId))
+			}
+			log.Printf("WARN 0x2a9e4d71 reconcile drop row(%s), uuid:%s, subNetId:%d, ts:%s", reason, row.Uuid, row.SubId, row.TS)
+			dropUuidMap[row.Uuid] = true
+			continue
+		}
+
+		keepLst = append(keepLst, row)
+		keepUuidMap[row.Uuid] = row
+		keepSubIdMap[row.SubId] = row
+	}
+	if !repair {
+		return keepLst, nil
+	}
+
+	// 删除该 uuid 的所有行后重新写入保留的一行，删除、写入及租约在同一事务中提交
+	for uuid := range dropUuidMap {
+		row, exist := keepUuidMap[uuid]
+		if !exist {
+			err := mgr.store.ReplaceNetConfig(uuid, nil)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		node := mgr.nodeOfNetConfig(row, leaseMap[uuid])
+		err := mgr.store.ReplaceNetConfig(uuid, node)
+		if err != nil {
+			return nil, err
+		}
+		leaseMap[uuid] = node.lease()
+	}
+
+	for _, row := range keepLst {
+		if dropUuidMap[row.Uuid] {
+			continue
+		}
+		lease, exist := leaseMap[row.Uuid]
+		if exist && lease.SubId == row.SubId && lease.RoleType == row.RoleType {
+			continue
+		}
+		log.Printf("WARN 0x7f13c0e8 reconcile lease(exist:%v) of uuid:%s, subNetId:%d", exist, row.Uuid, row.SubId)
+		node := mgr.nodeOfNetConfig(row, lease)
+		err := mgr.store.UpsertLease(node.lease())
+		if err != nil {
+			return nil, err
+		}
+		leaseMap[row.Uuid] = node.lease()
+	}
+
+	return keepLst, nil
+}
+
+// nodeOfNetConfig 由 DB 中的网络参数和租约构造 node，租约为 nil 时按最后一次 ping 推算
+func (mgr *nodeMgrT) nodeOfNetConfig(row *NetConfigT, lease *LeaseT) *NodeT {
+	var n NodeT
+	n.Uuid = row.Uuid
+	n.IP = row.IP
+	n.IPv6 = row.IPv6
+	n.SubId = row.SubId
+	n.Pool = mgr.subnet.poolOf(row.SubId)
+	_, n.Reserved = mgr.reserveUuidMap[row.Uuid]
+	n.RoleType = row.RoleType
+	n.Ping = row.TS
+	n.Ver = row.Ver
+	if lease != nil {
+		n.LeaseExpire = lease.Expire
+		n.closed = lease.Closed
+	} else {
+		// 旧数据没有租约记录，按最后一次 ping 推算
+		n.LeaseExpire = n.Ping.Add(mgr.leaseDur)
+	}
+	return &n
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// RegionCfgT 按 CIDR 给 node 打 region 标签，挑选 repeater 时优先客户端所在 region 对应的 region
+type RegionCfgT struct {
+	Table    string              `json:"table"`    // CIDR 到 region 的对照表，每行 "CIDR region"，# 开头为注释
+	Lists    map[string]string   `json:"lists"`    // region->CIDR 列表文件(每行一个 CIDR)，如 cn:./etc/cnIP.cfg
+	Default  string              `json:"default"`  // 不在表中的地址所属的 region
+	Prefer   map[string][]string `json:"prefer"`   // 客户端 region->repeater region 的优先顺序
+	Fallback []string            `json:"fallback"` // 客户端 region 不在 prefer 中时的优先顺序
+}
+
+// regionTableT CIDR->region，最长前缀匹配，同一 CIDR 以 table 中的为准
+type regionTableT struct {
+	netMap   map[string]string // 网络地址/前缀长度->region
+	ones4    []int             // 出现过的 ipv4 前缀长度(降序)
+	ones6    []int             // 出现过的 ipv6 前缀长度(降序)
+	def      string
+	prefer   map[string][]string
+	fallback []string
+}
+
+// newRegionTable 没有配置 table、lists、default 时返回 nil，不区分 region
+func newRegionTable(cfg *RegionCfgT) (*regionTableT, error) {
+	if len(cfg.Table) == 0 && len(cfg.Lists) == 0 && len(cfg.Default) == 0 {
+		return nil, nil
+	}
+
+	t := &regionTableT{netMap: make(map[string]string), def: cfg.Default, prefer: cfg.Prefer, fallback: cfg.Fallback}
+	// 按 region 排序加载，重复的 CIDR 结果稳定
+	regionLst := make([]string, 0, len(cfg.Lists))
+	for region := range cfg.Lists {
+		regionLst = append(regionLst, region)
+	}
+	sort.Strings(regionLst)
+	for _, region := range regionLst {
+		err := t.load(cfg.Lists[region], region)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.Table) > 0 {
+		err := t.load(cfg.Table, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	t.ones4 = onesUniq(t.ones4)
+	t.ones6 = onesUniq(t.ones6)
+	return t, nil
+}
+
+// onesUniq 去重并降序，查找时先匹配最长的前缀
+func onesUniq(onesLst []int) []int {
+	sort.Sort(sort.Reverse(sort.IntSlice(onesLst)))
+	lst := make([]int, 0, len(onesLst))
+	for _, ones := range onesLst {
+		if len(lst) == 0 || lst[len(lst)-1] != ones {
+			lst = append(lst, ones)
+		}
+	}
+	return lst
+}
+
+// load region 为空时每行为 "CIDR region"，否则每行一个 CIDR
+func (t *regionTableT) load(path, region string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x39c4e0b2 open region file(%s) fail:%s", path, err))
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		r := region
+		if len(r) == 0 {
+			if len(fields) < 2 {
+				return errors.New(fmt.Sprintf("0x5d02a7f1 region file(%s) line %d: missing region", path, line))
+			}
+			r = fields[1]
+		}
+		err = t.add(fields[0], r)
+		if err != nil {
+			return errors.New(fmt.Sprintf("0x1b8f63ce region file(%s) line %d: %s", path, line, err))
+		}
+	}
+	return scanner.Err()
+}
+
+func (t *regionTableT) add(cidr, region string) error {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+	ones, bits := ipNet.Mask.Size()
+	key := fmt.Sprintf("%s/%d", ipNet.IP, ones)
+	if _, exist := t.netMap[key]; !exist {
+		if bits == 32 {
+			t.ones4 = append(t.ones4, ones)
+		} else {
+			t.ones6 = append(t.ones6, ones)
+		}
+	}
+	t.netMap[key] = region
+	return nil
+}
+
+// lookup 地址所属的 region，无法解析或不在表中的为 default
+func (t *regionTableT) lookup(ip string) string {
+	if t == nil {
+		return ""
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return t.def
+	}
+	onesLst, bits := t.ones6, 128
+	if addr4 := addr.To4(); addr4 != nil {
+		addr, onesLst, bits = addr4, t.ones4, 32
+	}
+	for _, ones := range onesLst {
+		key := fmt.Sprintf("%s/%d", addr.Mask(net.CIDRMask(ones, bits)), ones)
+		if region, ok := t.netMap[key]; ok {
+			return region
+		}
+	}
+	return t.def
+}
+
+// nodeRegion ipv4 优先，其次 ipv6
+func (t *regionTableT) nodeRegion(node *NodeT) string {
+	if len(node.IP) > 0 {
+		return t.lookup(node.IP)
+	}
+	return t.lookup(node.IPv6)
+}
+
+// rank repeater region 对客户端 region 的优先级，越小越优先，不在优先顺序中的排最后
+func (t *regionTableT) rank(client, repeater string) int {
+	if t == nil {
+		return 0
+	}
+	order, ok := t.prefer[client]
+	if !ok {
+		order = t.fallback
+	}
+	for i, region := range order {
+		if region == repeater {
+			return i
+		}
+	}
+	return len(order)
+}
+
+// preferRegion 按客户端地址计算各候选的 region 优先级，返回客户端的 region，调用方需持有 dataMtx
+func (mgr *nodeMgrT) preferRegion(clientIP string, candLst []*repeaterCandT) string {
+	client := mgr.region.lookup(clientIP)
+	for _, cand := range candLst {
+		cand.prefer = mgr.region.rank(client, cand.node.Region)
+	}
+	return client
+}
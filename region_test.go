@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegionTable(t *testing.T) {
+	dir := t.TempDir()
+	table := filepath.Join(dir, "region.cfg")
+	os.WriteFile(table, []byte("# cidr region\n1.1.0.0/16 hk\n1.1.8.0/24 sg\n2001:db8::/32 jp\n"), 0644)
+	tbl, err := newRegionTable(&RegionCfgT{
+		Table:   table,
+		Lists:   map[string]string{"cn": "./etc/cnIP.cfg"},
+		Default: "other",
+	})
+	if err != nil {
+		t.Fatalf("0x2e95a7c0 load region table:%s", err)
+	}
+	for _, c := range []struct {
+		ip     string
+		region string
+	}{
+		{"1.1.8.8", "sg"}, // table 覆盖 cnIP.cfg 中同一 CIDR
+		{"1.1.9.1", "hk"},
+		{"1.2.4.8", "cn"},
+		{"2001:db8::1", "jp"},
+		{"8.8.8.8", "other"},
+		{"bad", "other"},
+	} {
+		if region := tbl.lookup(c.ip); region != c.region {
+			t.Fatalf("0x4c1d06b8 %s region:%s, expect:%s", c.ip, region, c.region)
+		}
+	}
+
+	os.WriteFile(table, []byte("1.1.0.0/16\n"), 0644)
+	if _, err = newRegionTable(&RegionCfgT{Table: table}); err == nil {
+		t.Fatalf("0x7a3f52e9 missing region accepted")
+	}
+}
+
+func TestAssignRepeaterRegion(t *testing.T) {
+	dir := t.TempDir()
+	table := filepath.Join(dir, "region.cfg")
+	os.WriteFile(table, []byte("1.1.1.0/24 hk\n2.2.2.0/24 sg\n3.3.3.0/24 us\n9.9.9.0/24 cn\n"), 0644)
+
+	for _, sel := range []string{REPEATER_SELECT_HASH, REPEATER_SELECT_LOAD} {
+		cfg := configDefault()
+		cfg.Repeater.Select = sel
+		cfg.Repeater.Count = 2
+		cfg.Region = RegionCfgT{
+			Table:    table,
+			Prefer:   map[string][]string{"cn": {"hk", "sg"}},
+			Fallback: []string{"us"},
+		}
+		NodeMgrInit(NewMemStore(), cfg)
+		t.Cleanup(nodeMgr.stopReaper)
+		mgr := nodeMgr
+		for i, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3", "1.1.1.2"} {
+			uuid := fmt.Sprintf("uuid-%d", i)
+			if err := mgr.reservationSet(&ReservationT{Uuid: uuid, SubId: 150 + i, RoleType: 1000}); err != nil {
+				t.Fatalf("0x21ca16db reserve fail:%v", err)
+			}
+			if _, _, err := mgr.bootNode(uuid, ip, "v1", 0); err != nil {
+				t.Fatalf("0x8d463859 boot fail:%v", err)
+			}
+		}
+
+		now := time.Now()
+		for i := 0; i < 10; i++ {
+			lst := mgr.assignRepeater(fmt.Sprintf("client-%d", i), "9.9.9.9", now)
+			if len(lst) != 2 || lst[0].Region != "hk" || lst[1].Region != "hk" {
+				t.Fatalf("0x1f7b83d5 %s cn client pick:%+v", sel, lst)
+			}
+			lst = mgr.assignRepeater(fmt.Sprintf("client-%d", i), "8.8.8.8", now)
+			if len(lst) != 2 || lst[0].Region != "us" {
+				t.Fatalf("0x5c0e9a12 %s fallback pick:%+v", sel, lst)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RepeaterCfgT repeater 列表的健康过滤
+type RepeaterCfgT struct {
+	AbnormalWindow DurationT `json:"abnormalWindow"` // 统计异常事件的时间窗口
+	AbnormalMax    int       `json:"abnormalMax"`    // 窗口内异常事件达到该数量视为不健康
+	MinCount       int       `json:"minCount"`       // 健康的不足该数量时，按排序补充不健康的，保证列表不为空
+
+	Select    string                      `json:"select"`    // 挑选方式: hash(默认)、load
+	Count     int                         `json:"count"`     // 每个客户端返回的 repeater 数，0:全部
+	Weight    int                         `json:"weight"`    // 默认权重
+	Capacity  int                         `json:"capacity"`  // 默认可服务的客户端数上限，0:不限
+	AssignTTL DurationT                   `json:"assignTtl"` // 客户端超过该时长未再请求则释放其分配
+	Nodes     map[string]RepeaterNodeCfgT `json:"nodes"`     // uuid->权重和容量
+
+	LongPollMax DurationT `json:"longPollMax"` // long-poll(?wait=) 最长的等待时长
+}
+
+func (cfg *RepeaterCfgT) check() error {
+	if cfg.AbnormalWindow <= 0 || cfg.AbnormalMax <= 0 || cfg.MinCount < 1 {
+		return errors.New(fmt.Sprintf("0x61d3a0f7 invalid repeater abnormalWindow(%s), abnormalMax(%d), minCount(%d)",
+			time.Duration(cfg.AbnormalWindow), cfg.AbnormalMax, cfg.MinCount))
+	}
+	if cfg.Count < 0 || cfg.Weight < 1 || cfg.Capacity < 0 || cfg.AssignTTL <= 0 {
+		return errors.New(fmt.Sprintf("0x3f9c0a2d invalid repeater count(%d), weight(%d), capacity(%d), assignTtl(%s)",
+			cfg.Count, cfg.Weight, cfg.Capacity, time.Duration(cfg.AssignTTL)))
+	}
+	if cfg.LongPollMax <= 0 {
+		return errors.New(fmt.Sprintf("0x2c58d1e6 invalid repeater longPollMax(%s)", time.Duration(cfg.LongPollMax)))
+	}
+	if cfg.Select != REPEATER_SELECT_HASH && cfg.Select != REPEATER_SELECT_LOAD {
+		return errors.New(fmt.Sprintf("0x4a70e5d3 invalid repeater select(%s)", cfg.Select))
+	}
+	for uuid, node := range cfg.Nodes {
+		if node.Weight < 0 || node.Capacity < 0 {
+			return errors.New(fmt.Sprintf("0x12e7b6c4 invalid repeater(%s) weight(%d) or capacity(%d)", uuid, node.Weight, node.Capacity))
+		}
+	}
+	return nil
+}
+
+// 存活状态的排序，越小越优先
+var nodeStateRank = map[string]int{
+	NODE_STATE_ONLINE:  0,
+	NODE_STATE_SUSPECT: 1,
+	NODE_STATE_OFFLINE: 2,
+	NODE_STATE_EXPIRED: 3,
+}
+
+// repeaterCandT 参与排序的 repeater
+type repeaterCandT struct {
+	node     NodeT
+	abnormal int // 窗口内与该 repeater 相关的异常事件数
+	healthy  bool
+	prefer   int // region 对请求客户端的优先级，越小越优先
+}
+
+// recordAbnormal 记录与 repeater 相关的异常事件：由该 repeater 上报，或消息中带有该 repeater 的地址
+// 返回涉及的 repeater，作为告警的统计对象；调用方需持有 dataMtx
+func (mgr *nodeMgrT) recordAbnormal(uuid, msg string, now time.Time) []alertSubjectT {
+	subjectLst := make([]alertSubjectT, 0)
+	for _, node := range mgr.nodeUuidMap {
+		if node.RoleType != int(proto.Role_Repeater) {
+			continue
+		}
+		if node.Uuid != uuid && !msgHasAddr(msg, node.IP) && !msgHasAddr(msg, node.IPv6) {
+			continue
+		}
+		mgr.abnormalMap[node.Uuid] = append(mgr.pruneAbnormal(node.Uuid, now), now)
+		subjectLst = append(subjectLst, alertSubjectT{uuid: node.Uuid, roleType: node.RoleType})
+	}
+	return subjectLst
+}
+
+// msgHasAddr 消息中是否有完整的 addr(避免 1.1.1.1 匹配到 11.1.1.10)
+func msgHasAddr(msg, addr string) bool {
+	if len(addr) == 0 {
+		return false
+	}
+	fields := strings.FieldsFunc(msg, func(r rune) bool {
+		return !(r == '.' || r == ':' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F'))
+	})
+	for _, field := range fields {
+		if strings.EqualFold(field, addr) || strings.EqualFold(strings.TrimRight(field, ".:"), addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneAbnormal 丢弃窗口外的记录，调用方需持有 dataMtx
+func (mgr *nodeMgrT) pruneAbnormal(uuid string, now time.Time) []time.Time {
+	tsLst := mgr.abnormalMap[uuid]
+	since := now.Add(-time.Duration(mgr.repeater.AbnormalWindow))
+	i := 0
+	for i < len(tsLst) && tsLst[i].Before(since) {
+		i++
+	}
+	tsLst = tsLst[i:]
+	if len(tsLst) == 0 {
+		delete(mgr.abnormalMap, uuid)
+		return nil
+	}
+	mgr.abnormalMap[uuid] = tsLst
+	return tsLst
+}
+
+func (mgr *nodeMgrT) repeaterList(now time.Time) []NodeT {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	candLst := mgr.repeaterCand(now)
+	lst := make([]NodeT, 0, len(candLst))
+	for _, cand := range candLst {
+		lst = append(lst, cand.node)
+	}
+	return lst
+}
+
+// repeaterCand 按健康程度排序的 repeater(租约有效且不在维护、排空中的)，不健康的只在健康的不足 minCount 时补充
+// 排序: 健康的在前，仅有 ipv6 的在后，存活状态、异常事件数、最近 ping 时间；调用方需持有 dataMtx
+func (mgr *nodeMgrT) repeaterCand(now time.Time) []*repeaterCandT {
+	candLst := make([]*repeaterCandT, 0)
+	for _, node := range mgr.nodeUuidMap {
+		if node.RoleType != int(proto.Role_Repeater) || !node.leaseValid(now) {
+			continue
+		}
+		if attr, ok := mgr.attrMap[node.Uuid]; ok && attr.Maintenance {
+			continue
+		}
+		if mgr.draining(node.Uuid, now) {
+			continue
+		}
+		cand := &repeaterCandT{node: *node, abnormal: len(mgr.pruneAbnormal(node.Uuid, now))}
+		cand.node.Region = mgr.region.nodeRegion(node)
+		cand.healthy = node.State == NODE_STATE_ONLINE && cand.abnormal < mgr.repeater.AbnormalMax
+		candLst = append(candLst, cand)
+	}
+	minCount := mgr.repeater.MinCount
+
+	sort.SliceStable(candLst, func(i, j int) bool {
+		a, b := candLst[i], candLst[j]
+		if a.healthy != b.healthy {
+			return a.healthy
+		}
+		if (len(a.node.IP) > 0) != (len(b.node.IP) > 0) {
+			return len(a.node.IP) > 0
+		}
+		if nodeStateRank[a.node.State] != nodeStateRank[b.node.State] {
+			return nodeStateRank[a.node.State] < nodeStateRank[b.node.State]
+		}
+		if a.abnormal != b.abnormal {
+			return a.abnormal < b.abnormal
+		}
+		return a.node.Ping.After(b.node.Ping)
+	})
+
+	for i, cand := range candLst {
+		if !cand.healthy && i >= minCount {
+			return candLst[:i]
+		}
+	}
+	return candLst
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRepeaterList(t *testing.T) {
+	NodeMgrInit(NewMemStore(), configDefault())
+	t.Cleanup(nodeMgr.stopReaper)
+	mgr := nodeMgr
+	for i, uuid := range []string{"uuid-a", "uuid-b", "uuid-c"} {
+		if err := mgr.reservationSet(&ReservationT{Uuid: uuid, SubId: 150 + i, RoleType: 1000}); err != nil {
+			t.Fatalf("0x9fb47c7b reserve fail:%v", err)
+		}
+		_, _, err := mgr.bootNode(uuid, fmt.Sprintf("1.1.1.%d", i+1), "v1", 0)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	// uuid-b 被多次上报 ping 丢包，uuid-c 漏了 keepalive
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		mgr.recordAbnormal("uuid-client", "ping 1.1.1.2: lost 30%", now)
+	}
+	mgr.recordAbnormal("uuid-client", "ping 11.1.1.1 lost", now)
+	mgr.nodeUuidMap["uuid-c"].Ping = now.Add(-time.Minute * 5)
+	mgr.sweepNodeState(now)
+
+	lst := mgr.repeaterList(now)
+	if len(lst) != 1 || lst[0].Uuid != "uuid-a" {
+		t.Fatalf("0x3c07e9b2 healthy list error:%v", lst)
+	}
+
+	// 健康的不足 minCount 时按存活状态补充
+	mgr.repeater.MinCount = 3
+	lst = mgr.repeaterList(now)
+	if len(lst) != 3 || lst[1].Uuid != "uuid-b" || lst[2].Uuid != "uuid-c" {
+		t.Fatalf("0x51a8d4f6 fallback list error:%v", lst)
+	}
+
+	// 窗口外的异常事件不再计入
+	lst = mgr.repeaterList(now.Add(time.Duration(mgr.repeater.AbnormalWindow) + time.Second))
+	if lst[0].Uuid == "uuid-c" || lst[1].Uuid == "uuid-c" {
+		t.Fatalf("0x27ef0b93 abnormal not expired:%v", lst)
+	}
+}
+
+func TestMsgHasAddr(t *testing.T) {
+	if !msgHasAddr("ping 1.1.1.1: lost", "1.1.1.1") || msgHasAddr("ping 11.1.1.10", "1.1.1.1") ||
+		!msgHasAddr("[2001:db8::1] ack null", "2001:db8::1") || msgHasAddr("", "") {
+		t.Fatalf("0x6e4b1a05 msgHasAddr error")
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// repeaterVerT repeater 集合的版本号，集合(增删、地址、region、健康、权重和容量)变化时递增
+// 以启动时的毫秒时间戳为初值，重启后仍单调递增
+type repeaterVerT struct {
+	ver uint64
+	sum uint64        // 集合的 hash
+	ch  chan struct{} // 版本变化时 close 并换新，唤醒 long-poll
+}
+
+func newRepeaterVer(now time.Time) repeaterVerT {
+	return repeaterVerT{ver: uint64(now.UnixMilli()), ch: make(chan struct{})}
+}
+
+// repeaterSum 集合的 hash，与排序无关，调用方需持有 dataMtx
+func (mgr *nodeMgrT) repeaterSum(candLst []*repeaterCandT) uint64 {
+	lst := make([]string, 0, len(candLst))
+	for _, cand := range candLst {
+		weight, capacity := mgr.repeaterWeight(&cand.node)
+		lst = append(lst, fmt.Sprintf("%s|%s|%s|%s|%t|%d|%d",
+			cand.node.Uuid, cand.node.IP, cand.node.IPv6, cand.node.Region, cand.healthy, weight, capacity))
+	}
+	sort.Strings(lst)
+
+	h := fnv.New64a()
+	for _, s := range lst {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// checkRepeaterVer 集合有变化时递增版本号并唤醒等待者，返回当前版本号，调用方需持有 dataMtx
+func (mgr *nodeMgrT) checkRepeaterVer(candLst []*repeaterCandT) uint64 {
+	sum := mgr.repeaterSum(candLst)
+	if sum != mgr.repeaterVer.sum {
+		mgr.repeaterVer.sum = sum
+		mgr.repeaterVer.ver++
+		close(mgr.repeaterVer.ch)
+		mgr.repeaterVer.ch = make(chan struct{})
+	}
+	return mgr.repeaterVer.ver
+}
+
+// refreshRepeaterVer 状态、地址等变化后重新计算，调用方需持有 dataMtx
+func (mgr *nodeMgrT) refreshRepeaterVer(now time.Time) uint64 {
+	return mgr.checkRepeaterVer(mgr.repeaterCand(now))
+}
+
+func (mgr *nodeMgrT) repeaterVersion(now time.Time) uint64 {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	return mgr.refreshRepeaterVer(now)
+}
+
+// waitRepeaterVer 等到版本号不再是 ver、超时或请求结束，返回当前版本号
+func (mgr *nodeMgrT) waitRepeaterVer(ctx context.Context, ver uint64, timeout time.Duration) uint64 {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		mgr.dataMtx.Lock()
+		cur, ch := mgr.repeaterVer.ver, mgr.repeaterVer.ch
+		mgr.dataMtx.Unlock()
+		if cur != ver {
+			return cur
+		}
+
+		select {
+		case <-ch:
+		case <-timer.C:
+			return cur
+		case <-ctx.Done():
+			return cur
+		}
+	}
+}
+
+// touchAssign 客户端的列表未变化(304)时也续期其分配
+func (mgr *nodeMgrT) touchAssign(client string, now time.Time) {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	if assign, ok := mgr.assignMap[client]; ok {
+		assign.ts = now
+	}
+}
+
+func repeaterETag(ver uint64) string {
+	return fmt.Sprintf(`"%d"`, ver)
+}
+
+// etagMatch If-None-Match 中是否有该版本，支持多个值、弱校验 W/ 和 *
+func etagMatch(ifNoneMatch string, ver uint64) bool {
+	etag := repeaterETag(ver)
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLongPollWait ?wait=30s 或 ?wait=30(秒)，不超过 max，未指定或无效时为 0(不等待)
+func parseLongPollWait(s string, max time.Duration) time.Duration {
+	if len(s) == 0 {
+		return 0
+	}
+	wait, err := time.ParseDuration(s)
+	if err != nil {
+		sec, err := strconv.Atoi(s)
+		if err != nil {
+			return 0
+		}
+		wait = time.Duration(sec) * time.Second
+	}
+	if wait < 0 {
+		return 0
+	}
+	if wait > max {
+		return max
+	}
+	return wait
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	pb "google.golang.org/protobuf/proto"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRepeaterVer(t *testing.T) {
+	NodeMgrInit(NewMemStore(), configDefault())
+	t.Cleanup(nodeMgr.stopReaper)
+	mgr := nodeMgr
+	if err := mgr.reservationSet(&ReservationT{Uuid: "uuid-a", SubId: 150, RoleType: 1000}); err != nil {
+		t.Fatalf("0x6af4e939 reserve fail:%v", err)
+	}
+	if err := mgr.reservationSet(&ReservationT{Uuid: "uuid-b", SubId: 151, RoleType: 1000}); err != nil {
+		t.Fatalf("0xaa87892f reserve fail:%v", err)
+	}
+
+	ver0 := mgr.repeaterVersion(time.Now())
+	if _, _, err := mgr.bootNode("uuid-a", "1.1.1.1", "v1", 0); err != nil {
+		t.Fatalf("0xcffe71a7 boot fail:%v", err)
+	}
+	ver1 := mgr.repeaterVersion(time.Now())
+	if ver1 <= ver0 {
+		t.Fatalf("0x3a6e1d90 boot ver not increased:%d, %d", ver0, ver1)
+	}
+	// keepalive 不改变集合
+	mgr.updateNode("1.1.1.1", "uuid-a")
+	if ver := mgr.repeaterVersion(time.Now()); ver != ver1 {
+		t.Fatalf("0x0f42c8b7 ping changed ver:%d, %d", ver1, ver)
+	}
+
+	// long-poll 在集合变化时返回
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		if _, _, err := mgr.bootNode("uuid-b", "1.1.1.2", "v1", 0); err != nil {
+			t.Errorf("0x82ada72f boot fail:%v", err)
+		}
+	}()
+	if ver := mgr.waitRepeaterVer(context.Background(), ver1, time.Second*5); ver <= ver1 {
+		t.Fatalf("0x6b15f3a2 wait ver:%d", ver)
+	}
+	ver2 := mgr.repeaterVersion(time.Now())
+	start := time.Now()
+	if ver := mgr.waitRepeaterVer(context.Background(), ver2, time.Millisecond*50); ver != ver2 || time.Since(start) < time.Millisecond*50 {
+		t.Fatalf("0x27d0e9c5 wait timeout ver:%d", ver)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/repeater", NodeRepeaterGet)
+	body, _ := pb.Marshal(&proto.MsgRepeaterServerInfoReq{Machine: &proto.Machine{UUID: "client-a"}})
+	req := func(etag, wait string) *httptest.ResponseRecorder {
+		rsp := httptest.NewRecorder()
+		r0 := httptest.NewRequest(http.MethodPost, "/repeater?wait="+wait, bytes.NewReader(body))
+		if len(etag) > 0 {
+			r0.Header.Set("If-None-Match", etag)
+		}
+		r.ServeHTTP(rsp, r0)
+		return rsp
+	}
+
+	rsp := req("", "")
+	etag := rsp.Header().Get("ETag")
+	if rsp.Code != http.StatusOK || etag != repeaterETag(ver2) {
+		t.Fatalf("0x4d8a07b1 rsp code:%d, etag:%s", rsp.Code, etag)
+	}
+	var info proto.MsgRepeaterServerInfoRsp
+	pb.Unmarshal(rsp.Body.Bytes(), &info)
+	if ver := info.GetVersion(); ver != ver2 || len(info.Servers) != 2 {
+		t.Fatalf("0x5e93b2f0 rsp ver:%d, servers:%d", ver, len(info.Servers))
+	}
+	if rsp = req("W/"+etag, ""); rsp.Code != http.StatusNotModified {
+		t.Fatalf("0x1c7f64ad if-none-match code:%d", rsp.Code)
+	}
+	if rsp = req(`"1"`, ""); rsp.Code != http.StatusOK {
+		t.Fatalf("0x72b0d5e8 stale etag code:%d", rsp.Code)
+	}
+	if rsp = req(etag, "10ms"); rsp.Code != http.StatusNotModified {
+		t.Fatalf("0x38e4a1c6 long-poll timeout code:%d", rsp.Code)
+	}
+}
+
+func TestParseLongPollWait(t *testing.T) {
+	for _, c := range []struct {
+		s    string
+		wait time.Duration
+	}{
+		{"", 0},
+		{"30s", time.Second * 30},
+		{"20", time.Second * 20},
+		{"5m", time.Minute},
+		{"-1s", 0},
+		{"x", 0},
+	} {
+		if wait := parseLongPollWait(c.s, time.Minute); wait != c.wait {
+			t.Fatalf("0x6f1a93d4 wait(%s):%s, expect:%s", c.s, wait, c.wait)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"log"
+)
+
+// subIdBusy 子网号已被 node 占用或已被预留，调用方需持有 dataMtx
+func (mgr *nodeMgrT) subIdBusy(id int) bool {
+	if _, exist := mgr.nodeSubNetIdMap[id]; exist {
+		return true
+	}
+	if _, exist := mgr.reserveSubIdMap[id]; exist {
+		return true
+	}
+	return false
+}
+
+// reservationSet 新增或修改预留，角色须为 Pac 或 Repeater，子网号须属于该角色的地址池，且不得已被其他 uuid 占用或预留
+// 已上线的 node 在下次 boot 时切换到预留的子网号
+func (mgr *nodeMgrT) reservationSet(res *ReservationT) error {
+	if res.SubId < SUBNET_ID_MIN || res.SubId > SUBNET_ID_MAX || len(res.Uuid) == 0 {
+		return errors.New(fmt.Sprintf("0x7c2f9a16 invalid reservation uuid:%s, subId:%d", res.Uuid, res.SubId))
+	}
+	if res.RoleType != int(proto.Role_Pac) && res.RoleType != int(proto.Role_Repeater) {
+		return errors.New(fmt.Sprintf("0x0f5d83b6 invalid reservation role(%d), uuid:%s", res.RoleType, res.Uuid))
+	}
+
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	if role, ok := mgr.subnet.poolRoleOf(res.SubId); !ok || role != res.RoleType {
+		return errors.New(fmt.Sprintf("0x58a2e6c1 subId(%d) not in a %s pool, pool:%s",
+			res.SubId, proto.Role(res.RoleType), mgr.subnet.poolOf(res.SubId)))
+	}
+
+	if other, exist := mgr.reserveSubIdMap[res.SubId]; exist && other.Uuid != res.Uuid {
+		return errors.New(fmt.Sprintf("0x4e0b63d8 subId(%d) already reserved by uuid:%s", res.SubId, other.Uuid))
+	}
+	if node, exist := mgr.nodeSubNetIdMap[res.SubId]; exist && node.Uuid != res.Uuid {
+		return errors.New(fmt.Sprintf("0x19d5c7a2 subId(%d) in use by uuid:%s", res.SubId, node.Uuid))
+	}
+
+	err := mgr.store.UpsertReservation(res)
+	if err != nil {
+		return err
+	}
+
+	if old, exist := mgr.reserveUuidMap[res.Uuid]; exist {
+		delete(mgr.reserveSubIdMap, old.SubId)
+	}
+	r := *res
+	mgr.reserveUuidMap[r.Uuid] = &r
+	mgr.reserveSubIdMap[r.SubId] = &r
+	if node, exist := mgr.nodeUuidMap[r.Uuid]; exist {
+		node.Reserved = true
+	}
+
+	return nil
+}
+
+// reservationDel 删除预留，已上线的 node 保留当前子网号，转为动态分配
+func (mgr *nodeMgrT) reservationDel(uuid string) error {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	res, exist := mgr.reserveUuidMap[uuid]
+	if !exist {
+		return errors.New(fmt.Sprintf("0x6a38e1f5 reservation not found, uuid:%s", uuid))
+	}
+
+	err := mgr.store.DeleteReservationByUuid(uuid)
+	if err != nil {
+		return err
+	}
+
+	delete(mgr.reserveUuidMap, uuid)
+	delete(mgr.reserveSubIdMap, res.SubId)
+	if node, exist := mgr.nodeUuidMap[uuid]; exist {
+		node.Reserved = false
+	}
+
+	return nil
+}
+
+func (mgr *nodeMgrT) reservationGetAll() []ReservationT {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	lst := make([]ReservationT, 0, len(mgr.reserveUuidMap))
+	for _, res := range mgr.reserveUuidMap {
+		lst = append(lst, *res)
+	}
+
+	return lst
+}
+
+// applyReservation 按预留调整已上线 node 的子网号和角色，调用方需持有 dataMtx
+// reserved: 该 node 有预留；changed: 子网号或角色有变化
+func (mgr *nodeMgrT) applyReservation(node *NodeT) (reserved bool, changed bool) {
+	res, exist := mgr.reserveUuidMap[node.Uuid]
+	if !exist {
+		node.Reserved = false
+		return false, false
+	}
+	node.Reserved = true
+	if node.SubId == res.SubId && node.RoleType == res.RoleType {
+		return true, false
+	}
+
+	holder, exist := mgr.nodeSubNetIdMap[res.SubId]
+	if exist && holder != node {
+		log.Printf("ERROR 0x0f6d2b94 reserved subId(%d) of uuid:%s held by uuid:%s", res.SubId, node.Uuid, holder.Uuid)
+		return true, false
+	}
+
+	delete(mgr.nodeSubNetIdMap, node.SubId)
+	node.SubId = res.SubId
+	node.Pool = mgr.subnet.poolOf(res.SubId)
+	node.RoleType = res.RoleType
+	mgr.nodeSubNetIdMap[node.SubId] = node
+
+	return true, true
+}
+
+func ReservationGetAll() []ReservationT {
+	return nodeMgr.reservationGetAll()
+}
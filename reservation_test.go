@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+)
+
+// 预留的角色须为 Pac 或 Repeater，子网号须属于该角色的地址池
+func TestReservationRole(t *testing.T) {
+	NodeMgrInit(NewMemStore(), configDefault())
+	t.Cleanup(nodeMgr.stopReaper)
+	mgr := nodeMgr
+	for _, res := range []*ReservationT{
+		{Uuid: "uuid-a", SubId: 150, RoleType: 7},
+		{Uuid: "uuid-a", SubId: 30, RoleType: 1000},
+		{Uuid: "uuid-a", SubId: 150, RoleType: 1},
+		{Uuid: "uuid-a", SubId: 250, RoleType: 1000},
+	} {
+		if mgr.reservationSet(res) == nil {
+			t.Fatalf("0x6e1a4f27 invalid reservation accepted:%+v", res)
+		}
+	}
+	if err := mgr.reservationSet(&ReservationT{Uuid: "uuid-a", SubId: 30, RoleType: 1}); err != nil {
+		t.Fatalf("0x2b95c0d8 pac reservation:%v", err)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	EVENT_ROLLUP_HOUR = "hour"
+	EVENT_ROLLUP_DAY  = "day"
+
+	eventCompactBatch = 1000
+)
+
+// RetentionCfgT 事件保留策略
+type RetentionCfgT struct {
+	Keep       map[string]DurationT `json:"keep"`       // 事件类型(名称或数值)->保留时长，未配置的类型永久保留
+	Interval   DurationT            `json:"interval"`   // 压缩周期
+	ArchiveDir string               `json:"archiveDir"` // 非空时过期的原始事件删除前先写入该目录(gzip 压缩的 jsonl)
+}
+
+// EventRollupT 某个 node 在 [Start, Start+period) 内某类事件的条数，Start 为 UTC 整点/零点
+type EventRollupT struct {
+	Period string    `json:"period"` // hour, day
+	Start  time.Time `json:"start"`
+	Uuid   string    `json:"uuid"`
+	EType  int       `json:"eType"`
+	Count  int       `json:"count"`
+}
+
+// EventRollupQueryT 汇总查询条件，零值的字段不参与过滤
+type EventRollupQueryT struct {
+	Period string
+	Uuid   string
+	Types  []int
+	Since  time.Time // start >= Since
+	Until  time.Time // start < Until
+}
+
+func (q *EventRollupQueryT) match(r *EventRollupT) bool {
+	if len(q.Period) > 0 && r.Period != q.Period {
+		return false
+	}
+	if len(q.Uuid) > 0 && r.Uuid != q.Uuid {
+		return false
+	}
+	if len(q.Types) > 0 {
+		found := false
+		for _, eType := range q.Types {
+			if r.EType == eType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !q.Since.IsZero() && r.Start.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !r.Start.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// sortEventRollup 按 start、period、uuid、eType 排序，各 Store 实现返回的顺序保持一致
+func sortEventRollup(lst []*EventRollupT) {
+	sort.Slice(lst, func(i, j int) bool {
+		a, b := lst[i], lst[j]
+		if !a.Start.Equal(b.Start) {
+			return a.Start.Before(b.Start)
+		}
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		if a.Uuid != b.Uuid {
+			return a.Uuid < b.Uuid
+		}
+		return a.EType < b.EType
+	})
+}
+
+// rollupEvent 按小时、天汇总每个 node 的事件数
+func rollupEvent(eLst []*EventItemDBT) []*EventRollupT {
+	type keyT struct {
+		period string
+		start  int64
+		uuid   string
+		eType  int
+	}
+	cntMap := make(map[keyT]int)
+	for _, e := range eLst {
+		ts := e.TS.UTC()
+		cntMap[keyT{EVENT_ROLLUP_HOUR, ts.Truncate(time.Hour).Unix(), e.Uuid, e.EType}]++
+		cntMap[keyT{EVENT_ROLLUP_DAY, ts.Truncate(time.Hour * 24).Unix(), e.Uuid, e.EType}]++
+	}
+
+	retLst := make([]*EventRollupT, 0, len(cntMap))
+	for k, cnt := range cntMap {
+		retLst = append(retLst, &EventRollupT{Period: k.period, Start: time.Unix(k.start, 0).UTC(), Uuid: k.uuid, EType: k.eType, Count: cnt})
+	}
+	sortEventRollup(retLst)
+	return retLst
+}
+
+// eventCompactT 定期将超过保留期的事件汇总、归档后删除
+type eventCompactT struct {
+	store      Store
+	keep       map[int]time.Duration // eventType->保留时长
+	interval   time.Duration
+	archiveDir string
+	stopCh     chan struct{} // 关闭后压缩协程退出
+	doneCh     chan struct{}
+}
+
+func newEventCompact(store Store, cfg *RetentionCfgT) (*eventCompactT, error) {
+	ec := &eventCompactT{
+		store:      store,
+		keep:       make(map[int]time.Duration),
+		interval:   time.Duration(cfg.Interval),
+		archiveDir: cfg.ArchiveDir,
+		stopCh:     make(chan struct{}),
+		doneCh:     make(chan struct{}),
+	}
+	for name, keep := range cfg.Keep {
+		eType, err := parseEventType(name)
+		if err != nil {
+			return nil, err
+		}
+		if keep <= 0 {
+			return nil, errors.New(fmt.Sprintf("0x2e5b9f0c invalid retention(%s) of event(%s)", time.Duration(keep), name))
+		}
+		ec.keep[eType] = time.Duration(keep)
+	}
+	if len(ec.keep) > 0 && ec.interval <= 0 {
+		return nil, errors.New(fmt.Sprintf("0x7b01d4e6 invalid retention interval(%s)", ec.interval))
+	}
+	if len(ec.archiveDir) > 0 {
+		err := os.MkdirAll(ec.archiveDir, 0755)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("0x4d8a2c71 create archive dir(%s) fail:%s", ec.archiveDir, err))
+		}
+	}
+	return ec, nil
+}
+
+// compact 处理一轮，返回删除的事件条数
+func (ec *eventCompactT) compact(now time.Time) (int, error) {
+	total := 0
+	for eType, keep := range ec.keep {
+		cnt, err := ec.compactType(eType, now.Add(-keep))
+		total += cnt
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (ec *eventCompactT) compactType(eType int, before time.Time) (int, error) {
+	total := 0
+	for {
+		// 已处理的会被删除，每次都从头取
+		page, err := ec.store.SelectEvent(&EventQueryT{Role: -1, Types: []int{eType}, Until: before, Limit: eventCompactBatch})
+		if err != nil {
+			return total, err
+		}
+		if len(page.Events) == 0 {
+			return total, nil
+		}
+
+		// 先归档再删除，删除失败时下一轮会重复归档，不会丢数据
+		if len(ec.archiveDir) > 0 {
+			err = ec.archive(eType, page.Events)
+			if err != nil {
+				return total, err
+			}
+		}
+
+		idLst := make([]int64, 0, len(page.Events))
+		for _, e := range page.Events {
+			idLst = append(idLst, e.Id)
+		}
+		err = ec.store.CompactEvent(rollupEvent(page.Events), idLst)
+		if err != nil {
+			return total, err
+		}
+		total += len(idLst)
+
+		if page.NextCursor == 0 {
+			return total, nil
+		}
+	}
+}
+
+// archive 写入 archiveDir/event-<type>-<首条 id>-<时间>.jsonl.gz，每行一条事件
+func (ec *eventCompactT) archive(eType int, eLst []*EventItemDBT) error {
+	name := filepath.Join(ec.archiveDir, fmt.Sprintf("event-%d-%d-%s.jsonl.gz", eType, eLst[0].Id, time.Now().Format("20060102T150405")))
+	fp, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x1c7e5a93 create archive(%s) fail:%s", name, err))
+	}
+	defer fp.Close()
+
+	zw := gzip.NewWriter(fp)
+	enc := json.NewEncoder(zw)
+	for _, e := range eLst {
+		err = enc.Encode(e)
+		if err != nil {
+			return errors.New(fmt.Sprintf("0x5f20b8d4 write archive(%s) fail:%s", name, err))
+		}
+	}
+	err = zw.Close()
+	if err == nil {
+		err = fp.Sync()
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x3a94e6c1 flush archive(%s) fail:%s", name, err))
+	}
+	return nil
+}
+
+func (ec *eventCompactT) loopCompact() {
+	defer close(ec.doneCh)
+
+	ticker := time.NewTicker(ec.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ec.stopCh:
+			return
+		case <-ticker.C:
+		}
+		cnt, err := ec.compact(time.Now())
+		if err != nil {
+			log.Printf("ERROR 0x6b3d1f08 compact event fail:%s", err)
+		}
+		if cnt > 0 {
+			log.Printf("LOG 0x08e7c5a2 compact %d expired event", cnt)
+		}
+	}
+}
+
+// stop 停止压缩协程，等正在进行的一轮完成，未启动(nil)时直接返回
+func (ec *eventCompactT) stop() {
+	if ec == nil {
+		return
+	}
+	close(ec.stopCh)
+	<-ec.doneCh
+}
+
+// EventCompactInit 配置了保留策略时启动后台压缩，没有配置时返回 nil
+func EventCompactInit(store Store, cfg *RetentionCfgT) *eventCompactT {
+	ec, err := newEventCompact(store, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(ec.keep) == 0 {
+		return nil
+	}
+	go ec.loopCompact()
+	return ec
+}
+
+// EventRollupGet 查询事件汇总，参数: period(hour/day)、uuid、type、since、until
+func EventRollupGet(c *gin.Context) {
+	eq, err := parseEventQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &AdminRspT{Err: err.Error()})
+		return
+	}
+	q := &EventRollupQueryT{Period: c.Query("period"), Uuid: eq.Uuid, Types: eq.Types, Since: eq.Since, Until: eq.Until}
+	if len(q.Period) > 0 && q.Period != EVENT_ROLLUP_HOUR && q.Period != EVENT_ROLLUP_DAY {
+		c.JSON(http.StatusBadRequest, &AdminRspT{Err: fmt.Sprintf("0x29f4a6d0 invalid period(%s)", q.Period)})
+		return
+	}
+
+	rLst, err := nodeMgr.store.SelectEventRollup(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, &AdminRspT{Err: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rLst)
+}
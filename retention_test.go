@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventCompact(t *testing.T) {
+	now := time.Date(2024, 5, 20, 12, 30, 0, 0, time.UTC)
+	for name, store := range testStores(t) {
+		// 8 天前的 4 条 ping lost(uuid-a 跨 2 个小时)、1 条 boot，以及 1 条新的 ping lost
+		old := now.Add(-time.Hour * 24 * 8)
+		for i, uuid := range []string{"uuid-a", "uuid-a", "uuid-a", "uuid-b"} {
+			store.InsertNodeEvent(&EventItemDBT{Uuid: uuid, EType: 1000, EMsg: "lost", TS: old.Add(time.Minute * 40 * time.Duration(i))})
+		}
+		store.InsertNodeEvent(&EventItemDBT{Uuid: "uuid-a", EType: 0, EMsg: "boot", TS: old})
+		store.InsertNodeEvent(&EventItemDBT{Uuid: "uuid-a", EType: 1000, EMsg: "lost", TS: now})
+
+		dir := filepath.Join(t.TempDir(), name)
+		ec, err := newEventCompact(store, &RetentionCfgT{
+			Keep:       map[string]DurationT{"PINGLOSTPERCENT20": DurationT(time.Hour * 24 * 7), "started": DurationT(time.Hour * 24 * 365)},
+			Interval:   DurationT(time.Hour),
+			ArchiveDir: dir,
+		})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		cnt, err := ec.compact(now)
+		if err != nil || cnt != 4 {
+			t.Fatalf("0x6a0c3e58 %s compact error:%v, cnt:%d", name, err, cnt)
+		}
+
+		eLst, _ := store.SelectEventAll()
+		if len(eLst) != 2 || eLst[0].EType != 0 || !eLst[1].TS.Equal(now) {
+			t.Fatalf("0x2f97d1b4 %s remain event error, len:%d", name, len(eLst))
+		}
+
+		rLst, _ := store.SelectEventRollup(&EventRollupQueryT{Period: EVENT_ROLLUP_HOUR, Uuid: "uuid-a"})
+		if len(rLst) != 2 || rLst[0].Count != 1 || rLst[1].Count != 2 || !rLst[0].Start.Equal(old.Truncate(time.Hour)) {
+			t.Fatalf("0x51b8e7c0 %s hour rollup error:%d", name, len(rLst))
+		}
+		rLst, _ = store.SelectEventRollup(&EventRollupQueryT{Period: EVENT_ROLLUP_DAY})
+		if len(rLst) != 2 || rLst[0].Uuid != "uuid-a" || rLst[0].Count != 3 || rLst[1].Count != 1 {
+			t.Fatalf("0x0d3a6f92 %s day rollup error:%d", name, len(rLst))
+		}
+
+		// 汇总是累加的
+		store.InsertNodeEvent(&EventItemDBT{Uuid: "uuid-b", EType: 1000, EMsg: "lost", TS: old.Add(time.Minute * 5)})
+		ec.compact(now)
+		rLst, _ = store.SelectEventRollup(&EventRollupQueryT{Period: EVENT_ROLLUP_DAY, Uuid: "uuid-b", Types: []int{1000}})
+		if len(rLst) != 1 || rLst[0].Count != 2 {
+			t.Fatalf("0x7c4e21a9 %s rollup not accumulated", name)
+		}
+
+		lines := 0
+		fileLst, _ := filepath.Glob(filepath.Join(dir, "event-1000-*.jsonl.gz"))
+		for _, file := range fileLst {
+			fp, _ := os.Open(file)
+			zr, err := gzip.NewReader(fp)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+			scanner := bufio.NewScanner(zr)
+			for scanner.Scan() {
+				lines++
+			}
+			fp.Close()
+		}
+		if len(fileLst) != 2 || lines != 5 {
+			t.Fatalf("0x3be90d56 %s archive error, files:%d, lines:%d", name, len(fileLst), lines)
+		}
+	}
+}
+
+func TestEventCompactStop(t *testing.T) {
+	if ec := EventCompactInit(NewMemStore(), &RetentionCfgT{}); ec != nil {
+		t.Fatalf("0x4b1e7d03 compact started without keep")
+	}
+	ec := EventCompactInit(NewMemStore(), &RetentionCfgT{
+		Keep:     map[string]DurationT{"PINGLOSTPERCENT20": DurationT(time.Hour)},
+		Interval: DurationT(time.Millisecond),
+	})
+	time.Sleep(time.Millisecond * 5)
+	done := make(chan struct{})
+	go func() {
+		ec.stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("0x6e20c9a4 compact not stopped")
+	}
+}
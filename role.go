@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"net"
+	"sort"
+)
+
+// 角色的决定来源，按 role.order 依次尝试，第一个能决定的为准
+const (
+	ROLE_SOURCE_REQUEST  = "request"  // node 在 STARTED 的 Node.Role 中请求的角色(Pac 或 Repeater)
+	ROLE_SOURCE_OVERRIDE = "override" // 管理员强制的角色，见 POST /v1/admin/node/:uuid/role
+	ROLE_SOURCE_CIDR     = "cidr"     // role.rules 中最长前缀匹配的规则
+	ROLE_SOURCE_IP       = "ip"       // 按地址推断: 国内地址为 pac，其他为 repeater
+
+	ROLE_SOURCE_RESERVATION = "reservation" // 静态预留，总是优先，不参与排序
+)
+
+// RoleRuleCfgT 地址在 CIDR 内的 node 为该角色
+type RoleRuleCfgT struct {
+	CIDR string `json:"cidr"`
+	Role string `json:"role"` // Pac 或 Repeater
+}
+
+// RoleCfgT 角色策略，order 中都不能决定时按地址推断
+type RoleCfgT struct {
+	Order []string       `json:"order"`
+	Rules []RoleRuleCfgT `json:"rules"`
+}
+
+func (cfg *RoleCfgT) check() error {
+	_, err := newRolePolicy(cfg)
+	return err
+}
+
+type roleRuleT struct {
+	cidr  string
+	ipNet *net.IPNet
+	ones  int
+	role  int
+}
+
+type rolePolicyT struct {
+	order []string
+	rules []roleRuleT // 按前缀长度降序
+}
+
+func newRolePolicy(cfg *RoleCfgT) (*rolePolicyT, error) {
+	p := &rolePolicyT{}
+	seen := make(map[string]bool)
+	for _, src := range cfg.Order {
+		if src != ROLE_SOURCE_REQUEST && src != ROLE_SOURCE_OVERRIDE && src != ROLE_SOURCE_CIDR && src != ROLE_SOURCE_IP {
+			return nil, errors.New(fmt.Sprintf("0x6f1d0b83 invalid role source(%s)", src))
+		}
+		if seen[src] {
+			return nil, errors.New(fmt.Sprintf("0x24a7e9c5 duplicate role source(%s)", src))
+		}
+		seen[src] = true
+		p.order = append(p.order, src)
+	}
+	for _, rule := range cfg.Rules {
+		_, ipNet, err := net.ParseCIDR(rule.CIDR)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("0x5b83c2f0 invalid role rule cidr(%s):%s", rule.CIDR, err))
+		}
+		role, err := parseRole(rule.Role)
+		if err != nil || (role != int(proto.Role_Pac) && role != int(proto.Role_Repeater)) {
+			return nil, errors.New(fmt.Sprintf("0x0e4f97a6 invalid role(%s) of rule cidr(%s)", rule.Role, rule.CIDR))
+		}
+		ones, _ := ipNet.Mask.Size()
+		p.rules = append(p.rules, roleRuleT{cidr: rule.CIDR, ipNet: ipNet, ones: ones, role: role})
+	}
+	sort.SliceStable(p.rules, func(i, j int) bool { return p.rules[i].ones > p.rules[j].ones })
+	return p, nil
+}
+
+// matchRule 最长前缀匹配，没有匹配的返回 nil
+func (p *rolePolicyT) matchRule(addr string) *roleRuleT {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil
+	}
+	for i := range p.rules {
+		if p.rules[i].ipNet.Contains(ip) {
+			return &p.rules[i]
+		}
+	}
+	return nil
+}
+
+func roleRequestable(role int) bool {
+	return role == int(proto.Role_Pac) || role == int(proto.Role_Repeater)
+}
+
+// decideRole 按策略决定角色，返回角色及原因，调用方需持有 dataMtx
+// ip 为本次上报的地址，ipv4 为 node 已知的 ipv4 地址(ip 为 ipv6 时用于推断)
+func (mgr *nodeMgrT) decideRole(uuid, ip, ipv4 string, reqRole int) (int, string) {
+	for _, src := range mgr.rolePolicy.order {
+		switch src {
+		case ROLE_SOURCE_REQUEST:
+			if roleRequestable(reqRole) {
+				return reqRole, fmt.Sprintf("%s: %s", src, proto.Role(reqRole))
+			}
+		case ROLE_SOURCE_OVERRIDE:
+			if attr, ok := mgr.attrMap[uuid]; ok && attr.Role != 0 {
+				return attr.Role, fmt.Sprintf("%s: %s", src, proto.Role(attr.Role))
+			}
+		case ROLE_SOURCE_CIDR:
+			for _, addr := range []string{ip, ipv4} {
+				if rule := mgr.rolePolicy.matchRule(addr); rule != nil {
+					return rule.role, fmt.Sprintf("%s: %s in %s", src, addr, rule.cidr)
+				}
+			}
+		}
+		if src == ROLE_SOURCE_IP {
+			break
+		}
+	}
+
+	if role, reason, ok := mgr.pacPinned(uuid, pacAddr(ip, ipv4)); ok {
+		return role, reason
+	}
+	if mgr.pac.isLocalAddr(ip, ipv4) {
+		return int(proto.Role_Pac), fmt.Sprintf("%s: %s is local", ROLE_SOURCE_IP, ip)
+	}
+	return int(proto.Role_Repeater), fmt.Sprintf("%s: %s is not local", ROLE_SOURCE_IP, ip)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRolePolicy(t *testing.T) {
+	for _, cfg := range []RoleCfgT{
+		{Order: []string{"request", "geo"}},
+		{Order: []string{"request", "request"}},
+		{Rules: []RoleRuleCfgT{{CIDR: "1.2.0.0/33", Role: "Pac"}}},
+		{Rules: []RoleRuleCfgT{{CIDR: "1.2.0.0/16", Role: "Default"}}},
+	} {
+		if cfg.check() == nil {
+			t.Fatalf("0x4d0a7e91 invalid role cfg passed:%+v", cfg)
+		}
+	}
+
+	store := NewMemStore()
+	cfg := configDefault()
+	cfg.Role.Order = []string{ROLE_SOURCE_OVERRIDE, ROLE_SOURCE_REQUEST, ROLE_SOURCE_CIDR}
+	// 兜底的规则避免按地址推断(依赖 pac 数据)
+	cfg.Role.Rules = []RoleRuleCfgT{
+		{CIDR: "0.0.0.0/0", Role: "Pac"},
+		{CIDR: "1.2.0.0/16", Role: "Repeater"},
+		{CIDR: "1.2.3.0/24", Role: "Pac"},
+		{CIDR: "2001:db8::/32", Role: "Repeater"},
+	}
+	NodeMgrInit(store, cfg)
+	t.Cleanup(nodeMgr.stopReaper)
+	nodeMgr.dataMtx.Lock()
+	nodeMgr.setNodeAttr("uuid-o", time.Now(), func(attr *NodeAttrT) { attr.Role = 1 })
+	nodeMgr.dataMtx.Unlock()
+
+	for _, c := range []struct {
+		uuid, ip, ipv4 string
+		reqRole        int
+		role           int
+		reason         string
+	}{
+		{"uuid-a", "1.2.3.4", "", 1000, 1000, "request: Repeater"},
+		{"uuid-o", "1.2.9.9", "", 1000, 1, "override: Pac"},
+		{"uuid-a", "1.2.3.4", "", 0, 1, "cidr: 1.2.3.4 in 1.2.3.0/24"},
+		{"uuid-a", "1.2.9.9", "", 0, 1000, "cidr: 1.2.9.9 in 1.2.0.0/16"},
+		{"uuid-a", "2001:db8::1", "1.2.3.4", 0, 1000, "cidr: 2001:db8::1 in 2001:db8::/32"},
+		{"uuid-a", "2400::1", "1.2.3.4", 0, 1, "cidr: 1.2.3.4 in 1.2.3.0/24"},
+		{"uuid-a", "8.8.8.8", "", 0, 1, "cidr: 8.8.8.8 in 0.0.0.0/0"},
+	} {
+		nodeMgr.dataMtx.Lock()
+		role, reason := nodeMgr.decideRole(c.uuid, c.ip, c.ipv4, c.reqRole)
+		nodeMgr.dataMtx.Unlock()
+		if role != c.role || reason != c.reason {
+			t.Fatalf("0x6a1e3c07 %s ip:%s req:%d role:%d reason:%s", c.uuid, c.ip, c.reqRole, role, reason)
+		}
+	}
+
+	// 角色变化时切换子网号，原因随 node 返回
+	node, _, _ := nodeMgr.bootNode("uuid-b", "1.2.9.9", "v1", 0)
+	if node.RoleType != 1000 || node.SubId < 120 || !strings.HasPrefix(node.RoleReason, ROLE_SOURCE_CIDR) {
+		t.Fatalf("0x2c85f0b3 boot node:%+v", node)
+	}
+	node, _, _ = nodeMgr.bootNode("uuid-b", "1.2.9.9", "v1", 1)
+	if node.RoleType != 1 || node.SubId > 49 || node.RoleReason != "request: Pac" {
+		t.Fatalf("0x71f3a9d4 boot node with request:%+v", node)
+	}
+
+	// 请求的角色及原因重启后恢复，pacDiff 仍按请求的角色计算
+	NodeMgrInit(store, cfg)
+	t.Cleanup(nodeMgr.stopReaper)
+	nodeMgr.dataMtx.Lock()
+	node = *nodeMgr.nodeUuidMap["uuid-b"]
+	changeLst := nodeMgr.pacDiff()
+	nodeMgr.dataMtx.Unlock()
+	if node.reqRole != 1 || node.RoleReason != "request: Pac" || len(changeLst) != 0 {
+		t.Fatalf("0x1b6d4e2f boot role after restart:%+v, diff:%+v", node, changeLst)
+	}
+}
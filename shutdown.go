@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ShutdownCfgT 收到 SIGTERM/SIGQUIT/SIGINT 后的退出
+type ShutdownCfgT struct {
+	Timeout DurationT `json:"timeout"` // 等待进行中的请求完成的最长时长
+}
+
+func (cfg *ShutdownCfgT) check() error {
+	if cfg.Timeout <= 0 {
+		return errors.New(fmt.Sprintf("0x47d0b9e3 invalid shutdown timeout(%s)", time.Duration(cfg.Timeout)))
+	}
+	return nil
+}
+
+// closeStore 持有 dataMtx 关闭，不会切断进行中的提交
+func (mgr *nodeMgrT) closeStore() error {
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	return mgr.store.Close()
+}
+
+// Serve 启动 HTTP 服务，收到退出信号后依次: 不再接受新请求并等进行中的完成(SSE、long-poll 立即结束)、
+// 停止 pac 重新加载、事件压缩、回收协程及告警(不再触发、不再重试)，等进行中的告警发送，最后关闭存储；
+// 进行中的请求在 timeout 内没有完成时同样停止后台任务并关闭存储(持有 dataMtx，正在进行的提交先完成)
+func Serve(r *gin.Engine, addr string, cfg *ShutdownCfgT, pw *pacWatchT, ec *eventCompactT) {
+	baseCtx, cancel := context.WithCancel(context.Background())
+	srv := &http.Server{
+		Addr:        addr,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT)
+	select {
+	case err := <-errCh:
+		log.Fatalf("0x1c6e82b7 http serve(%s) fail:%s", addr, err)
+	case sig := <-sigCh:
+		log.Printf("LOG 0x3b8f0d64 recv %s, shutdown", sig)
+	}
+
+	// 请求的 context 来自 baseCtx，取消后 SSE、long-poll 结束，其他请求不受影响
+	cancel()
+	ctx, ctxCancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout))
+	defer ctxCancel()
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		log.Printf("ERROR 0x5d24e9a1 http shutdown fail:%s, requests still running", err)
+	}
+
+	pw.stop()
+	ec.stop()
+	nodeMgr.stopReaper()
+	alertEngine.stop()
+	if !alertEngine.wait(ctx) {
+		log.Printf("WARN 0x2f5c8e17 alert still sending at shutdown")
+	}
+	err = nodeMgr.closeStore()
+	if err != nil {
+		log.Printf("ERROR 0x09f7c3a2 close store fail:%s", err)
+	}
+	log.Printf("LOG 0x62a1b5e0 shutdown done")
+}
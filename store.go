@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	STORE_DRIVER_SQLITE = "sqlite"
+	STORE_DRIVER_BOLT   = "bolt"
+	STORE_DRIVER_MEMORY = "memory" // 仅用于测试，重启后数据丢失
+)
+
+// Store 持久化接口，nodeMgr 只通过它读写数据
+type Store interface {
+	// 网络参数
+	LoadNetConfigItemAll() ([]*NetConfigT, error)
+	FindNetConfigItemByUuid(uuid string) ([]*NetConfigT, error)
+	InsertNetConfig(node *NodeT) error
+	UpdateNetConfigRowByUuid(node *NodeT) error
+	UpdateNetConfigPingByUuid(uuid string) error
+	DeleteNetConfigItemByUuid(uuid string) error
+	// 网络参数与租约在同一事务中提交，insert 为 false 时更新原有行
+	CommitNetConfig(node *NodeT, insert bool) error
+	// 删除网络参数并标记租约已回收，同一事务
+	ReleaseNetConfig(uuid string) error
+	// 删除网络参数及租约，同一事务，再次上线时重新分配子网号
+	DeleteNetConfig(uuid string) error
+	// 删除 uuid 的所有网络参数后重新写入 node，node 为 nil 时标记租约已回收，同一事务
+	ReplaceNetConfig(uuid string, node *NodeT) error
+
+	// 租约
+	LoadLeaseAll() ([]*LeaseT, error)
+	UpsertLease(lease *LeaseT) error
+	ReleaseLeaseByUuid(uuid string) error
+
+	// 静态预留
+	LoadReservationAll() ([]*ReservationT, error)
+	UpsertReservation(res *ReservationT) error
+	DeleteReservationByUuid(uuid string) error
+
+	// node 的签名密钥
+	LoadCredentialAll() ([]*CredentialT, error)
+	UpsertCredential(cred *CredentialT) error
+	DeleteCredentialByUuid(uuid string) error
+
+	// 登记审批及一次性登记 token
+	LoadEnrollAll() ([]*EnrollT, error)
+	UpsertEnroll(enroll *EnrollT) error
+	DeleteEnrollByUuid(uuid string) error
+	LoadEnrollTokenAll() ([]*EnrollTokenT, error)
+	UpsertEnrollToken(token *EnrollTokenT) error
+	DeleteEnrollToken(hash string) error
+
+	// 管理员设置的 node 属性(强制角色、备注、维护)及操作审计
+	LoadNodeAttrAll() ([]*NodeAttrT, error)
+	UpsertNodeAttr(attr *NodeAttrT) error
+	DeleteNodeAttrByUuid(uuid string) error
+	InsertAudit(audit *AuditT) error
+	// 按条件过滤，新的在前
+	SelectAudit(q *AuditQueryT) ([]*AuditT, error)
+
+	// 事件
+	InsertNodeEvent(event *EventItemDBT) error
+	SelectEventAll() ([]*EventItemDBT, error)
+	// 按条件过滤、分页，结果按 id 排序
+	SelectEvent(q *EventQueryT) (*EventPageT, error)
+	// 累加汇总并删除对应的原始事件，同一事务
+	CompactEvent(rollupLst []*EventRollupT, idLst []int64) error
+	SelectEventRollup(q *EventRollupQueryT) ([]*EventRollupT, error)
+
+	Close() error
+}
+
+type StoreCfgT struct {
+	Driver string `json:"driver"` // sqlite, bolt, memory
+	Path   string `json:"path"`
+}
+
+func NewStore(cfg *StoreCfgT) (Store, error) {
+	switch cfg.Driver {
+	case STORE_DRIVER_SQLITE:
+		return NewSqliteStore(cfg.Path)
+	case STORE_DRIVER_BOLT:
+		return NewBoltStore(cfg.Path)
+	case STORE_DRIVER_MEMORY:
+		return NewMemStore(), nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("0x2c6f1e85 unknown store driver(%s)", cfg.Driver))
+}
@@ -0,0 +1,766 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	boltBucketNetConfig      = []byte("netConfig")      // uuid->NetConfigT
+	boltBucketNetConfigSubId = []byte("netConfigSubId") // subId->uuid，保证 sub_id 唯一
+	boltBucketLease          = []byte("lease")          // uuid->LeaseT
+	boltBucketReservation    = []byte("reservation")    // uuid->ReservationT
+	boltBucketReservationSub = []byte("reservationSub") // subId->uuid
+	boltBucketEvent          = []byte("event")          // seq->EventItemDBT
+	boltBucketEventTS        = []byte("eventTS")        // ts(UnixNano)|seq->nil，按时间范围查询事件
+	boltBucketEventRollup    = []byte("eventRollup")    // period|start|uuid|eType->EventRollupT
+	boltBucketCredential     = []byte("credential")     // uuid->CredentialT
+	boltBucketEnroll         = []byte("enroll")         // uuid->EnrollT
+	boltBucketEnrollToken    = []byte("enrollToken")    // hash->EnrollTokenT
+	boltBucketNodeAttr       = []byte("nodeAttr")       // uuid->NodeAttrT
+	boltBucketAudit          = []byte("audit")          // seq->AuditT
+)
+
+// boltStoreT Store 的 bbolt(嵌入式 kv)实现，value 为 json
+type boltStoreT struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (*boltStoreT, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second * 3})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x4b0e8a63 open bolt(%s) fail:%s", path, err))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		indexed := tx.Bucket(boltBucketEventTS) != nil
+		for _, name := range [][]byte{boltBucketNetConfig, boltBucketNetConfigSubId, boltBucketLease,
+			boltBucketReservation, boltBucketReservationSub, boltBucketEvent, boltBucketEventTS, boltBucketEventRollup,
+			boltBucketCredential, boltBucketEnroll, boltBucketEnrollToken, boltBucketNodeAttr, boltBucketAudit} {
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+		}
+		if indexed {
+			return nil
+		}
+		// 之前的版本没有时间索引，补齐已有的事件
+		bTS := tx.Bucket(boltBucketEventTS)
+		return tx.Bucket(boltBucketEvent).ForEach(func(k, v []byte) error {
+			event, err := boltEvent(k, v)
+			if err != nil {
+				return err
+			}
+			return bTS.Put(boltEventTSKey(event.TS, uint64(event.Id)), []byte{})
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.New(fmt.Sprintf("0x1e7c45d9 create bucket fail:%s", err))
+	}
+
+	return &boltStoreT{db: db}, nil
+}
+
+func boltSubIdKey(subId int) []byte {
+	return []byte(strconv.Itoa(subId))
+}
+
+func boltSeqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// boltEventTSKey 8 字节 UnixNano 加 8 字节 seq，按时间排序
+func boltEventTSKey(ts time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(ts.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+func boltEvent(k, v []byte) (*EventItemDBT, error) {
+	event := &EventItemDBT{}
+	err := json.Unmarshal(v, event)
+	event.Id = int64(binary.BigEndian.Uint64(k))
+	return event, err
+}
+
+func boltPutJson(b *bolt.Bucket, key []byte, v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, buf)
+}
+
+// boltLoadAll 遍历 bucket，逐个反序列化后交给 fn
+func (s *boltStoreT) boltLoadAll(bucket []byte, fn func(v []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(k, v []byte) error {
+			return fn(v)
+		})
+	})
+}
+
+// putNetConfig 新增或覆盖一行，sub_id 不得被其他 uuid 占用
+func putNetConfig(tx *bolt.Tx, row *NetConfigT) error {
+	bSub := tx.Bucket(boltBucketNetConfigSubId)
+	owner := bSub.Get(boltSubIdKey(row.SubId))
+	if owner != nil && string(owner) != row.Uuid {
+		return errors.New(fmt.Sprintf("0x2f5a7d18 subId(%d) exist, uuid:%s", row.SubId, string(owner)))
+	}
+
+	b := tx.Bucket(boltBucketNetConfig)
+	old := b.Get([]byte(row.Uuid))
+	if old != nil {
+		var oldRow NetConfigT
+		err := json.Unmarshal(old, &oldRow)
+		if err != nil {
+			return err
+		}
+		err = bSub.Delete(boltSubIdKey(oldRow.SubId))
+		if err != nil {
+			return err
+		}
+	}
+
+	err := bSub.Put(boltSubIdKey(row.SubId), []byte(row.Uuid))
+	if err != nil {
+		return err
+	}
+	return boltPutJson(b, []byte(row.Uuid), row)
+}
+
+// delNetConfig 删除网络参数及子网号索引
+func delNetConfig(tx *bolt.Tx, uuid string) error {
+	b := tx.Bucket(boltBucketNetConfig)
+	v := b.Get([]byte(uuid))
+	if v == nil {
+		return nil
+	}
+	var row NetConfigT
+	err := json.Unmarshal(v, &row)
+	if err != nil {
+		return err
+	}
+	err = tx.Bucket(boltBucketNetConfigSubId).Delete(boltSubIdKey(row.SubId))
+	if err != nil {
+		return err
+	}
+	return b.Delete([]byte(uuid))
+}
+
+func (s *boltStoreT) LoadNetConfigItemAll() ([]*NetConfigT, error) {
+	var retLst []*NetConfigT
+	err := s.boltLoadAll(boltBucketNetConfig, func(v []byte) error {
+		row := &NetConfigT{}
+		err := json.Unmarshal(v, row)
+		retLst = append(retLst, row)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x7d3c9b05 load netConfig fail:%s", err))
+	}
+	return retLst, nil
+}
+
+func (s *boltStoreT) FindNetConfigItemByUuid(uuid string) ([]*NetConfigT, error) {
+	var retLst []*NetConfigT
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltBucketNetConfig).Get([]byte(uuid))
+		if v == nil {
+			return nil
+		}
+		row := &NetConfigT{}
+		err := json.Unmarshal(v, row)
+		retLst = append(retLst, row)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x0a6e2f94 find netConfig fail:%s, uuid:%s", err, uuid))
+	}
+	return retLst, nil
+}
+
+func (s *boltStoreT) InsertNetConfig(node *NodeT) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltBucketNetConfig).Get([]byte(node.Uuid)) != nil {
+			return errors.New("uuid exist")
+		}
+		return putNetConfig(tx, netConfigOfNode(node))
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x58e1b7c2 insert fail:%s, uuid:%s, subId:%d", err, node.Uuid, node.SubId))
+	}
+	return nil
+}
+
+func (s *boltStoreT) UpdateNetConfigRowByUuid(node *NodeT) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltBucketNetConfig).Get([]byte(node.Uuid)) == nil {
+			return errors.New("uuid not exist")
+		}
+		return putNetConfig(tx, netConfigOfNode(node))
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x6c94a0e3 update fail:%s, uuid:%s, subId:%d", err, node.Uuid, node.SubId))
+	}
+	return nil
+}
+
+func (s *boltStoreT) UpdateNetConfigPingByUuid(uuid string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucketNetConfig)
+		v := b.Get([]byte(uuid))
+		if v == nil {
+			return nil
+		}
+		var row NetConfigT
+		err := json.Unmarshal(v, &row)
+		if err != nil {
+			return err
+		}
+		row.TS = time.Now()
+		return boltPutJson(b, []byte(uuid), &row)
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x31f7d8a6 update ping fail:%s, uuid:%s", err, uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) DeleteNetConfigItemByUuid(uuid string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return delNetConfig(tx, uuid)
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x0b2d6e7f delete fail:%s, uuid:%s", err, uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) CommitNetConfig(node *NodeT, insert bool) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		exist := tx.Bucket(boltBucketNetConfig).Get([]byte(node.Uuid)) != nil
+		if exist == insert {
+			return errors.New(fmt.Sprintf("insert:%v, uuid exist:%v", insert, exist))
+		}
+		err := putNetConfig(tx, netConfigOfNode(node))
+		if err != nil {
+			return err
+		}
+		return boltPutJson(tx.Bucket(boltBucketLease), []byte(node.Uuid), node.lease())
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x52f0e9a7 commit fail:%s, uuid:%s, subId:%d", err, node.Uuid, node.SubId))
+	}
+	return nil
+}
+
+func (s *boltStoreT) ReleaseNetConfig(uuid string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		err := delNetConfig(tx, uuid)
+		if err != nil {
+			return err
+		}
+		return releaseLease(tx, uuid)
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x1d6b3c8f release fail:%s, uuid:%s", err, uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) DeleteNetConfig(uuid string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		err := delNetConfig(tx, uuid)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(boltBucketLease).Delete([]byte(uuid))
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x7f4c19a2 delete net config fail:%s, uuid:%s", err, uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) ReplaceNetConfig(uuid string, node *NodeT) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		err := delNetConfig(tx, uuid)
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			return releaseLease(tx, uuid)
+		}
+		err = putNetConfig(tx, netConfigOfNode(node))
+		if err != nil {
+			return err
+		}
+		return boltPutJson(tx.Bucket(boltBucketLease), []byte(uuid), node.lease())
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x0d93e6b4 replace fail:%s, uuid:%s", err, uuid))
+	}
+	return nil
+}
+
+// releaseLease 标记租约已回收，保留记录
+func releaseLease(tx *bolt.Tx, uuid string) error {
+	b := tx.Bucket(boltBucketLease)
+	v := b.Get([]byte(uuid))
+	if v == nil {
+		return nil
+	}
+	var lease LeaseT
+	err := json.Unmarshal(v, &lease)
+	if err != nil {
+		return err
+	}
+	lease.Released = true
+	return boltPutJson(b, []byte(uuid), &lease)
+}
+
+func (s *boltStoreT) LoadLeaseAll() ([]*LeaseT, error) {
+	var retLst []*LeaseT
+	err := s.boltLoadAll(boltBucketLease, func(v []byte) error {
+		lease := &LeaseT{}
+		err := json.Unmarshal(v, lease)
+		retLst = append(retLst, lease)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x4e8f03b1 load lease fail:%s", err))
+	}
+	return retLst, nil
+}
+
+func (s *boltStoreT) UpsertLease(lease *LeaseT) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return boltPutJson(tx.Bucket(boltBucketLease), []byte(lease.Uuid), lease)
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x19c5a2e8 upsert lease fail:%s, uuid:%s", err, lease.Uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) ReleaseLeaseByUuid(uuid string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return releaseLease(tx, uuid)
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x7a06c3f5 release lease fail:%s, uuid:%s", err, uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) LoadReservationAll() ([]*ReservationT, error) {
+	var retLst []*ReservationT
+	err := s.boltLoadAll(boltBucketReservation, func(v []byte) error {
+		res := &ReservationT{}
+		err := json.Unmarshal(v, res)
+		retLst = append(retLst, res)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x2d91e6a4 load reservation fail:%s", err))
+	}
+	return retLst, nil
+}
+
+func (s *boltStoreT) UpsertReservation(res *ReservationT) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bSub := tx.Bucket(boltBucketReservationSub)
+		owner := bSub.Get(boltSubIdKey(res.SubId))
+		if owner != nil && string(owner) != res.Uuid {
+			return errors.New(fmt.Sprintf("subId exist, uuid:%s", string(owner)))
+		}
+
+		b := tx.Bucket(boltBucketReservation)
+		if old := b.Get([]byte(res.Uuid)); old != nil {
+			var oldRes ReservationT
+			err := json.Unmarshal(old, &oldRes)
+			if err != nil {
+				return err
+			}
+			err = bSub.Delete(boltSubIdKey(oldRes.SubId))
+			if err != nil {
+				return err
+			}
+		}
+		err := bSub.Put(boltSubIdKey(res.SubId), []byte(res.Uuid))
+		if err != nil {
+			return err
+		}
+		return boltPutJson(b, []byte(res.Uuid), res)
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x63b8f1d7 upsert reservation fail:%s, uuid:%s, subId:%d", err, res.Uuid, res.SubId))
+	}
+	return nil
+}
+
+func (s *boltStoreT) DeleteReservationByUuid(uuid string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucketReservation)
+		v := b.Get([]byte(uuid))
+		if v == nil {
+			return nil
+		}
+		var res ReservationT
+		err := json.Unmarshal(v, &res)
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket(boltBucketReservationSub).Delete(boltSubIdKey(res.SubId))
+		if err != nil {
+			return err
+		}
+		return b.Delete([]byte(uuid))
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x5f2a9c60 delete reservation fail:%s, uuid:%s", err, uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) InsertNodeEvent(event *EventItemDBT) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucketEvent)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket(boltBucketEventTS).Put(boltEventTSKey(event.TS, seq), []byte{})
+		if err != nil {
+			return err
+		}
+		return boltPutJson(b, boltSeqKey(seq), event)
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x08d4e3b9 insert event fail:%s, uuid:%s", err, event.Uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) SelectEventAll() ([]*EventItemDBT, error) {
+	retLst := make([]*EventItemDBT, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucketEvent).ForEach(func(k, v []byte) error {
+			event, err := boltEvent(k, v)
+			retLst = append(retLst, event)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x3e7b25c1 select event fail:%s", err))
+	}
+	return retLst, nil
+}
+
+// SelectEvent 逐条过滤，不加载全部事件；有时间范围时只读取 ts 索引中范围内的事件
+func (s *boltStoreT) SelectEvent(q *EventQueryT) (*EventPageT, error) {
+	pager := q.pager()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucketEvent)
+		add := func(k, v []byte) error {
+			event, err := boltEvent(k, v)
+			if err != nil {
+				return err
+			}
+			pager.add(event)
+			return nil
+		}
+
+		if q.Since.IsZero() && q.Until.IsZero() {
+			c := b.Cursor()
+			first, next := c.First, c.Next
+			if q.Desc {
+				first, next = c.Last, c.Prev
+			}
+			for k, v := first(); k != nil; k, v = next() {
+				err := add(k, v)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		var seqLst []uint64
+		c := tx.Bucket(boltBucketEventTS).Cursor()
+		k, _ := c.First()
+		if !q.Since.IsZero() {
+			k, _ = c.Seek(boltEventTSKey(q.Since, 0))
+		}
+		for ; k != nil; k, _ = c.Next() {
+			if !q.Until.IsZero() && int64(binary.BigEndian.Uint64(k)) >= q.Until.UnixNano() {
+				break
+			}
+			seqLst = append(seqLst, binary.BigEndian.Uint64(k[8:]))
+		}
+		sort.Slice(seqLst, func(i, j int) bool { return (seqLst[i] < seqLst[j]) != q.Desc })
+		for _, seq := range seqLst {
+			key := boltSeqKey(seq)
+			if v := b.Get(key); v != nil {
+				err := add(key, v)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x5f81c3d6 select event fail:%s", err))
+	}
+	return pager.ret, nil
+}
+
+func (s *boltStoreT) CompactEvent(rollupLst []*EventRollupT, idLst []int64) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bRollup := tx.Bucket(boltBucketEventRollup)
+		for _, r := range rollupLst {
+			key := []byte(eventRollupKey(r))
+			n := *r
+			if v := bRollup.Get(key); v != nil {
+				var old EventRollupT
+				err := json.Unmarshal(v, &old)
+				if err != nil {
+					return err
+				}
+				n.Count += old.Count
+			}
+			err := boltPutJson(bRollup, key, &n)
+			if err != nil {
+				return err
+			}
+		}
+
+		b := tx.Bucket(boltBucketEvent)
+		bTS := tx.Bucket(boltBucketEventTS)
+		for _, id := range idLst {
+			key := boltSeqKey(uint64(id))
+			v := b.Get(key)
+			if v == nil {
+				continue
+			}
+			event, err := boltEvent(key, v)
+			if err != nil {
+				return err
+			}
+			err = bTS.Delete(boltEventTSKey(event.TS, uint64(id)))
+			if err != nil {
+				return err
+			}
+			err = b.Delete(key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x7e4c2b90 compact event fail:%s", err))
+	}
+	return nil
+}
+
+func (s *boltStoreT) SelectEventRollup(q *EventRollupQueryT) ([]*EventRollupT, error) {
+	retLst := make([]*EventRollupT, 0)
+	err := s.boltLoadAll(boltBucketEventRollup, func(v []byte) error {
+		r := &EventRollupT{}
+		err := json.Unmarshal(v, r)
+		if err == nil && q.match(r) {
+			retLst = append(retLst, r)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x3d61f8a4 select rollup fail:%s", err))
+	}
+	sortEventRollup(retLst)
+	return retLst, nil
+}
+
+func (s *boltStoreT) Close() error {
+	return s.db.Close()
+}
+
+func (s *boltStoreT) LoadCredentialAll() ([]*CredentialT, error) {
+	var retLst []*CredentialT
+	err := s.boltLoadAll(boltBucketCredential, func(v []byte) error {
+		cred := &CredentialT{}
+		err := json.Unmarshal(v, cred)
+		retLst = append(retLst, cred)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x58e0c4a1 load credential fail:%s", err))
+	}
+	return retLst, nil
+}
+
+func (s *boltStoreT) UpsertCredential(cred *CredentialT) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return boltPutJson(tx.Bucket(boltBucketCredential), []byte(cred.Uuid), cred)
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x2b7d91f4 upsert credential fail:%s, uuid:%s", err, cred.Uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) DeleteCredentialByUuid(uuid string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucketCredential).Delete([]byte(uuid))
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x7e16a3c9 delete credential fail:%s, uuid:%s", err, uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) LoadEnrollAll() ([]*EnrollT, error) {
+	var retLst []*EnrollT
+	err := s.boltLoadAll(boltBucketEnroll, func(v []byte) error {
+		enroll := &EnrollT{}
+		err := json.Unmarshal(v, enroll)
+		retLst = append(retLst, enroll)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x6d1b4e09 load enroll fail:%s", err))
+	}
+	return retLst, nil
+}
+
+func (s *boltStoreT) UpsertEnroll(enroll *EnrollT) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return boltPutJson(tx.Bucket(boltBucketEnroll), []byte(enroll.Uuid), enroll)
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x3f85a2c7 upsert enroll fail:%s, uuid:%s", err, enroll.Uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) DeleteEnrollByUuid(uuid string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucketEnroll).Delete([]byte(uuid))
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x0e29d7b5 delete enroll fail:%s, uuid:%s", err, uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) LoadEnrollTokenAll() ([]*EnrollTokenT, error) {
+	var retLst []*EnrollTokenT
+	err := s.boltLoadAll(boltBucketEnrollToken, func(v []byte) error {
+		token := &EnrollTokenT{}
+		err := json.Unmarshal(v, token)
+		retLst = append(retLst, token)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x4b7ec310 load enroll token fail:%s", err))
+	}
+	return retLst, nil
+}
+
+func (s *boltStoreT) UpsertEnrollToken(token *EnrollTokenT) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return boltPutJson(tx.Bucket(boltBucketEnrollToken), []byte(token.Hash), token)
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x72a0d6f8 upsert enroll token fail:%s", err))
+	}
+	return nil
+}
+
+func (s *boltStoreT) DeleteEnrollToken(hash string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucketEnrollToken).Delete([]byte(hash))
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x19c4f8e3 delete enroll token fail:%s", err))
+	}
+	return nil
+}
+
+func (s *boltStoreT) LoadNodeAttrAll() ([]*NodeAttrT, error) {
+	var retLst []*NodeAttrT
+	err := s.boltLoadAll(boltBucketNodeAttr, func(v []byte) error {
+		attr := &NodeAttrT{}
+		err := json.Unmarshal(v, attr)
+		retLst = append(retLst, attr)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x3a6e0f71 load node attr fail:%s", err))
+	}
+	return retLst, nil
+}
+
+func (s *boltStoreT) UpsertNodeAttr(attr *NodeAttrT) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return boltPutJson(tx.Bucket(boltBucketNodeAttr), []byte(attr.Uuid), attr)
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x5f19c8a4 upsert node attr fail:%s, uuid:%s", err, attr.Uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) DeleteNodeAttrByUuid(uuid string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucketNodeAttr).Delete([]byte(uuid))
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x0b8d7e25 delete node attr fail:%s, uuid:%s", err, uuid))
+	}
+	return nil
+}
+
+func (s *boltStoreT) InsertAudit(audit *AuditT) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucketAudit)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		a := *audit
+		a.Id = int64(seq)
+		return boltPutJson(b, boltSeqKey(seq), &a)
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("0x6d42a0b9 insert audit fail:%s, action:%s", err, audit.Action))
+	}
+	return nil
+}
+
+func (s *boltStoreT) SelectAudit(q *AuditQueryT) ([]*AuditT, error) {
+	var auditLst []*AuditT
+	err := s.boltLoadAll(boltBucketAudit, func(v []byte) error {
+		audit := &AuditT{}
+		err := json.Unmarshal(v, audit)
+		auditLst = append(auditLst, audit)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x29c5f3e8 select audit fail:%s", err))
+	}
+	return q.pick(auditLst), nil
+}
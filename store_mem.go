@@ -0,0 +1,457 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// memStoreT Store 的内存实现，约束(uuid、sub_id 唯一)与 sqlite 保持一致
+type memStoreT struct {
+	netConfigMap   map[string]*NetConfigT // uuid->row
+	leaseMap       map[string]*LeaseT
+	reservationMap map[string]*ReservationT
+	credentialMap  map[string]*CredentialT
+	enrollMap      map[string]*EnrollT
+	tokenMap       map[string]*EnrollTokenT // hash->token
+	attrMap        map[string]*NodeAttrT
+	auditLst       []*AuditT
+	eventLst       []*EventItemDBT
+	eventSeq       int64
+	rollupMap      map[string]*EventRollupT // period|start|uuid|eType->rollup
+	mtx            sync.Mutex
+}
+
+func NewMemStore() *memStoreT {
+	return &memStoreT{
+		netConfigMap:   make(map[string]*NetConfigT),
+		leaseMap:       make(map[string]*LeaseT),
+		reservationMap: make(map[string]*ReservationT),
+		credentialMap:  make(map[string]*CredentialT),
+		enrollMap:      make(map[string]*EnrollT),
+		tokenMap:       make(map[string]*EnrollTokenT),
+		attrMap:        make(map[string]*NodeAttrT),
+		rollupMap:      make(map[string]*EventRollupT),
+	}
+}
+
+func netConfigOfNode(node *NodeT) *NetConfigT {
+	return &NetConfigT{
+		SubId:    node.SubId,
+		Uuid:     node.Uuid,
+		IP:       node.IP,
+		IPv6:     node.IPv6,
+		RoleType: node.RoleType,
+		TS:       node.Ping,
+		Ver:      node.Ver,
+	}
+}
+
+// subIdUsed sub_id 是否已被其他 uuid 使用，调用方需持锁
+func (s *memStoreT) subIdUsed(subId int, uuid string) bool {
+	for _, row := range s.netConfigMap {
+		if row.SubId == subId && row.Uuid != uuid {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memStoreT) LoadNetConfigItemAll() ([]*NetConfigT, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var retLst []*NetConfigT
+	for _, row := range s.netConfigMap {
+		r := *row
+		retLst = append(retLst, &r)
+	}
+	return retLst, nil
+}
+
+func (s *memStoreT) FindNetConfigItemByUuid(uuid string) ([]*NetConfigT, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var retLst []*NetConfigT
+	if row, ok := s.netConfigMap[uuid]; ok {
+		r := *row
+		retLst = append(retLst, &r)
+	}
+	return retLst, nil
+}
+
+func (s *memStoreT) InsertNetConfig(node *NodeT) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	_, exist := s.netConfigMap[node.Uuid]
+	if exist || s.subIdUsed(node.SubId, node.Uuid) {
+		return errors.New(fmt.Sprintf("0x3a70c8e1 insert fail: uuid(%s) or subId(%d) exist", node.Uuid, node.SubId))
+	}
+	s.netConfigMap[node.Uuid] = netConfigOfNode(node)
+	return nil
+}
+
+func (s *memStoreT) UpdateNetConfigRowByUuid(node *NodeT) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	_, exist := s.netConfigMap[node.Uuid]
+	if !exist {
+		return errors.New(fmt.Sprintf("0x5d2e9f47 update fail: uuid(%s) not exist", node.Uuid))
+	}
+	if s.subIdUsed(node.SubId, node.Uuid) {
+		return errors.New(fmt.Sprintf("0x14b6a3d0 update fail: subId(%d) exist", node.SubId))
+	}
+	s.netConfigMap[node.Uuid] = netConfigOfNode(node)
+	return nil
+}
+
+func (s *memStoreT) UpdateNetConfigPingByUuid(uuid string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if row, ok := s.netConfigMap[uuid]; ok {
+		row.TS = time.Now()
+	}
+	return nil
+}
+
+func (s *memStoreT) DeleteNetConfigItemByUuid(uuid string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.netConfigMap, uuid)
+	return nil
+}
+
+func (s *memStoreT) CommitNetConfig(node *NodeT, insert bool) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	_, exist := s.netConfigMap[node.Uuid]
+	if exist == insert || s.subIdUsed(node.SubId, node.Uuid) {
+		return errors.New(fmt.Sprintf("0x0e4a7c92 commit fail: insert:%v, uuid(%s) exist:%v or subId(%d) used", insert, node.Uuid, exist, node.SubId))
+	}
+	s.netConfigMap[node.Uuid] = netConfigOfNode(node)
+	s.leaseMap[node.Uuid] = node.lease()
+	return nil
+}
+
+func (s *memStoreT) ReleaseNetConfig(uuid string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.netConfigMap, uuid)
+	if lease, ok := s.leaseMap[uuid]; ok {
+		lease.Released = true
+	}
+	return nil
+}
+
+func (s *memStoreT) DeleteNetConfig(uuid string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.netConfigMap, uuid)
+	delete(s.leaseMap, uuid)
+	return nil
+}
+
+func (s *memStoreT) ReplaceNetConfig(uuid string, node *NodeT) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.netConfigMap, uuid)
+	if node == nil {
+		if lease, ok := s.leaseMap[uuid]; ok {
+			lease.Released = true
+		}
+		return nil
+	}
+	if s.subIdUsed(node.SubId, uuid) {
+		return errors.New(fmt.Sprintf("0x2c81f5a9 replace fail: uuid(%s) subId(%d) used", uuid, node.SubId))
+	}
+	s.netConfigMap[uuid] = netConfigOfNode(node)
+	s.leaseMap[uuid] = node.lease()
+	return nil
+}
+
+func (s *memStoreT) LoadLeaseAll() ([]*LeaseT, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var retLst []*LeaseT
+	for _, lease := range s.leaseMap {
+		l := *lease
+		retLst = append(retLst, &l)
+	}
+	return retLst, nil
+}
+
+func (s *memStoreT) UpsertLease(lease *LeaseT) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	l := *lease
+	s.leaseMap[l.Uuid] = &l
+	return nil
+}
+
+func (s *memStoreT) ReleaseLeaseByUuid(uuid string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if lease, ok := s.leaseMap[uuid]; ok {
+		lease.Released = true
+	}
+	return nil
+}
+
+func (s *memStoreT) LoadReservationAll() ([]*ReservationT, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var retLst []*ReservationT
+	for _, res := range s.reservationMap {
+		r := *res
+		retLst = append(retLst, &r)
+	}
+	return retLst, nil
+}
+
+func (s *memStoreT) UpsertReservation(res *ReservationT) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, other := range s.reservationMap {
+		if other.SubId == res.SubId && other.Uuid != res.Uuid {
+			return errors.New(fmt.Sprintf("0x6f8b1c29 upsert reservation fail: subId(%d) exist", res.SubId))
+		}
+	}
+	r := *res
+	s.reservationMap[r.Uuid] = &r
+	return nil
+}
+
+func (s *memStoreT) DeleteReservationByUuid(uuid string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.reservationMap, uuid)
+	return nil
+}
+
+func (s *memStoreT) LoadCredentialAll() ([]*CredentialT, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var retLst []*CredentialT
+	for _, cred := range s.credentialMap {
+		c := *cred
+		retLst = append(retLst, &c)
+	}
+	return retLst, nil
+}
+
+func (s *memStoreT) UpsertCredential(cred *CredentialT) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	c := *cred
+	s.credentialMap[c.Uuid] = &c
+	return nil
+}
+
+func (s *memStoreT) DeleteCredentialByUuid(uuid string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.credentialMap, uuid)
+	return nil
+}
+
+func (s *memStoreT) LoadEnrollAll() ([]*EnrollT, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var retLst []*EnrollT
+	for _, enroll := range s.enrollMap {
+		e := *enroll
+		retLst = append(retLst, &e)
+	}
+	return retLst, nil
+}
+
+func (s *memStoreT) UpsertEnroll(enroll *EnrollT) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	e := *enroll
+	s.enrollMap[e.Uuid] = &e
+	return nil
+}
+
+func (s *memStoreT) DeleteEnrollByUuid(uuid string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.enrollMap, uuid)
+	return nil
+}
+
+func (s *memStoreT) LoadEnrollTokenAll() ([]*EnrollTokenT, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var retLst []*EnrollTokenT
+	for _, token := range s.tokenMap {
+		t := *token
+		retLst = append(retLst, &t)
+	}
+	return retLst, nil
+}
+
+func (s *memStoreT) UpsertEnrollToken(token *EnrollTokenT) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	t := *token
+	s.tokenMap[t.Hash] = &t
+	return nil
+}
+
+func (s *memStoreT) DeleteEnrollToken(hash string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.tokenMap, hash)
+	return nil
+}
+
+func (s *memStoreT) LoadNodeAttrAll() ([]*NodeAttrT, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var retLst []*NodeAttrT
+	for _, attr := range s.attrMap {
+		a := *attr
+		retLst = append(retLst, &a)
+	}
+	return retLst, nil
+}
+
+func (s *memStoreT) UpsertNodeAttr(attr *NodeAttrT) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	a := *attr
+	s.attrMap[a.Uuid] = &a
+	return nil
+}
+
+func (s *memStoreT) DeleteNodeAttrByUuid(uuid string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.attrMap, uuid)
+	return nil
+}
+
+func (s *memStoreT) InsertAudit(audit *AuditT) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	a := *audit
+	a.Id = int64(len(s.auditLst) + 1)
+	s.auditLst = append(s.auditLst, &a)
+	return nil
+}
+
+func (s *memStoreT) SelectAudit(q *AuditQueryT) ([]*AuditT, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return q.pick(s.auditLst), nil
+}
+
+func (s *memStoreT) InsertNodeEvent(event *EventItemDBT) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.eventSeq++
+	e := *event
+	e.Id = s.eventSeq
+	s.eventLst = append(s.eventLst, &e)
+	return nil
+}
+
+func (s *memStoreT) SelectEvent(q *EventQueryT) (*EventPageT, error) {
+	eLst, _ := s.SelectEventAll()
+	return q.page(eLst), nil
+}
+
+func (s *memStoreT) SelectEventAll() ([]*EventItemDBT, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	retLst := make([]*EventItemDBT, 0, len(s.eventLst))
+	for _, event := range s.eventLst {
+		e := *event
+		retLst = append(retLst, &e)
+	}
+	return retLst, nil
+}
+
+func eventRollupKey(r *EventRollupT) string {
+	return fmt.Sprintf("%s|%d|%s|%d", r.Period, r.Start.Unix(), r.Uuid, r.EType)
+}
+
+func (s *memStoreT) CompactEvent(rollupLst []*EventRollupT, idLst []int64) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, r := range rollupLst {
+		key := eventRollupKey(r)
+		if old, ok := s.rollupMap[key]; ok {
+			old.Count += r.Count
+		} else {
+			n := *r
+			s.rollupMap[key] = &n
+		}
+	}
+
+	idMap := make(map[int64]bool)
+	for _, id := range idLst {
+		idMap[id] = true
+	}
+	eLst := s.eventLst[:0]
+	for _, e := range s.eventLst {
+		if !idMap[e.Id] {
+			eLst = append(eLst, e)
+		}
+	}
+	s.eventLst = eLst
+	return nil
+}
+
+func (s *memStoreT) SelectEventRollup(q *EventRollupQueryT) ([]*EventRollupT, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	retLst := make([]*EventRollupT, 0)
+	for _, r := range s.rollupMap {
+		if q.match(r) {
+			n := *r
+			retLst = append(retLst, &n)
+		}
+	}
+	sortEventRollup(retLst)
+	return retLst, nil
+}
+
+func (s *memStoreT) Close() error {
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"time"
+)
+
+// metricStoreT 统计每个 Store 操作的耗时，其余行为与被包装的 Store 一致
+type metricStoreT struct {
+	store Store
+}
+
+func newMetricStore(store Store) Store {
+	return &metricStoreT{store: store}
+}
+
+func (s *metricStoreT) observe(op string, begin time.Time) {
+	metricStoreLatency.WithLabelValues(op).Observe(time.Since(begin).Seconds())
+}
+
+func (s *metricStoreT) LoadNetConfigItemAll() ([]*NetConfigT, error) {
+	defer s.observe("LoadNetConfigItemAll", time.Now())
+	return s.store.LoadNetConfigItemAll()
+}
+
+func (s *metricStoreT) FindNetConfigItemByUuid(uuid string) ([]*NetConfigT, error) {
+	defer s.observe("FindNetConfigItemByUuid", time.Now())
+	return s.store.FindNetConfigItemByUuid(uuid)
+}
+
+func (s *metricStoreT) InsertNetConfig(node *NodeT) error {
+	defer s.observe("InsertNetConfig", time.Now())
+	return s.store.InsertNetConfig(node)
+}
+
+func (s *metricStoreT) UpdateNetConfigRowByUuid(node *NodeT) error {
+	defer s.observe("UpdateNetConfigRowByUuid", time.Now())
+	return s.store.UpdateNetConfigRowByUuid(node)
+}
+
+func (s *metricStoreT) UpdateNetConfigPingByUuid(uuid string) error {
+	defer s.observe("UpdateNetConfigPingByUuid", time.Now())
+	return s.store.UpdateNetConfigPingByUuid(uuid)
+}
+
+func (s *metricStoreT) DeleteNetConfigItemByUuid(uuid string) error {
+	defer s.observe("DeleteNetConfigItemByUuid", time.Now())
+	return s.store.DeleteNetConfigItemByUuid(uuid)
+}
+
+func (s *metricStoreT) CommitNetConfig(node *NodeT, insert bool) error {
+	defer s.observe("CommitNetConfig", time.Now())
+	return s.store.CommitNetConfig(node, insert)
+}
+
+func (s *metricStoreT) ReleaseNetConfig(uuid string) error {
+	defer s.observe("ReleaseNetConfig", time.Now())
+	return s.store.ReleaseNetConfig(uuid)
+}
+
+func (s *metricStoreT) DeleteNetConfig(uuid string) error {
+	defer s.observe("DeleteNetConfig", time.Now())
+	return s.store.DeleteNetConfig(uuid)
+}
+
+func (s *metricStoreT) ReplaceNetConfig(uuid string, node *NodeT) error {
+	defer s.observe("ReplaceNetConfig", time.Now())
+	return s.store.ReplaceNetConfig(uuid, node)
+}
+
+func (s *metricStoreT) LoadLeaseAll() ([]*LeaseT, error) {
+	defer s.observe("LoadLeaseAll", time.Now())
+	return s.store.LoadLeaseAll()
+}
+
+func (s *metricStoreT) UpsertLease(lease *LeaseT) error {
+	defer s.observe("UpsertLease", time.Now())
+	return s.store.UpsertLease(lease)
+}
+
+func (s *metricStoreT) ReleaseLeaseByUuid(uuid string) error {
+	defer s.observe("ReleaseLeaseByUuid", time.Now())
+	return s.store.ReleaseLeaseByUuid(uuid)
+}
+
+func (s *metricStoreT) LoadReservationAll() ([]*ReservationT, error) {
+	defer s.observe("LoadReservationAll", time.Now())
+	return s.store.LoadReservationAll()
+}
+
+func (s *metricStoreT) UpsertReservation(res *ReservationT) error {
+	defer s.observe("UpsertReservation", time.Now())
+	return s.store.UpsertReservation(res)
+}
+
+func (s *metricStoreT) DeleteReservationByUuid(uuid string) error {
+	defer s.observe("DeleteReservationByUuid", time.Now())
+	return s.store.DeleteReservationByUuid(uuid)
+}
+
+func (s *metricStoreT) LoadCredentialAll() ([]*CredentialT, error) {
+	defer s.observe("LoadCredentialAll", time.Now())
+	return s.store.LoadCredentialAll()
+}
+
+func (s *metricStoreT) UpsertCredential(cred *CredentialT) error {
+	defer s.observe("UpsertCredential", time.Now())
+	return s.store.UpsertCredential(cred)
+}
+
+func (s *metricStoreT) DeleteCredentialByUuid(uuid string) error {
+	defer s.observe("DeleteCredentialByUuid", time.Now())
+	return s.store.DeleteCredentialByUuid(uuid)
+}
+
+func (s *metricStoreT) LoadEnrollAll() ([]*EnrollT, error) {
+	defer s.observe("LoadEnrollAll", time.Now())
+	return s.store.LoadEnrollAll()
+}
+
+func (s *metricStoreT) UpsertEnroll(enroll *EnrollT) error {
+	defer s.observe("UpsertEnroll", time.Now())
+	return s.store.UpsertEnroll(enroll)
+}
+
+func (s *metricStoreT) DeleteEnrollByUuid(uuid string) error {
+	defer s.observe("DeleteEnrollByUuid", time.Now())
+	return s.store.DeleteEnrollByUuid(uuid)
+}
+
+func (s *metricStoreT) LoadEnrollTokenAll() ([]*EnrollTokenT, error) {
+	defer s.observe("LoadEnrollTokenAll", time.Now())
+	return s.store.LoadEnrollTokenAll()
+}
+
+func (s *metricStoreT) UpsertEnrollToken(token *EnrollTokenT) error {
+	defer s.observe("UpsertEnrollToken", time.Now())
+	return s.store.UpsertEnrollToken(token)
+}
+
+func (s *metricStoreT) DeleteEnrollToken(hash string) error {
+	defer s.observe("DeleteEnrollToken", time.Now())
+	return s.store.DeleteEnrollToken(hash)
+}
+
+func (s *metricStoreT) LoadNodeAttrAll() ([]*NodeAttrT, error) {
+	defer s.observe("LoadNodeAttrAll", time.Now())
+	return s.store.LoadNodeAttrAll()
+}
+
+func (s *metricStoreT) UpsertNodeAttr(attr *NodeAttrT) error {
+	defer s.observe("UpsertNodeAttr", time.Now())
+	return s.store.UpsertNodeAttr(attr)
+}
+
+func (s *metricStoreT) DeleteNodeAttrByUuid(uuid string) error {
+	defer s.observe("DeleteNodeAttrByUuid", time.Now())
+	return s.store.DeleteNodeAttrByUuid(uuid)
+}
+
+func (s *metricStoreT) InsertAudit(audit *AuditT) error {
+	defer s.observe("InsertAudit", time.Now())
+	return s.store.InsertAudit(audit)
+}
+
+func (s *metricStoreT) SelectAudit(q *AuditQueryT) ([]*AuditT, error) {
+	defer s.observe("SelectAudit", time.Now())
+	return s.store.SelectAudit(q)
+}
+
+func (s *metricStoreT) InsertNodeEvent(event *EventItemDBT) error {
+	defer s.observe("InsertNodeEvent", time.Now())
+	return s.store.InsertNodeEvent(event)
+}
+
+func (s *metricStoreT) SelectEventAll() ([]*EventItemDBT, error) {
+	defer s.observe("SelectEventAll", time.Now())
+	return s.store.SelectEventAll()
+}
+
+func (s *metricStoreT) SelectEvent(q *EventQueryT) (*EventPageT, error) {
+	defer s.observe("SelectEvent", time.Now())
+	return s.store.SelectEvent(q)
+}
+
+func (s *metricStoreT) CompactEvent(rollupLst []*EventRollupT, idLst []int64) error {
+	defer s.observe("CompactEvent", time.Now())
+	return s.store.CompactEvent(rollupLst, idLst)
+}
+
+func (s *metricStoreT) SelectEventRollup(q *EventRollupQueryT) ([]*EventRollupT, error) {
+	defer s.observe("SelectEventRollup", time.Now())
+	return s.store.SelectEventRollup(q)
+}
+
+func (s *metricStoreT) Close() error {
+	return s.store.Close()
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 三种实现的行为需保持一致
+func testStores(t *testing.T) map[string]Store {
+	dir := t.TempDir()
+	sqlite, err := NewSqliteStore(filepath.Join(dir, "nodeInfo.db"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	bolt, err := NewBoltStore(filepath.Join(dir, "nodeInfo.bolt"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	stores := map[string]Store{
+		STORE_DRIVER_SQLITE: sqlite,
+		STORE_DRIVER_BOLT:   bolt,
+		STORE_DRIVER_MEMORY: NewMemStore(),
+	}
+	t.Cleanup(func() {
+		for _, s := range stores {
+			s.Close()
+		}
+	})
+	return stores
+}
+
+func TestStoreNetConfig(t *testing.T) {
+	for name, store := range testStores(t) {
+		nodeA := &NodeT{Uuid: "uuid-a", IP: "1.1.1.1", IPv6: "2001:db8::1", SubId: 120, RoleType: 1000, Ping: time.Now(), Ver: "v1"}
+		nodeB := &NodeT{Uuid: "uuid-b", IP: "2.2.2.2", SubId: 121, RoleType: 1000, Ping: time.Now(), Ver: "v1"}
+		if store.InsertNetConfig(nodeA) != nil || store.InsertNetConfig(nodeB) != nil {
+			t.Fatalf("0x7e24b1c0 %s insert fail", name)
+		}
+
+		// uuid、sub_id 唯一
+		if store.InsertNetConfig(nodeA) == nil {
+			t.Fatalf("0x25c8f6a3 %s duplicate uuid", name)
+		}
+		dup := *nodeB
+		dup.Uuid = "uuid-c"
+		if store.InsertNetConfig(&dup) == nil {
+			t.Fatalf("0x4a1d907e %s duplicate subId", name)
+		}
+		nodeA.SubId = 121
+		if store.UpdateNetConfigRowByUuid(nodeA) == nil {
+			t.Fatalf("0x68f3c2d5 %s update to used subId", name)
+		}
+
+		nodeA.SubId = 122
+		nodeA.Ver = "v2"
+		if store.UpdateNetConfigRowByUuid(nodeA) != nil {
+			t.Fatalf("0x0c7b5e19 %s update fail", name)
+		}
+		retLst, _ := store.FindNetConfigItemByUuid(nodeA.Uuid)
+		if len(retLst) != 1 || retLst[0].SubId != 122 || retLst[0].Ver != "v2" || retLst[0].IPv6 != nodeA.IPv6 {
+			t.Fatalf("0x3d92a6f4 %s find error", name)
+		}
+
+		// 旧的 sub_id 已释放
+		dup.SubId = 120
+		if store.InsertNetConfig(&dup) != nil {
+			t.Fatalf("0x51e0c8b7 %s subId not released", name)
+		}
+
+		store.DeleteNetConfigItemByUuid(nodeB.Uuid)
+		allRow, _ := store.LoadNetConfigItemAll()
+		if len(allRow) != 2 {
+			t.Fatalf("0x1f6ad03e %s load all error, len:%d", name, len(allRow))
+		}
+	}
+}
+
+func TestStoreReservationLease(t *testing.T) {
+	for name, store := range testStores(t) {
+		store.UpsertReservation(&ReservationT{Uuid: "uuid-a", SubId: 150, RoleType: 1000})
+		if store.UpsertReservation(&ReservationT{Uuid: "uuid-b", SubId: 150, RoleType: 1000}) == nil {
+			t.Fatalf("0x2b47e9d1 %s duplicate reserved subId", name)
+		}
+		store.UpsertReservation(&ReservationT{Uuid: "uuid-a", SubId: 151, RoleType: 1000, Note: "core"})
+		if store.UpsertReservation(&ReservationT{Uuid: "uuid-b", SubId: 150, RoleType: 1000}) != nil {
+			t.Fatalf("0x6c03f8a5 %s reserved subId not released", name)
+		}
+		store.DeleteReservationByUuid("uuid-b")
+		resLst, _ := store.LoadReservationAll()
+		if len(resLst) != 1 || resLst[0].SubId != 151 || resLst[0].Note != "core" {
+			t.Fatalf("0x7f18d4c2 %s reservation error", name)
+		}
+
+		store.UpsertLease(&LeaseT{Uuid: "uuid-a", SubId: 151, RoleType: 1000, Expire: time.Now(), Closed: true})
+		store.ReleaseLeaseByUuid("uuid-a")
+		leaseLst, _ := store.LoadLeaseAll()
+		if len(leaseLst) != 1 || leaseLst[0].Released != true || leaseLst[0].Closed != true {
+			t.Fatalf("0x4d5e2a90 %s lease error", name)
+		}
+	}
+}
+
+func TestStoreCredential(t *testing.T) {
+	for name, store := range testStores(t) {
+		created := time.UnixMilli(time.Now().UnixMilli())
+		store.UpsertCredential(&CredentialT{Uuid: "uuid-a", Secret: "aa", Created: created})
+		store.UpsertCredential(&CredentialT{Uuid: "uuid-b", Secret: "bb", Created: created})
+		store.UpsertCredential(&CredentialT{Uuid: "uuid-a", Secret: "cc", Created: created})
+		store.DeleteCredentialByUuid("uuid-b")
+		credLst, err := store.LoadCredentialAll()
+		if err != nil || len(credLst) != 1 || credLst[0].Secret != "cc" || !credLst[0].Created.Equal(created) {
+			t.Fatalf("0x5a0d3e7b %s credential error:%v", name, err)
+		}
+	}
+}
+
+func TestStoreEnroll(t *testing.T) {
+	for name, store := range testStores(t) {
+		now := time.UnixMilli(time.Now().UnixMilli())
+		store.UpsertEnroll(&EnrollT{Uuid: "uuid-a", Status: ENROLL_STATUS_PENDING, IP: "1.1.1.1", First: now, Last: now, Count: 1})
+		store.UpsertEnroll(&EnrollT{Uuid: "uuid-b", Status: ENROLL_STATUS_PENDING, First: now, Last: now})
+		store.UpsertEnroll(&EnrollT{Uuid: "uuid-a", Status: ENROLL_STATUS_APPROVED, IP: "1.1.1.1", First: now, Last: now, Count: 2})
+		store.DeleteEnrollByUuid("uuid-b")
+		enrollLst, err := store.LoadEnrollAll()
+		if err != nil || len(enrollLst) != 1 || enrollLst[0].Status != ENROLL_STATUS_APPROVED || enrollLst[0].Count != 2 || !enrollLst[0].First.Equal(now) {
+			t.Fatalf("0x4c7e19a3 %s enroll error:%v", name, err)
+		}
+
+		store.UpsertEnrollToken(&EnrollTokenT{Hash: "h1", Uuid: "uuid-a", Expire: now, Created: now})
+		store.UpsertEnrollToken(&EnrollTokenT{Hash: "h2", Expire: now, Created: now})
+		store.DeleteEnrollToken("h1")
+		tokenLst, err := store.LoadEnrollTokenAll()
+		if err != nil || len(tokenLst) != 1 || tokenLst[0].Hash != "h2" || !tokenLst[0].Expire.Equal(now) {
+			t.Fatalf("0x2a95d0e6 %s enroll token error:%v", name, err)
+		}
+	}
+}
+
+func TestStoreNodeAttrAudit(t *testing.T) {
+	for name, store := range testStores(t) {
+		now := time.UnixMilli(time.Now().UnixMilli())
+		store.UpsertNodeAttr(&NodeAttrT{Uuid: "uuid-a", Role: 1000, Updated: now})
+		store.UpsertNodeAttr(&NodeAttrT{Uuid: "uuid-b", Note: "b", Updated: now})
+		store.UpsertNodeAttr(&NodeAttrT{Uuid: "uuid-a", Role: 1000, Note: "a", Maintenance: true, Updated: now,
+			DrainSince: now, DrainUntil: now.Add(time.Hour), DrainAssigned: 3, PacPinRole: 1, PacPinIP: "1.1.1.1"})
+		store.DeleteNodeAttrByUuid("uuid-b")
+		attrLst, err := store.LoadNodeAttrAll()
+		if err != nil || len(attrLst) != 1 || attrLst[0].Note != "a" || !attrLst[0].Maintenance || !attrLst[0].Updated.Equal(now) {
+			t.Fatalf("0x7f3a0c52 %s node attr error:%v", name, err)
+		}
+		if !attrLst[0].DrainSince.Equal(now) || !attrLst[0].DrainUntil.Equal(now.Add(time.Hour)) || attrLst[0].DrainAssigned != 3 ||
+			attrLst[0].PacPinRole != 1 || attrLst[0].PacPinIP != "1.1.1.1" {
+			t.Fatalf("0x0c8b5e27 %s node attr drain:%+v", name, attrLst[0])
+		}
+
+		for _, action := range []string{"role", "edit", "role"} {
+			store.InsertAudit(&AuditT{TS: now, Actor: "alice", Action: action, Uuid: "uuid-a"})
+		}
+		store.InsertAudit(&AuditT{TS: now, Action: "role", Uuid: "uuid-b", Err: "fail"})
+		auditLst, err := store.SelectAudit(&AuditQueryT{Action: "role", Limit: 2})
+		if err != nil || len(auditLst) != 2 || auditLst[0].Uuid != "uuid-b" || auditLst[0].Id <= auditLst[1].Id || !auditLst[1].TS.Equal(now) {
+			t.Fatalf("0x1e68b4d9 %s audit error:%v", name, err)
+		}
+		if auditLst, _ = store.SelectAudit(&AuditQueryT{Uuid: "uuid-a", Limit: 10}); len(auditLst) != 3 {
+			t.Fatalf("0x5c2d97a0 %s audit of uuid:%d", name, len(auditLst))
+		}
+	}
+}
+
+func TestStoreEvent(t *testing.T) {
+	for name, store := range testStores(t) {
+		for i := 0; i < 3; i++ {
+			store.InsertNodeEvent(&EventItemDBT{Uuid: "uuid-a", IP: "1.1.1.1", EType: 1000 + i, EMsg: "msg", TS: time.Now()})
+		}
+		eLst, err := store.SelectEventAll()
+		if err != nil || len(eLst) != 3 || eLst[2].EType != 1002 {
+			t.Fatalf("0x39a7c6e1 %s event error", name)
+		}
+	}
+}
+
+func TestStoreCommitNetConfig(t *testing.T) {
+	for name, store := range testStores(t) {
+		nodeA := &NodeT{Uuid: "uuid-a", IP: "1.1.1.1", SubId: 120, RoleType: 1000, Ping: time.Now(), LeaseExpire: time.Now().Add(time.Hour)}
+		if store.CommitNetConfig(nodeA, false) == nil {
+			t.Fatalf("0x3e1b7a06 %s update not exist uuid", name)
+		}
+		if store.CommitNetConfig(nodeA, true) != nil {
+			t.Fatalf("0x6d20c9f1 %s commit insert fail", name)
+		}
+		if store.CommitNetConfig(nodeA, true) == nil {
+			t.Fatalf("0x18f4e2b5 %s duplicate uuid", name)
+		}
+
+		nodeA.SubId = 130
+		if store.CommitNetConfig(nodeA, false) != nil {
+			t.Fatalf("0x52a7d3c0 %s commit update fail", name)
+		}
+		leaseLst, _ := store.LoadLeaseAll()
+		if len(leaseLst) != 1 || leaseLst[0].SubId != 130 || leaseLst[0].Released {
+			t.Fatalf("0x0b9c6e84 %s lease not committed", name)
+		}
+
+		if store.ReleaseNetConfig(nodeA.Uuid) != nil {
+			t.Fatalf("0x7a4f1d29 %s release fail", name)
+		}
+		allRow, _ := store.LoadNetConfigItemAll()
+		leaseLst, _ = store.LoadLeaseAll()
+		if len(allRow) != 0 || len(leaseLst) != 1 || leaseLst[0].Released != true {
+			t.Fatalf("0x25e8b0d7 %s release error", name)
+		}
+
+		// 重新写入，子网号变化时旧的索引一并删除
+		nodeA.SubId = 140
+		if err := store.ReplaceNetConfig(nodeA.Uuid, nodeA); err != nil {
+			t.Fatalf("0x1f6d20a8 %s replace fail:%v", name, err)
+		}
+		if err := store.ReplaceNetConfig(nodeA.Uuid, nodeA); err != nil {
+			t.Fatalf("0x49b3e7c1 %s replace again fail:%v", name, err)
+		}
+		allRow, _ = store.LoadNetConfigItemAll()
+		leaseLst, _ = store.LoadLeaseAll()
+		if len(allRow) != 1 || allRow[0].SubId != 140 || len(leaseLst) != 1 || leaseLst[0].SubId != 140 || leaseLst[0].Released {
+			t.Fatalf("0x6a0c5d93 %s replace error", name)
+		}
+		if store.ReplaceNetConfig(nodeA.Uuid, nil) != nil {
+			t.Fatalf("0x3d7e1f42 %s replace nil fail", name)
+		}
+		allRow, _ = store.LoadNetConfigItemAll()
+		leaseLst, _ = store.LoadLeaseAll()
+		if len(allRow) != 0 || len(leaseLst) != 1 || !leaseLst[0].Released {
+			t.Fatalf("0x58e2a0b6 %s replace nil error", name)
+		}
+	}
+}
+
+func TestStoreSelectEvent(t *testing.T) {
+	base := time.Now().Add(-time.Hour)
+	for name, store := range testStores(t) {
+		for i := 0; i < 10; i++ {
+			uuid := "uuid-a"
+			if i%2 == 1 {
+				uuid = "uuid-b"
+			}
+			store.InsertNodeEvent(&EventItemDBT{Uuid: uuid, IP: "1.1.1.1", RoleType: 1000, Ver: "v1",
+				EType: 1000 + i%3, EMsg: fmt.Sprintf("ping lost %d", i), TS: base.Add(time.Minute * time.Duration(i))})
+		}
+
+		// uuid-a 为 0,2,4,6,8，其中 type 为 1000/1001 的是 0,4,6
+		q := &EventQueryT{Uuid: "uuid-a", Role: -1, Types: []int{1000, 1001}, Limit: 2}
+		page, err := store.SelectEvent(q)
+		if err != nil || page.Total != 3 || len(page.Events) != 2 || page.NextCursor == 0 || page.Events[1].EMsg != "ping lost 4" {
+			t.Fatalf("0x4e2a91c7 %s page 1 error:%v", name, err)
+		}
+		q.Cursor = page.NextCursor
+		page, _ = store.SelectEvent(q)
+		if page.Total != 3 || len(page.Events) != 1 || page.NextCursor != 0 || page.Events[0].EMsg != "ping lost 6" {
+			t.Fatalf("0x1b7d0e35 %s page 2 error", name)
+		}
+
+		q = &EventQueryT{Role: 1000, Since: base.Add(time.Minute * 3), Until: base.Add(time.Minute * 8), Limit: 3, Desc: true}
+		page, _ = store.SelectEvent(q)
+		if page.Total != 5 || len(page.Events) != 3 || page.Events[0].EMsg != "ping lost 7" {
+			t.Fatalf("0x5c80f4a2 %s time range error, total:%d", name, page.Total)
+		}
+		q.Cursor = page.NextCursor
+		page, _ = store.SelectEvent(q)
+		if len(page.Events) != 2 || page.Events[1].EMsg != "ping lost 3" || page.NextCursor != 0 {
+			t.Fatalf("0x20d9b6e3 %s desc page error", name)
+		}
+
+		page, _ = store.SelectEvent(&EventQueryT{Role: -1, Text: "lost 9", Limit: 10})
+		if page.Total != 1 || page.Events[0].Uuid != "uuid-b" {
+			t.Fatalf("0x6a13c5f8 %s text error", name)
+		}
+		page, _ = store.SelectEvent(&EventQueryT{Role: 1, Limit: 10})
+		if page.Total != 0 || page.Events == nil {
+			t.Fatalf("0x39e7a0d4 %s role error", name)
+		}
+	}
+}
+
+// 没有时间索引的旧库打开时补齐索引
+func TestBoltEventIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodeInfo.bolt")
+	store, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		store.InsertNodeEvent(&EventItemDBT{Uuid: "uuid-a", EType: 1000, TS: base.Add(time.Minute * time.Duration(i))})
+	}
+	store.db.Update(func(tx *bolt.Tx) error { return tx.DeleteBucket(boltBucketEventTS) })
+	store.Close()
+
+	store, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	page, err := store.SelectEvent(&EventQueryT{Role: -1, Since: base.Add(time.Minute), Limit: 10, Desc: true})
+	if err != nil || page.Total != 2 || page.Events[0].Id != 3 || page.Events[1].Id != 2 {
+		t.Fatalf("0x0e3c7b95 select after reindex:%+v, err:%v", page, err)
+	}
+}
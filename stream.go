@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StreamCfgT 事件推送(SSE)
+type StreamCfgT struct {
+	Buffer    int       `json:"buffer"`    // 每个订阅者的缓冲条数，满了则断开该订阅者
+	Heartbeat DurationT `json:"heartbeat"` // 没有事件时发送 ping 的周期，避免被代理断开
+}
+
+func (cfg *StreamCfgT) check() error {
+	if cfg.Buffer <= 0 || cfg.Heartbeat <= 0 {
+		return errors.New(fmt.Sprintf("0x0e6c3b58 invalid stream buffer(%d) or heartbeat(%s)", cfg.Buffer, time.Duration(cfg.Heartbeat)))
+	}
+	return nil
+}
+
+// StreamMsgT 推送的一条消息，SSE 的 event 字段为类型名
+type StreamMsgT struct {
+	EType int           `json:"eventType"`
+	Name  string        `json:"name"`
+	TS    time.Time     `json:"ts"`
+	Node  *NodeT        `json:"node,omitempty"`  // node 上线、角色/子网号变化后的 node
+	Event *EventItemDBT `json:"event,omitempty"` // 存活状态变化、EventPost 记录的事件
+}
+
+func (msg *StreamMsgT) uuid() string {
+	if msg.Node != nil {
+		return msg.Node.Uuid
+	}
+	return msg.Event.Uuid
+}
+
+func (msg *StreamMsgT) role() int {
+	if msg.Node != nil {
+		return msg.Node.RoleType
+	}
+	return msg.Event.RoleType
+}
+
+// streamFilterT 为空的条件不过滤
+type streamFilterT struct {
+	roleMap map[int]bool
+	uuidMap map[string]bool
+	typeMap map[int]bool
+}
+
+func (f *streamFilterT) match(msg *StreamMsgT) bool {
+	if len(f.roleMap) > 0 && !f.roleMap[msg.role()] {
+		return false
+	}
+	if len(f.uuidMap) > 0 && !f.uuidMap[msg.uuid()] {
+		return false
+	}
+	if len(f.typeMap) > 0 && !f.typeMap[msg.EType] {
+		return false
+	}
+	return true
+}
+
+// streamSubT 一个订阅者
+type streamSubT struct {
+	filter *streamFilterT
+	ch     chan *StreamMsgT
+	drop   chan struct{} // 缓冲满被断开时 close
+}
+
+// streamHubT 广播给所有订阅者，发布方不阻塞
+type streamHubT struct {
+	buffer    int
+	heartbeat time.Duration
+	subMap    map[*streamSubT]struct{}
+	mtx       sync.Mutex
+}
+
+func newStreamHub(cfg *StreamCfgT) *streamHubT {
+	return &streamHubT{buffer: cfg.Buffer, heartbeat: time.Duration(cfg.Heartbeat), subMap: make(map[*streamSubT]struct{})}
+}
+
+func (hub *streamHubT) subscribe(filter *streamFilterT) *streamSubT {
+	sub := &streamSubT{filter: filter, ch: make(chan *StreamMsgT, hub.buffer), drop: make(chan struct{})}
+
+	hub.mtx.Lock()
+	defer hub.mtx.Unlock()
+	hub.subMap[sub] = struct{}{}
+	return sub
+}
+
+func (hub *streamHubT) unsubscribe(sub *streamSubT) {
+	hub.mtx.Lock()
+	defer hub.mtx.Unlock()
+	delete(hub.subMap, sub)
+}
+
+// publish 订阅者的缓冲满了则断开，不等待慢的订阅者
+func (hub *streamHubT) publish(msg *StreamMsgT) {
+	hub.mtx.Lock()
+	defer hub.mtx.Unlock()
+
+	for sub := range hub.subMap {
+		if !sub.filter.match(msg) {
+			continue
+		}
+		select {
+		case sub.ch <- msg:
+		default:
+			log.Printf("WARN 0x5f1d8e27 stream subscriber too slow, buffer(%d) full, drop it", hub.buffer)
+			delete(hub.subMap, sub)
+			close(sub.drop)
+			metricStreamDrop.Inc()
+		}
+	}
+}
+
+// eventTypeName 本地事件类型或 proto.Event 的名称
+func eventTypeName(eType int) string {
+	if name, ok := eventLocalName[eType]; ok {
+		return name
+	}
+	return proto.Event(eType).String()
+}
+
+// publishNode node 上线、角色/子网号变化，调用方需持有 dataMtx
+func (mgr *nodeMgrT) publishNode(eType int, node *NodeT, now time.Time) {
+	n := *node
+	n.ULA = mgr.ula.subnetOf(n.SubId)
+	n.Region = mgr.region.nodeRegion(node)
+	mgr.stream.publish(&StreamMsgT{EType: eType, Name: eventTypeName(eType), TS: now, Node: &n})
+}
+
+// recordEvent 写入事件表、推送并匹配告警规则，subjectLst 为告警的统计对象，为空时为上报的 node
+func (mgr *nodeMgrT) recordEvent(e *EventItemDBT, subjectLst ...alertSubjectT) {
+	mgr.store.InsertNodeEvent(e)
+	mgr.stream.publish(&StreamMsgT{EType: e.EType, Name: eventTypeName(e.EType), TS: e.TS, Event: e})
+	alertEngine.observe(e, subjectLst...)
+}
+
+// parseStreamFilter role/uuid/type 均可重复或逗号分隔，role、type 可以是名称或数值
+func parseStreamFilter(c *gin.Context) (*streamFilterT, error) {
+	f := &streamFilterT{roleMap: make(map[int]bool), uuidMap: make(map[string]bool), typeMap: make(map[int]bool)}
+	for _, s := range queryList(c, "role") {
+		role, err := strconv.Atoi(s)
+		if err != nil {
+			role, err = parseRole(s)
+			if err != nil {
+				return nil, err
+			}
+		}
+		f.roleMap[role] = true
+	}
+	for _, s := range queryList(c, "uuid") {
+		f.uuidMap[s] = true
+	}
+	for _, s := range queryList(c, "type") {
+		eType, err := parseEventType(s)
+		if err != nil {
+			return nil, err
+		}
+		f.typeMap[eType] = true
+	}
+	return f, nil
+}
+
+// queryList key=a&key=b 或 key=a,b
+func queryList(c *gin.Context, key string) []string {
+	lst := make([]string, 0)
+	for _, arg := range c.QueryArray(key) {
+		for _, s := range strings.Split(arg, ",") {
+			if s = strings.TrimSpace(s); len(s) > 0 {
+				lst = append(lst, s)
+			}
+		}
+	}
+	return lst
+}
+
+// EventStream SSE 推送 node 上线、角色/子网号变化、存活状态变化及新上报的事件
+// 缓冲满时推送 dropped 后断开，客户端重连后可用 EventGet 补齐
+func EventStream(c *gin.Context) {
+	filter, err := parseStreamFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &AdminRspT{Err: err.Error()})
+		return
+	}
+
+	hub := nodeMgr.stream
+	sub := hub.subscribe(filter)
+	defer hub.unsubscribe(sub)
+
+	ticker := time.NewTicker(hub.heartbeat)
+	defer ticker.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Header("Content-Type", "text/event-stream")
+	// 先返回响应头，客户端不必等到第一条事件
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case msg := <-sub.ch:
+			c.SSEvent(msg.Name, msg)
+			return true
+		case <-sub.drop:
+			c.SSEvent("dropped", &AdminRspT{Err: fmt.Sprintf("buffer(%d) full", hub.buffer)})
+			return false
+		case now := <-ticker.C:
+			c.SSEvent("ping", now.UnixMilli())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamHub(t *testing.T) {
+	hub := newStreamHub(&StreamCfgT{Buffer: 2, Heartbeat: DurationT(time.Second)})
+	all := hub.subscribe(&streamFilterT{})
+	repeater := hub.subscribe(&streamFilterT{roleMap: map[int]bool{1000: true}, typeMap: map[int]bool{EVENT_NODE_OFFLINE: true}})
+
+	hub.publish(&StreamMsgT{EType: EVENT_NODE_JOIN, Node: &NodeT{Uuid: "uuid-a", RoleType: 1000}})
+	hub.publish(&StreamMsgT{EType: EVENT_NODE_OFFLINE, Event: &EventItemDBT{Uuid: "uuid-a", RoleType: 1000}})
+	if len(all.ch) != 2 || len(repeater.ch) != 1 {
+		t.Fatalf("0x3d7a0c95 filter error:%d, %d", len(all.ch), len(repeater.ch))
+	}
+
+	// 缓冲满的订阅者被断开，不影响其他订阅者
+	hub.publish(&StreamMsgT{EType: EVENT_NODE_OFFLINE, Event: &EventItemDBT{Uuid: "uuid-b", RoleType: 1000}})
+	select {
+	case <-all.drop:
+	default:
+		t.Fatalf("0x61e4b9a2 slow subscriber not dropped")
+	}
+	if _, exist := hub.subMap[all]; exist || len(repeater.ch) != 2 {
+		t.Fatalf("0x0b5f2d73 drop error")
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	NodeMgrInit(NewMemStore(), configDefault())
+	t.Cleanup(nodeMgr.stopReaper)
+	if err := nodeMgr.reservationSet(&ReservationT{Uuid: "uuid-a", SubId: 150, RoleType: 1000}); err != nil {
+		t.Fatalf("0x28b81326 reserve fail:%v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/stream", EventStream)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	if rsp, _ := http.Get(srv.URL + "/stream?type=bad"); rsp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("0x2a96e1c4 bad type code:%d", rsp.StatusCode)
+	}
+
+	rsp, err := http.Get(srv.URL + "/stream?type=NODE_JOIN,NODE_ONLINE&uuid=uuid-a&role=Repeater")
+	if err != nil {
+		t.Fatalf("0x7c03d5b8 get stream:%s", err)
+	}
+	defer rsp.Body.Close()
+	for len(nodeMgr.stream.subMap) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, _, err = nodeMgr.bootNode("uuid-a", "1.1.1.1", "v1", 0); err != nil {
+		t.Fatalf("0x3e8b0f61 boot:%s", err)
+	}
+
+	expect := []string{"event:NODE_JOIN", "event:NODE_ONLINE"}
+	scanner := bufio.NewScanner(rsp.Body)
+	for scanner.Scan() && len(expect) > 0 {
+		if strings.HasPrefix(scanner.Text(), "event:") {
+			if scanner.Text() != expect[0] {
+				t.Fatalf("0x4f18a6e0 stream event:%s, expect:%s", scanner.Text(), expect[0])
+			}
+			expect = expect[1:]
+		}
+	}
+	if len(expect) > 0 {
+		t.Fatalf("0x15b7c9d2 missing stream event:%v", expect)
+	}
+}
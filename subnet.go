@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+)
+
+const (
+	SUBNET_ID_MIN = 1
+	SUBNET_ID_MAX = 255
+)
+
+// SubnetPoolCfgT 子网号地址池(10.x.0.0 中的 x)
+// Min/Max 与 CIDR 二选一，CIDR 按 10.x.0.0 的第二段展开，如 10.120.0.0/13 -> 120~127
+type SubnetPoolCfgT struct {
+	Name    string `json:"name"`
+	Role    string `json:"role"` // Pac or Repeater
+	Min     int    `json:"min,omitempty"`
+	Max     int    `json:"max,omitempty"`
+	CIDR    string `json:"cidr,omitempty"`
+	Exclude []int  `json:"exclude,omitempty"` // 不参与分配的子网号
+}
+
+// SubnetPoolStatT 地址池使用情况
+type SubnetPoolStatT struct {
+	Name     string `json:"name"`
+	Role     int    `json:"role"`
+	Size     int    `json:"size"`
+	Used     int    `json:"used"`
+	Reserved int    `json:"reserved"` // 静态预留(含已上线的)
+}
+
+type subnetPoolT struct {
+	name string
+	role int
+	ids  []int // 可分配的子网号(升序，已剔除 Exclude)
+}
+
+type subnetAllocT struct {
+	pools    []*subnetPoolT // 按配置顺序分配
+	idPoolMp map[int]*subnetPoolT
+}
+
+func newSubnetPool(cfg *SubnetPoolCfgT) (*subnetPoolT, error) {
+	role, err := parseRole(cfg.Role)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("0x0c7e5b92 pool(%s) %s", cfg.Name, err))
+	}
+
+	min, max := cfg.Min, cfg.Max
+	if len(cfg.CIDR) > 0 {
+		if min != 0 || max != 0 {
+			return nil, errors.New(fmt.Sprintf("0x2b8a4c61 pool(%s) min/max and cidr both set", cfg.Name))
+		}
+		_, ipNet, err := net.ParseCIDR(cfg.CIDR)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("0x5f13d0a8 pool(%s) invalid cidr(%s)", cfg.Name, cfg.CIDR))
+		}
+		ip4 := ipNet.IP.To4()
+		ones, bits := ipNet.Mask.Size()
+		if ip4 == nil || bits != 32 || ip4[0] != 10 || ones < 8 || ones > 16 {
+			return nil, errors.New(fmt.Sprintf("0x71c4e2d5 pool(%s) cidr(%s) must be 10.x.0.0/8~16", cfg.Name, cfg.CIDR))
+		}
+		min = int(ip4[1])
+		max = min + (1 << (16 - ones)) - 1
+		// 10.0.0.0/8、10.0.0.0/9 等包含 10.0 的，子网号 0 不可用，从 SUBNET_ID_MIN 开始
+		if max < SUBNET_ID_MIN {
+			return nil, errors.New(fmt.Sprintf("0x1d8e6f40 pool(%s) cidr(%s) has no subId >= %d", cfg.Name, cfg.CIDR, SUBNET_ID_MIN))
+		}
+		if min < SUBNET_ID_MIN {
+			min = SUBNET_ID_MIN
+		}
+	}
+	if min < SUBNET_ID_MIN || max > SUBNET_ID_MAX || min > max {
+		return nil, errors.New(fmt.Sprintf("0x3ea09f17 pool(%s) invalid range %d~%d", cfg.Name, min, max))
+	}
+
+	exclude := make(map[int]bool)
+	for _, id := range cfg.Exclude {
+		exclude[id] = true
+	}
+
+	pool := &subnetPoolT{name: cfg.Name, role: role}
+	for i := min; i <= max; i++ {
+		if exclude[i] == false {
+			pool.ids = append(pool.ids, i)
+		}
+	}
+
+	return pool, nil
+}
+
+// newSubnetAlloc 校验配置：池名不得重复，同一子网号不得属于多个池
+func newSubnetAlloc(cfgLst []SubnetPoolCfgT) (*subnetAllocT, error) {
+	alloc := &subnetAllocT{idPoolMp: make(map[int]*subnetPoolT)}
+	names := make(map[string]bool)
+
+	for i := range cfgLst {
+		pool, err := newSubnetPool(&cfgLst[i])
+		if err != nil {
+			return nil, err
+		}
+		if len(pool.name) == 0 || names[pool.name] {
+			return nil, errors.New(fmt.Sprintf("0x4d6b28e3 pool name(%s) empty or duplicate", pool.name))
+		}
+		names[pool.name] = true
+
+		for _, id := range pool.ids {
+			if other, exist := alloc.idPoolMp[id]; exist {
+				return nil, errors.New(fmt.Sprintf("0x1a9f7c05 subId(%d) in both pool(%s) and pool(%s)", id, other.name, pool.name))
+			}
+			alloc.idPoolMp[id] = pool
+		}
+		alloc.pools = append(alloc.pools, pool)
+	}
+
+	return alloc, nil
+}
+
+// alloc 从指定角色的地址池中找一个未被占用(busy 返回 false)的子网号
+func (a *subnetAllocT) alloc(role int, busy func(id int) bool) (int, string, bool) {
+	for _, pool := range a.pools {
+		if pool.role != role {
+			continue
+		}
+		for _, id := range pool.ids {
+			if busy(id) == false {
+				return id, pool.name, true
+			}
+		}
+	}
+
+	return 0, "", false
+}
+
+// allocPrefer 优先使用 prefer(该 uuid 之前的子网号)，被占用或不属于该角色时再按池顺序分配
+func (a *subnetAllocT) allocPrefer(role int, prefer int, busy func(id int) bool) (int, string, bool) {
+	pool, ok := a.idPoolMp[prefer]
+	if ok && pool.role == role && busy(prefer) == false {
+		return prefer, pool.name, true
+	}
+
+	return a.alloc(role, busy)
+}
+
+// poolOf 子网号所属的池，不属于任何池时返回 ""
+func (a *subnetAllocT) poolOf(subId int) string {
+	pool, ok := a.idPoolMp[subId]
+	if !ok {
+		return ""
+	}
+	return pool.name
+}
+
+// poolRoleOf 子网号所属的池的角色，不属于任何池时返回 false
+func (a *subnetAllocT) poolRoleOf(subId int) (int, bool) {
+	pool, ok := a.idPoolMp[subId]
+	if !ok {
+		return 0, false
+	}
+	return pool.role, true
+}
+
+func (a *subnetAllocT) stat(used map[int]*NodeT, reserved map[int]*ReservationT) []SubnetPoolStatT {
+	lst := make([]SubnetPoolStatT, 0, len(a.pools))
+	for _, pool := range a.pools {
+		st := SubnetPoolStatT{Name: pool.name, Role: pool.role, Size: len(pool.ids)}
+		for _, id := range pool.ids {
+			if _, exist := used[id]; exist {
+				st.Used++
+			}
+			if _, exist := reserved[id]; exist {
+				st.Reserved++
+			}
+		}
+		lst = append(lst, st)
+	}
+
+	return lst
+}
+
+// reloadSubnetPool 替换地址池配置，已分配的子网号保持不变(即使已不在任何池中)
+func (mgr *nodeMgrT) reloadSubnetPool(cfgLst []SubnetPoolCfgT) error {
+	alloc, err := newSubnetAlloc(cfgLst)
+	if err != nil {
+		return err
+	}
+
+	mgr.dataMtx.Lock()
+	defer mgr.dataMtx.Unlock()
+
+	mgr.subnet = alloc
+	for _, node := range mgr.nodeUuidMap {
+		node.Pool = alloc.poolOf(node.SubId)
+		if len(node.Pool) == 0 {
+			log.Printf("WARN 0x6c2e0b7f node(uuid:%s, subId:%d) not in any pool after reload", node.Uuid, node.SubId)
+		}
+	}
+
+	return nil
+}
+
+// SubnetPoolReload 重新读取配置文件中的地址池
+func SubnetPoolReload(path string) error {
+	cfg, err := ConfigLoad(path)
+	if err != nil {
+		return err
+	}
+	return nodeMgr.reloadSubnetPool(cfg.SubnetPools)
+}
+
+func SubnetPoolStat() []SubnetPoolStatT {
+	nodeMgr.dataMtx.Lock()
+	defer nodeMgr.dataMtx.Unlock()
+
+	return nodeMgr.subnet.stat(nodeMgr.nodeSubNetIdMap, nodeMgr.reserveSubIdMap)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"github.com/shankusu2017/proto_pb/go/proto"
+	"testing"
+)
+
+func TestSubnetPoolCIDR(t *testing.T) {
+	alloc, err := newSubnetAlloc([]SubnetPoolCfgT{
+		{Name: "rpt", Role: "Repeater", CIDR: "10.120.0.0/13", Exclude: []int{120, 121}},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	used := make(map[int]*NodeT)
+	busy := func(id int) bool {
+		_, exist := used[id]
+		return exist
+	}
+	id, pool, ok := alloc.alloc(int(proto.Role_Repeater), busy)
+	if !ok || id != 122 || pool != "rpt" {
+		t.Fatalf("0x0e1c5a77 alloc error, id:%d, pool:%s", id, pool)
+	}
+	if alloc.poolOf(127) != "rpt" || alloc.poolOf(128) != "" || alloc.poolOf(120) != "" {
+		t.Fatalf("0x38b7e2c9 poolOf error")
+	}
+
+	// 角色不匹配
+	_, _, ok = alloc.alloc(int(proto.Role_Pac), busy)
+	if ok {
+		t.Fatalf("0x5d0f61ab pac alloc from repeater pool")
+	}
+
+	// 用尽
+	for i := 122; i <= 127; i++ {
+		used[i] = &NodeT{}
+	}
+	_, _, ok = alloc.alloc(int(proto.Role_Repeater), busy)
+	if ok {
+		t.Fatalf("0x47a93e02 pool should be exhausted")
+	}
+
+	// 优先沿用
+	delete(used, 125)
+	delete(used, 126)
+	id, _, ok = alloc.allocPrefer(int(proto.Role_Repeater), 126, busy)
+	if !ok || id != 126 {
+		t.Fatalf("0x2a6e9f3c allocPrefer error, id:%d", id)
+	}
+}
+
+func TestSubnetPoolInvalid(t *testing.T) {
+	cfgLst := [][]SubnetPoolCfgT{
+		{{Name: "a", Role: "Pac", Min: 20, Max: 30}, {Name: "b", Role: "Repeater", Min: 30, Max: 40}}, // 重叠
+		{{Name: "a", Role: "Pac", Min: 20, Max: 30}, {Name: "a", Role: "Pac", Min: 40, Max: 50}},      // 重名
+		{{Name: "a", Role: "Pac", CIDR: "192.168.0.0/16"}},
+		{{Name: "a", Role: "Pac", Min: 0, Max: 300}},
+		{{Name: "a", Role: "Unknown", Min: 1, Max: 2}},
+		{{Name: "a", Role: "Pac", CIDR: "10.0.0.0/16"}}, // 只有子网号 0
+	}
+	for i, cfg := range cfgLst {
+		_, err := newSubnetAlloc(cfg)
+		if err == nil {
+			t.Fatalf("0x6b2d04f1 cfg[%d] should be invalid", i)
+		}
+	}
+}
+
+// /8、/9 包含子网号 0，从 SUBNET_ID_MIN 开始
+func TestSubnetPoolWide(t *testing.T) {
+	for cidr, size := range map[string]int{"10.0.0.0/8": 255, "10.0.0.0/9": 127, "10.128.0.0/9": 128} {
+		pool, err := newSubnetPool(&SubnetPoolCfgT{Name: "a", Role: "Repeater", CIDR: cidr})
+		if err != nil || len(pool.ids) != size || pool.ids[0] < SUBNET_ID_MIN || pool.ids[len(pool.ids)-1] > SUBNET_ID_MAX {
+			t.Fatalf("0x3c72a9e5 cidr(%s) pool error:%v", cidr, err)
+		}
+	}
+}
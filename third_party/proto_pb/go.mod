@@ -0,0 +1,7 @@
+module github.com/shankusu2017/proto_pb
+
+go 1.21
+
+require google.golang.org/protobuf v1.34.1
+
+require github.com/golang/protobuf v1.5.4 // indirect
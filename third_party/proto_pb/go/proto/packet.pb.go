@@ -0,0 +1,223 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        v3.18.0
+// source: packet.proto
+
+package proto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// 8.bit + 8.bit(flag) + 16.bit(type)
+type PacketFlag int32
+
+const (
+	PacketFlag_NULL PacketFlag = 0
+	PacketFlag_PING PacketFlag = 1
+	PacketFlag_IP   PacketFlag = 2 // 普通的IP包
+	PacketFlag_SEQ  PacketFlag = 65536
+	PacketFlag_ACK  PacketFlag = 131072 // 65536*2
+)
+
+// Enum value maps for PacketFlag.
+var (
+	PacketFlag_name = map[int32]string{
+		0:      "NULL",
+		1:      "PING",
+		2:      "IP",
+		65536:  "SEQ",
+		131072: "ACK",
+	}
+	PacketFlag_value = map[string]int32{
+		"NULL": 0,
+		"PING": 1,
+		"IP":   2,
+		"SEQ":  65536,
+		"ACK":  131072,
+	}
+)
+
+func (x PacketFlag) Enum() *PacketFlag {
+	p := new(PacketFlag)
+	*p = x
+	return p
+}
+
+func (x PacketFlag) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PacketFlag) Descriptor() protoreflect.EnumDescriptor {
+	return file_packet_proto_enumTypes[0].Descriptor()
+}
+
+func (PacketFlag) Type() protoreflect.EnumType {
+	return &file_packet_proto_enumTypes[0]
+}
+
+func (x PacketFlag) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PacketFlag.Descriptor instead.
+func (PacketFlag) EnumDescriptor() ([]byte, []int) {
+	return file_packet_proto_rawDescGZIP(), []int{0}
+}
+
+// 报文
+type Packet struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type uint64 `protobuf:"varint,1,opt,name=type,proto3" json:"type,omitempty"` // 类型
+	Uuid string `protobuf:"bytes,2,opt,name=uuid,proto3" json:"uuid,omitempty"`  // uuid标识符（对于发送方来说唯一）,长度为 constant.UUID_MACHINE_LENGTH * 2
+	Load []byte `protobuf:"bytes,3,opt,name=load,proto3" json:"load,omitempty"`
+}
+
+func (x *Packet) Reset() {
+	*x = Packet{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_packet_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Packet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Packet) ProtoMessage() {}
+
+func (x *Packet) ProtoReflect() protoreflect.Message {
+	mi := &file_packet_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Packet.ProtoReflect.Descriptor instead.
+func (*Packet) Descriptor() ([]byte, []int) {
+	return file_packet_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Packet) GetType() uint64 {
+	if x != nil {
+		return x.Type
+	}
+	return 0
+}
+
+func (x *Packet) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *Packet) GetLoad() []byte {
+	if x != nil {
+		return x.Load
+	}
+	return nil
+}
+
+var File_packet_proto protoreflect.FileDescriptor
+
+var file_packet_proto_rawDesc = []byte{
+	0x0a, 0x0c, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06,
+	0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x22, 0x44, 0x0a, 0x06, 0x50, 0x61, 0x63, 0x6b, 0x65, 0x74,
+	0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04,
+	0x74, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x75, 0x75, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x75, 0x75, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x6f, 0x61, 0x64,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x6c, 0x6f, 0x61, 0x64, 0x2a, 0x3e, 0x0a, 0x0a,
+	0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x46, 0x6c, 0x61, 0x67, 0x12, 0x08, 0x0a, 0x04, 0x4e, 0x55,
+	0x4c, 0x4c, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x50, 0x49, 0x4e, 0x47, 0x10, 0x01, 0x12, 0x06,
+	0x0a, 0x02, 0x49, 0x50, 0x10, 0x02, 0x12, 0x09, 0x0a, 0x03, 0x53, 0x45, 0x51, 0x10, 0x80, 0x80,
+	0x04, 0x12, 0x09, 0x0a, 0x03, 0x41, 0x43, 0x4b, 0x10, 0x80, 0x80, 0x08, 0x42, 0x09, 0x5a, 0x07,
+	0x2f, 0x3b, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_packet_proto_rawDescOnce sync.Once
+	file_packet_proto_rawDescData = file_packet_proto_rawDesc
+)
+
+func file_packet_proto_rawDescGZIP() []byte {
+	file_packet_proto_rawDescOnce.Do(func() {
+		file_packet_proto_rawDescData = protoimpl.X.CompressGZIP(file_packet_proto_rawDescData)
+	})
+	return file_packet_proto_rawDescData
+}
+
+var file_packet_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_packet_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_packet_proto_goTypes = []interface{}{
+	(PacketFlag)(0), // 0: packet.PacketFlag
+	(*Packet)(nil),  // 1: packet.Packet
+}
+var file_packet_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_packet_proto_init() }
+func file_packet_proto_init() {
+	if File_packet_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_packet_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Packet); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_packet_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_packet_proto_goTypes,
+		DependencyIndexes: file_packet_proto_depIdxs,
+		EnumInfos:         file_packet_proto_enumTypes,
+		MessageInfos:      file_packet_proto_msgTypes,
+	}.Build()
+	File_packet_proto = out.File
+	file_packet_proto_rawDesc = nil
+	file_packet_proto_goTypes = nil
+	file_packet_proto_depIdxs = nil
+}
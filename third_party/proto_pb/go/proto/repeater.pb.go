@@ -0,0 +1,152 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        v3.18.0
+// source: repeater.proto
+
+package proto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// RepeaterServerNode 单个服务器信息
+type RepeaterServerNode struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	IPv4 string `protobuf:"bytes,1,opt,name=IPv4,proto3" json:"IPv4,omitempty"` // ipv4地址
+	IPv6 string `protobuf:"bytes,2,opt,name=IPv6,proto3" json:"IPv6,omitempty"` // ipv6地址，仅有 ipv6 的 repeater 其 IPv4 为空
+}
+
+func (x *RepeaterServerNode) Reset() {
+	*x = RepeaterServerNode{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_repeater_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RepeaterServerNode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RepeaterServerNode) ProtoMessage() {}
+
+func (x *RepeaterServerNode) ProtoReflect() protoreflect.Message {
+	mi := &file_repeater_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RepeaterServerNode.ProtoReflect.Descriptor instead.
+func (*RepeaterServerNode) Descriptor() ([]byte, []int) {
+	return file_repeater_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RepeaterServerNode) GetIPv4() string {
+	if x != nil {
+		return x.IPv4
+	}
+	return ""
+}
+
+func (x *RepeaterServerNode) GetIPv6() string {
+	if x != nil {
+		return x.IPv6
+	}
+	return ""
+}
+
+var File_repeater_proto protoreflect.FileDescriptor
+
+var file_repeater_proto_rawDesc = []byte{
+	0x0a, 0x0e, 0x72, 0x65, 0x70, 0x65, 0x61, 0x74, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x12, 0x08, 0x72, 0x65, 0x70, 0x65, 0x61, 0x74, 0x65, 0x72, 0x22, 0x3c, 0x0a, 0x12, 0x52, 0x65,
+	0x70, 0x65, 0x61, 0x74, 0x65, 0x72, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4e, 0x6f, 0x64, 0x65,
+	0x12, 0x12, 0x0a, 0x04, 0x49, 0x50, 0x76, 0x34, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x49, 0x50, 0x76, 0x34, 0x12, 0x12, 0x0a, 0x04, 0x49, 0x50, 0x76, 0x36, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x49, 0x50, 0x76, 0x36, 0x42, 0x09, 0x5a, 0x07, 0x2f, 0x3b, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_repeater_proto_rawDescOnce sync.Once
+	file_repeater_proto_rawDescData = file_repeater_proto_rawDesc
+)
+
+func file_repeater_proto_rawDescGZIP() []byte {
+	file_repeater_proto_rawDescOnce.Do(func() {
+		file_repeater_proto_rawDescData = protoimpl.X.CompressGZIP(file_repeater_proto_rawDescData)
+	})
+	return file_repeater_proto_rawDescData
+}
+
+var file_repeater_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_repeater_proto_goTypes = []interface{}{
+	(*RepeaterServerNode)(nil), // 0: repeater.RepeaterServerNode
+}
+var file_repeater_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_repeater_proto_init() }
+func file_repeater_proto_init() {
+	if File_repeater_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_repeater_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RepeaterServerNode); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_repeater_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_repeater_proto_goTypes,
+		DependencyIndexes: file_repeater_proto_depIdxs,
+		MessageInfos:      file_repeater_proto_msgTypes,
+	}.Build()
+	File_repeater_proto = out.File
+	file_repeater_proto_rawDesc = nil
+	file_repeater_proto_goTypes = nil
+	file_repeater_proto_depIdxs = nil
+}